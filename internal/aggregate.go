@@ -0,0 +1,166 @@
+package ashudb
+
+// aggregateFunction describes how an aggregate folds the values of a
+// group into one. Groups are folded in parts, possibly concurrently,
+// and the partial states merged afterwards, so merge must give the
+// same result however the rows were split.
+type aggregateFunction struct {
+	// resultType returns the type of the result for an argument of
+	// type arg, or an error if the aggregate can't take it
+	resultType func(arg ColumnType) (ColumnType, error)
+	// initial is the state of a group no value has been folded into
+	initial MemoryCell
+	// step folds a value into a state. Unknown values are skipped.
+	step func(state, value MemoryCell, typ ColumnType) (MemoryCell, error)
+	// merge combines the states of two parts of the same group
+	merge func(a, b MemoryCell, typ ColumnType) (MemoryCell, error)
+}
+
+func sumCells(a, b MemoryCell, typ ColumnType) (MemoryCell, error) {
+	if a == nil {
+		return b, nil
+	}
+
+	if b == nil {
+		return a, nil
+	}
+
+	return numericArithmetic(PlusSymbol, a, b, typ)
+}
+
+// sumType is the type of the sum of values of type arg. Small integers
+// are summed as BIGINT so that many of them don't overflow.
+func sumType(arg ColumnType) (ColumnType, error) {
+	switch {
+	case arg == SmallIntType || arg == IntType:
+		return BigIntType, nil
+	case !isNumeric(arg):
+		return 0, ErrInvalidOperands
+	}
+
+	return arg, nil
+}
+
+// extremeCell returns a function keeping whichever of two cells sorts
+// first when multiplied by sign.
+func extremeCell(sign int) func(a, b MemoryCell, typ ColumnType) (MemoryCell, error) {
+	return func(a, b MemoryCell, typ ColumnType) (MemoryCell, error) {
+		if a == nil {
+			return b, nil
+		}
+
+		if b == nil || compareCells(a, b, typ)*sign <= 0 {
+			return a, nil
+		}
+
+		return b, nil
+	}
+}
+
+func anyType(arg ColumnType) (ColumnType, error) {
+	return arg, nil
+}
+
+var aggregateFunctions = map[string]aggregateFunction{
+	"count": {
+		resultType: func(ColumnType) (ColumnType, error) {
+			return IntType, nil
+		},
+		initial: intToCell(0),
+		step: func(state, value MemoryCell, _ ColumnType) (MemoryCell, error) {
+			if value == nil {
+				return state, nil
+			}
+
+			return intToCell(state.AsInt() + 1), nil
+		},
+		merge: func(a, b MemoryCell, _ ColumnType) (MemoryCell, error) {
+			return sumCells(a, b, IntType)
+		},
+	},
+	"sum": {
+		resultType: sumType,
+		step: func(state, value MemoryCell, typ ColumnType) (MemoryCell, error) {
+			sum, err := sumType(typ)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err = convertNumeric(value, typ, sum)
+			if err != nil {
+				return nil, err
+			}
+
+			return sumCells(state, value, sum)
+		},
+		merge: func(a, b MemoryCell, typ ColumnType) (MemoryCell, error) {
+			sum, err := sumType(typ)
+			if err != nil {
+				return nil, err
+			}
+
+			return sumCells(a, b, sum)
+		},
+	},
+	"min": {
+		resultType: anyType,
+		step:       extremeCell(1),
+		merge:      extremeCell(1),
+	},
+	"max": {
+		resultType: anyType,
+		step:       extremeCell(-1),
+		merge:      extremeCell(-1),
+	},
+}
+
+// aggregateGroups accumulates the states of every group of an
+// aggregation, remembering the order the groups were first seen in.
+type aggregateGroups struct {
+	// types are the types of the key cells
+	types  []ColumnType
+	keys   [][]MemoryCell
+	states [][]MemoryCell
+	index  map[string]int
+}
+
+func newAggregateGroups(types []ColumnType) *aggregateGroups {
+	return &aggregateGroups{types: types, index: map[string]int{}}
+}
+
+// group returns the states of the group with key, starting a new group
+// if there is none yet.
+func (ag *aggregateGroups) group(key []MemoryCell, functions []aggregateFunction) []MemoryCell {
+	encoded := hashKey(key, ag.types)
+	if i, ok := ag.index[encoded]; ok {
+		return ag.states[i]
+	}
+
+	states := []MemoryCell{}
+	for _, function := range functions {
+		states = append(states, function.initial)
+	}
+
+	ag.index[encoded] = len(ag.keys)
+	ag.keys = append(ag.keys, key)
+	ag.states = append(ag.states, states)
+	return states
+}
+
+// merge folds the groups of another part, which comes after this one
+// in the input, into this one.
+func (ag *aggregateGroups) merge(other *aggregateGroups, functions []aggregateFunction, types []ColumnType) error {
+	for i, key := range other.keys {
+		states := ag.group(key, functions)
+		for j, function := range functions {
+			state, err := function.merge(states[j], other.states[i][j], types[j])
+			if err != nil {
+				return err
+			}
+
+			states[j] = state
+		}
+	}
+
+	return nil
+}
@@ -1,37 +1,135 @@
 package ashudb
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 type ColumnType uint
 
 const (
 	TextType ColumnType = iota
 	IntType
+	BoolType
+	RealType
+	DoubleType
+	DecimalType
+	SmallIntType
+	BigIntType
+	DateType
+	TimeType
+	TimestampType
+	IntervalType
+	BlobType
+	JsonType
+	UuidType
 )
 
 type Cell interface {
 	AsText() string
 	AsInt() int32
+	AsSmallInt() int16
+	AsBigInt() int64
+	AsBool() bool
+	AsFloat() float64
+	AsDecimal() Decimal
+	AsDate() time.Time
+	AsTime() time.Time
+	AsTimestamp() time.Time
+	AsInterval() Interval
+	AsBytes() []byte
+	AsUUID() UUID
+}
+
+type ResultColumn struct {
+	Type ColumnType
+	Name string
+	// table is the table or alias the column comes from, used to
+	// resolve qualified references like `users.id`
+	table string
+	// pad is the length of a CHAR(n) column, whose values are padded
+	// with spaces to it when returned
+	pad int
 }
 
 type Results struct {
-	Columns []struct {
-		Type ColumnType
-		Name string
-	}
-	Rows [][]Cell
+	Columns []ResultColumn
+	Rows    [][]Cell
 }
 
 var (
-	ErrTableDoesNotExist  = errors.New("table does not exist")
-	ErrColumnDoesNotExist = errors.New("column does not exist")
-	ErrInvalidSelectItem  = errors.New("select item is not valid")
-	ErrInvalidDatatype    = errors.New("invalid datatype")
-	ErrMissingValues      = errors.New("missing values")
+	ErrTableDoesNotExist    = errors.New("table does not exist")
+	ErrColumnDoesNotExist   = errors.New("column does not exist")
+	ErrInvalidSelectItem    = errors.New("select item is not valid")
+	ErrInvalidDatatype      = errors.New("invalid datatype")
+	ErrMissingValues        = errors.New("missing values")
+	ErrDuplicateColumn      = errors.New("column specified more than once")
+	ErrInvalidOperands      = errors.New("operands are invalid")
+	ErrDivisionByZero       = errors.New("division by zero")
+	ErrIndexAlreadyExists   = errors.New("index already exists")
+	ErrIndexDoesNotExist    = errors.New("index does not exist")
+	ErrColumnAmbiguous      = errors.New("column reference is ambiguous")
+	ErrFunctionDoesNotExist = errors.New("function does not exist")
+	ErrInvalidArguments     = errors.New("function arguments are invalid")
+	ErrMisplacedAggregate   = errors.New("aggregate functions are not allowed here")
+	ErrInvalidStorage       = errors.New("invalid storage layout")
+	ErrNumericOverflow      = errors.New("numeric value out of range")
+	ErrInvalidDatetime      = errors.New("invalid date or time")
+	ErrDatetimeOverflow     = errors.New("date or time out of range")
+	ErrValueTooLong         = errors.New("value too long for type")
+	ErrInvalidJSON          = errors.New("invalid json")
+	ErrInvalidUUID          = errors.New("invalid uuid")
+	ErrMisplacedDefault     = errors.New("DEFAULT is only allowed in VALUES")
+	ErrInvalidCast          = errors.New("value can't be converted to type")
+	ErrUniqueViolation      = errors.New("duplicate key value violates unique constraint")
+	ErrNullViolation        = errors.New("null value violates not-null constraint")
+	ErrMultiplePrimaryKeys  = errors.New("multiple primary keys are not allowed")
+	ErrNoConflictConstraint = errors.New("no unique constraint matches the ON CONFLICT columns")
+	ErrRowAffectedTwice     = errors.New("ON CONFLICT DO UPDATE can't affect a row twice")
+	ErrSequenceExists       = errors.New("sequence already exists")
+	ErrSequenceDoesNotExist = errors.New("sequence does not exist")
+	ErrSequenceNotCalled    = errors.New("currval of sequence is not yet defined")
+	ErrInvalidIncrement     = errors.New("sequence increment can't be zero")
+	ErrIdentityDefault      = errors.New("identity column can't have a default")
+	ErrGeneratedAlways      = errors.New("can't insert a value into a GENERATED ALWAYS column")
+	ErrMisplacedSubquery    = errors.New("subqueries are not allowed here")
+	ErrSubqueryColumns      = errors.New("subquery must return only one column")
+	ErrSubqueryRows         = errors.New("more than one row returned by a subquery used as an expression")
+	ErrCTEColumns           = errors.New("WITH query has fewer columns than names given")
+	ErrSetColumns           = errors.New("each query of a set operation must have the same number of columns")
+	ErrSetTypes             = errors.New("column types of a set operation can't be matched")
+	ErrRecursionLimit       = errors.New("recursive query exceeded the iteration limit")
+	ErrInvalidPattern       = errors.New("invalid LIKE pattern or regular expression")
+	ErrBranchTypes          = errors.New("types of CASE results or COALESCE, GREATEST or LEAST arguments can't be matched")
+	ErrInvalidPlan          = errors.New("plan kind is not valid")
 )
 
+// ColumnTypeError is returned when a value inserted into a column
+// doesn't have, and can't be converted to, the column's type.
+type ColumnTypeError struct {
+	Column   string
+	Expected ColumnType
+	Actual   ColumnType
+	// Err is why the value couldn't be converted, ErrInvalidDatatype if
+	// its type can't be at all
+	Err error
+}
+
+func (e *ColumnTypeError) Error() string {
+	return fmt.Sprintf("column %s is of type %s but value is of type %s: %s", e.Column, e.Expected, e.Actual, e.Err)
+}
+
+func (e *ColumnTypeError) Unwrap() error {
+	return e.Err
+}
+
 type Backend interface {
 	CreateTable(*CreateTableStatement) error
-	Insert(*InsertStatement) error
+	CreateIndex(*CreateIndexStatement) error
+	CreateSequence(*CreateSequenceStatement) error
+	Insert(*InsertStatement) (*Results, error)
 	Select(*SelectStatement) (*Results, error)
+	Explain(*ExplainStatement) (*Results, error)
+	Analyze(*AnalyzeStatement) error
 }
@@ -0,0 +1,134 @@
+package ashudb
+
+// Expressions are parsed without a backend, but nextval, currval and
+// subqueries need one to run against. They are bound to the backend
+// when the statement holding them is run, as are the tables in FROM
+// clauses that name common table expressions.
+
+// bind binds the nextval and currval calls and the subqueries of e to
+// mb, the subqueries seeing the common table expressions of ctes.
+func (mb *MemoryBackend) bind(e expression, ctes *cteScope) {
+	switch e.kind {
+	case binaryKind:
+		mb.bind(e.binary.a, ctes)
+		mb.bind(e.binary.b, ctes)
+	case functionKind:
+		if isSequenceFunction(e.function.name.Value) {
+			e.function.sequences = mb.sequences
+		}
+
+		for _, arg := range e.function.args {
+			mb.bind(arg, ctes)
+		}
+	case subqueryKind:
+		// The subquery is bound again each time it runs, but its FROM
+		// clause has to be known before that to work out whether it is
+		// correlated
+		e.subquery.bound = &boundSubquery{mb: mb, ctes: ctes}
+		mb.bindSelect(e.subquery.slct, ctes)
+		if e.subquery.in != nil {
+			mb.bind(*e.subquery.in, ctes)
+		}
+	}
+}
+
+// bindSelect binds every expression of slct, and of the subqueries and
+// common table expressions in it, to mb. ctes are the common table
+// expressions of the queries slct is nested in.
+func (mb *MemoryBackend) bindSelect(slct *SelectStatement, ctes *cteScope) {
+	if slct.with != nil {
+		ctes = mb.bindWith(slct.with, ctes)
+	}
+
+	if slct.set != nil {
+		mb.bindSelect(slct.set.left, ctes)
+		mb.bindSelect(slct.set.right, ctes)
+		return
+	}
+
+	for _, exp := range slct.distinctOn {
+		mb.bind(*exp, ctes)
+	}
+
+	for _, item := range *slct.item {
+		if item.exp != nil {
+			mb.bind(*item.exp, ctes)
+		}
+	}
+
+	if slct.from != nil {
+		mb.bindFrom(slct.from, ctes)
+	}
+
+	for _, join := range slct.joins {
+		mb.bindFrom(join.from, ctes)
+
+		if join.on != nil {
+			mb.bind(*join.on, ctes)
+		}
+	}
+
+	if slct.where != nil {
+		mb.bind(*slct.where, ctes)
+	}
+
+	for _, key := range slct.groupBy {
+		mb.bind(*key, ctes)
+	}
+}
+
+// bindWith binds the common table expressions of with, returning the
+// scope the query they belong to sees them in.
+func (mb *MemoryBackend) bindWith(with *withClause, ctes *cteScope) *cteScope {
+	for _, cte := range with.ctes {
+		bound := &boundCTE{mb: mb, cte: cte, ctes: ctes}
+		mb.bindSelect(cte.slct, ctes)
+
+		if cte.recursive != nil {
+			bound.self = &boundCTE{mb: mb, cte: cte, ctes: ctes, working: &relation{}}
+			mb.bindSelect(cte.recursive, &cteScope{cte: bound.self, parent: ctes})
+		}
+
+		ctes = &cteScope{cte: bound, parent: ctes}
+	}
+
+	return ctes
+}
+
+func (mb *MemoryBackend) bindFrom(from *fromItem, ctes *cteScope) {
+	if from.subquery != nil {
+		mb.bindSelect(from.subquery, ctes)
+		return
+	}
+
+	// Written only once, since the subqueries of a recursive term are
+	// bound again as they run, possibly in parallel
+	from.cte = ctes.lookup(from.table.Value)
+	if from.cte != nil && from.cte.working != nil && !from.cte.referenced {
+		from.cte.referenced = true
+	}
+}
+
+// bindInsert binds every expression of inst to mb, other than those of
+// its query, which are bound when it is run.
+func (mb *MemoryBackend) bindInsert(inst *InsertStatement) {
+	for _, tuple := range inst.values {
+		for _, value := range *tuple {
+			mb.bind(*value, nil)
+		}
+	}
+
+	if inst.onConflict != nil {
+		for _, a := range inst.onConflict.set {
+			mb.bind(a.value, nil)
+		}
+	}
+
+	if inst.returning != nil {
+		for _, item := range *inst.returning {
+			if item.exp != nil {
+				mb.bind(*item.exp, nil)
+			}
+		}
+	}
+}
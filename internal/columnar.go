@@ -0,0 +1,851 @@
+package ashudb
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// batchSize is how many rows columnar execution evaluates at a time:
+// enough to amortize the work done per batch, few enough for a
+// batch's vectors to stay in cache.
+const batchSize = 1024
+
+// columnVector holds one column of a columnar table. Integers and
+// booleans, as 1 or 0, are stored unboxed in ints. Text is dictionary
+// encoded, every distinct string stored once and rows holding its
+// position in the dictionary. Values of other types are kept as cells
+// and only read a row at a time. Unknown values are marked in nulls,
+// and stored as zero or the empty string where values are unboxed.
+type columnVector struct {
+	typ        ColumnType
+	ints       []int32
+	codes      []uint32
+	dictionary []string
+	codeOf     map[string]uint32
+	cells      []MemoryCell
+	// nulls marks the rows whose value is unknown, nil while there
+	// are none
+	nulls []bool
+}
+
+// unboxed reports whether a vector of type typ stores its values
+// unboxed, so that expressions over it can be evaluated a batch at a
+// time.
+func unboxed(typ ColumnType) bool {
+	return typ == IntType || typ == BoolType || typ == TextType
+}
+
+func newColumnVector(typ ColumnType) *columnVector {
+	return &columnVector{typ: typ, codeOf: map[string]uint32{}}
+}
+
+func (cv *columnVector) len() int {
+	switch cv.typ {
+	case IntType, BoolType:
+		return len(cv.ints)
+	case TextType:
+		return len(cv.codes)
+	}
+
+	return len(cv.cells)
+}
+
+// cellSizes are the sizes of the cells of fixed size types.
+var cellSizes = map[ColumnType]int{
+	IntType:       4,
+	BoolType:      1,
+	RealType:      4,
+	DoubleType:    8,
+	DecimalType:   9,
+	SmallIntType:  2,
+	BigIntType:    8,
+	DateType:      4,
+	TimeType:      8,
+	TimestampType: 8,
+	IntervalType:  16,
+	UuidType:      16,
+}
+
+// check reports whether a cell can be stored in the vector.
+func (cv *columnVector) check(cell MemoryCell) error {
+	if size, ok := cellSizes[cv.typ]; cell != nil && ok && len(cell) != size {
+		return ErrInvalidDatatype
+	}
+
+	return nil
+}
+
+// unboxInt returns an integer or boolean cell as it's stored in ints.
+func unboxInt(cell MemoryCell, typ ColumnType) int32 {
+	switch {
+	case cell == nil:
+		return 0
+	case typ == BoolType:
+		return boolToInt(cell.AsBool())
+	}
+
+	return cell.AsInt()
+}
+
+// setNull marks whether the i'th value, or the one about to be
+// appended if i is the length of the vector, is unknown.
+func (cv *columnVector) setNull(i int, null bool) {
+	if cv.nulls == nil {
+		if !null {
+			return
+		}
+
+		cv.nulls = make([]bool, cv.len())
+	}
+
+	if i == len(cv.nulls) {
+		cv.nulls = append(cv.nulls, null)
+	} else {
+		cv.nulls[i] = null
+	}
+}
+
+func (cv *columnVector) append(cell MemoryCell) {
+	cv.setNull(cv.len(), cell == nil)
+
+	switch cv.typ {
+	case IntType, BoolType:
+		cv.ints = append(cv.ints, unboxInt(cell, cv.typ))
+	case TextType:
+		cv.codes = append(cv.codes, cv.code(cell))
+	default:
+		cv.cells = append(cv.cells, cell)
+	}
+}
+
+// set replaces the i'th value.
+func (cv *columnVector) set(i int, cell MemoryCell) {
+	cv.setNull(i, cell == nil)
+
+	switch cv.typ {
+	case IntType, BoolType:
+		cv.ints[i] = unboxInt(cell, cv.typ)
+	case TextType:
+		cv.codes[i] = cv.code(cell)
+	default:
+		cv.cells[i] = cell
+	}
+}
+
+// code returns the position of text in the dictionary, adding it if
+// it isn't there.
+func (cv *columnVector) code(cell MemoryCell) uint32 {
+	code, ok := cv.codeOf[string(cell)]
+	if !ok {
+		code = uint32(len(cv.dictionary))
+		cv.dictionary = append(cv.dictionary, string(cell))
+		cv.codeOf[string(cell)] = code
+	}
+
+	return code
+}
+
+func (cv *columnVector) cell(i int) MemoryCell {
+	if cv.nulls != nil && cv.nulls[i] {
+		return nil
+	}
+
+	switch cv.typ {
+	case IntType:
+		return intToCell(cv.ints[i])
+	case BoolType:
+		return boolToCell(cv.ints[i] != 0)
+	case TextType:
+		return MemoryCell(cv.dictionary[cv.codes[i]])
+	}
+
+	return cv.cells[i]
+}
+
+// vector is the value of an expression for every row of a batch, in
+// ints for integers and booleans and in texts for text, with nulls
+// marking unknown values as a column's do. Vectors read straight from
+// a column share its storage and must not be written to.
+type vector struct {
+	typ   ColumnType
+	ints  []int32
+	texts []string
+	nulls []bool
+}
+
+// null reports whether the i'th value is unknown.
+func (v *vector) null(i int) bool {
+	return v.nulls != nil && v.nulls[i]
+}
+
+// eitherNull marks the rows where x or y is unknown, nil if there are
+// none, as the result of most operations on them is.
+func eitherNull(x, y *vector, n int) []bool {
+	if x.nulls == nil && y.nulls == nil {
+		return nil
+	}
+
+	nulls := make([]bool, n)
+	for i := range nulls {
+		nulls[i] = x.null(i) || y.null(i)
+	}
+
+	return nulls
+}
+
+func (v *vector) cell(i int) MemoryCell {
+	if v.null(i) {
+		return nil
+	}
+
+	switch v.typ {
+	case IntType:
+		return intToCell(v.ints[i])
+	case BoolType:
+		return boolToCell(v.ints[i] != 0)
+	}
+
+	return MemoryCell(v.texts[i])
+}
+
+// batch is a range of the rows of a columnar table, seen through the
+// vectors of the columns a scan produces.
+type batch struct {
+	columns []ResultColumn
+	vectors []*columnVector
+	start   int
+	end     int
+}
+
+// forEachBatch calls fn for every batch of the rows in s.
+func forEachBatch(s span, columns []ResultColumn, vectors []*columnVector, fn func(b batch) error) error {
+	for start := s.start; start < s.end; start += batchSize {
+		end := start + batchSize
+		if end > s.end {
+			end = s.end
+		}
+
+		if err := fn(batch{columns: columns, vectors: vectors, start: start, end: end}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// vectorizable reports whether an expression over columns can be
+// evaluated a batch at a time.
+func vectorizable(e expression, columns []ResultColumn) bool {
+	switch e.kind {
+	case literalKind:
+		if e.literal.Kind == IdentifierKind {
+			// Errors are left for row at a time evaluation to report
+			i, err := resolveColumn(e, columns)
+			return err == nil && unboxed(columns[i].Type)
+		}
+
+		if e.typ != nil {
+			return false
+		}
+
+		_, typ, err := literalCell(e.literal)
+		return err == nil && unboxed(typ)
+	case binaryKind:
+		return vectorizable(e.binary.a, columns) && vectorizable(e.binary.b, columns)
+	}
+
+	return false
+}
+
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// evaluateBatch evaluates an expression for every row of a batch,
+// following the same rules as evaluateCell.
+func evaluateBatch(e expression, b batch) (*vector, error) {
+	if e.kind == binaryKind {
+		return evaluateBinaryBatch(*e.binary, b)
+	}
+
+	if e.kind != literalKind {
+		return nil, ErrInvalidSelectItem
+	}
+
+	n := b.end - b.start
+	if e.literal.Kind == IdentifierKind {
+		i, err := resolveColumn(e, b.columns)
+		if err != nil {
+			return nil, err
+		}
+
+		cv := b.vectors[i]
+		var nulls []bool
+		if cv.nulls != nil {
+			nulls = cv.nulls[b.start:b.end]
+		}
+
+		if cv.typ != TextType {
+			return &vector{typ: cv.typ, ints: cv.ints[b.start:b.end], nulls: nulls}, nil
+		}
+
+		texts := make([]string, n)
+		for j, code := range cv.codes[b.start:b.end] {
+			texts[j] = cv.dictionary[code]
+		}
+
+		return &vector{typ: TextType, texts: texts, nulls: nulls}, nil
+	}
+
+	// Constants are repeated for every row
+	cell, _, err := literalCell(e.literal)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.literal.Kind == StringKind {
+		texts := make([]string, n)
+		for j := range texts {
+			texts[j] = cell.AsText()
+		}
+
+		return &vector{typ: TextType, texts: texts}, nil
+	}
+
+	typ, value := IntType, int32(0)
+	if e.literal.Kind == KeywordKind {
+		typ, value = BoolType, boolToInt(cell.AsBool())
+	} else {
+		value = cell.AsInt()
+	}
+
+	ints := make([]int32, n)
+	for j := range ints {
+		ints[j] = value
+	}
+
+	return &vector{typ: typ, ints: ints}, nil
+}
+
+// coerceLiteralVector converts v, the repeated value of e, to integers
+// if e is a string literal compared with them, as coerceLiteral does.
+func coerceLiteralVector(e expression, v *vector, other ColumnType) (*vector, error) {
+	if v.typ != TextType || other != IntType || !isStringLiteral(e) {
+		return v, nil
+	}
+
+	cell, _, err := coerceLiteral(e, MemoryCell(e.literal.Value), TextType, other)
+	if err != nil {
+		return nil, err
+	}
+
+	ints := make([]int32, len(v.texts))
+	for i := range ints {
+		ints[i] = cell.AsInt()
+	}
+
+	return &vector{typ: IntType, ints: ints}, nil
+}
+
+func evaluateBinaryBatch(exp binaryExpression, b batch) (*vector, error) {
+	x, err := evaluateBatch(exp.a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := evaluateBatch(exp.b, b)
+	if err != nil {
+		return nil, err
+	}
+
+	n := b.end - b.start
+	result := &vector{typ: IntType, ints: make([]int32, n)}
+	out := result.ints
+
+	switch exp.op.Kind {
+	case KeywordKind:
+		switch Keyword(exp.op.Value) {
+		case AndKeyword, OrKeyword:
+			if x.typ != BoolType || y.typ != BoolType {
+				return nil, ErrInvalidOperands
+			}
+
+			result.typ = BoolType
+
+			// An unknown operand doesn't matter if the other one
+			// decides the result, as in evaluateBinaryCell
+			decisive := Keyword(exp.op.Value) == OrKeyword
+			for i := range out {
+				switch {
+				case (!x.null(i) && (x.ints[i] != 0) == decisive) || (!y.null(i) && (y.ints[i] != 0) == decisive):
+					out[i] = boolToInt(decisive)
+				case x.null(i) || y.null(i):
+					if result.nulls == nil {
+						result.nulls = make([]bool, n)
+					}
+
+					result.nulls[i] = true
+				default:
+					out[i] = boolToInt(!decisive)
+				}
+			}
+
+			return result, nil
+		}
+	case SymbolKind:
+		switch op := Symbol(exp.op.Value); op {
+		case EqSymbol, NeqSymbol, BangEqSymbol, LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+			if x, err = coerceLiteralVector(exp.a, x, y.typ); err != nil {
+				return nil, err
+			}
+
+			if y, err = coerceLiteralVector(exp.b, y, x.typ); err != nil {
+				return nil, err
+			}
+
+			if x.typ != y.typ {
+				return nil, ErrInvalidOperands
+			}
+
+			result.typ = BoolType
+			result.nulls = eitherNull(x, y, n)
+			for i := range out {
+				if result.null(i) {
+					continue
+				}
+
+				var cmp int
+				if x.typ != TextType {
+					cmp = compareInts(x.ints[i], y.ints[i])
+				} else {
+					cmp = strings.Compare(x.texts[i], y.texts[i])
+				}
+
+				out[i] = boolToInt(comparisonHolds(op, cmp))
+			}
+
+			return result, nil
+		case PlusSymbol, MinusSymbol, AsteriskSymbol, SlashSymbol, PercentSymbol:
+			if x.typ != IntType || y.typ != IntType {
+				return nil, ErrInvalidOperands
+			}
+
+			// Results are worked out in 64 bits, where they can't
+			// overflow, and checked to fit back in 32
+			result.nulls = eitherNull(x, y, n)
+			for i := range out {
+				if result.null(i) {
+					continue
+				}
+
+				a, b := int64(x.ints[i]), int64(y.ints[i])
+				var r int64
+				switch op {
+				case PlusSymbol:
+					r = a + b
+				case MinusSymbol:
+					r = a - b
+				case AsteriskSymbol:
+					r = a * b
+				default:
+					if b == 0 {
+						return nil, ErrDivisionByZero
+					}
+
+					if op == SlashSymbol {
+						r = a / b
+					} else {
+						r = a % b
+					}
+				}
+
+				if r != int64(int32(r)) {
+					return nil, ErrNumericOverflow
+				}
+
+				out[i] = int32(r)
+			}
+
+			return result, nil
+		case LikeSymbol, NotLikeSymbol, IlikeSymbol, NotIlikeSymbol, RegexSymbol, NotRegexSymbol, IregexSymbol, NotIregexSymbol:
+			if x.typ != TextType || y.typ != TextType {
+				return nil, ErrInvalidOperands
+			}
+
+			result.typ = BoolType
+			result.nulls = eitherNull(x, y, n)
+			for i := range out {
+				if result.null(i) {
+					continue
+				}
+
+				matched, err := matchPattern(op, x.texts[i], y.texts[i])
+				if err != nil {
+					return nil, err
+				}
+
+				out[i] = boolToInt(matched)
+			}
+
+			return result, nil
+		case ConcatSymbol:
+			if x.typ != TextType || y.typ != TextType {
+				return nil, ErrInvalidOperands
+			}
+
+			texts := make([]string, len(out))
+			for i := range texts {
+				texts[i] = x.texts[i] + y.texts[i]
+			}
+
+			return &vector{typ: TextType, texts: texts, nulls: eitherNull(x, y, n)}, nil
+		}
+	}
+
+	return nil, ErrInvalidOperands
+}
+
+// selectRows returns the positions, relative to the start of the
+// batch, of the rows a predicate holds for.
+func selectRows(predicate expression, b batch) ([]int, error) {
+	v, err := evaluateBatch(predicate, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.typ != BoolType {
+		return nil, ErrInvalidOperands
+	}
+
+	selected := []int{}
+	for i, value := range v.ints {
+		if value != 0 && !v.null(i) {
+			selected = append(selected, i)
+		}
+	}
+
+	return selected, nil
+}
+
+// columnarScan returns the columnar table p scans, with the vectors
+// and descriptions of the columns the scan produces. ok is false if p
+// is not a scan of a columnar table, or if any of exps can't be
+// evaluated over it a batch at a time.
+func (mb *MemoryBackend) columnarScan(p *plan, exps []expression) (t *table, positions []int, vectors []*columnVector, columns []ResultColumn, ok bool) {
+	// A dry run reads nothing, as the row at a time scan does
+	if p == nil || p.kind != scanPlanKind || mb.dry {
+		return nil, nil, nil, nil, false
+	}
+
+	t, ok = mb.tables[p.scan.table.Value]
+	if !ok || !t.columnar() {
+		return nil, nil, nil, nil, false
+	}
+
+	// Errors are left for the row at a time scan to report
+	positions, columns, err := t.pick(p.scan.columns, qualifier(p.scan.table, p.scan.as))
+	if err != nil {
+		return nil, nil, nil, nil, false
+	}
+
+	for _, exp := range exps {
+		if !vectorizable(exp, columns) {
+			return nil, nil, nil, nil, false
+		}
+	}
+
+	for _, position := range positions {
+		vectors = append(vectors, t.vectors[position])
+	}
+
+	if p.analysis != nil {
+		p.analysis.rows = t.rowCount()
+	}
+
+	return t, positions, vectors, columns, true
+}
+
+// executeColumnarFilter evaluates a filter directly over a columnar
+// table a batch at a time, only assembling the rows that pass. ok is
+// false if the filter can't be executed this way.
+func (mb *MemoryBackend) executeColumnarFilter(f *filterPlan) (rel *relation, ok bool, err error) {
+	t, positions, vectors, columns, ok := mb.columnarScan(f.child, []expression{f.predicate})
+	if !ok {
+		return nil, false, nil
+	}
+
+	spans := mb.partition(t.rowCount())
+	kept := make([][][]MemoryCell, len(spans))
+	err = parallel(spans, func(part int, s span) error {
+		rows := [][]MemoryCell{}
+		err := forEachBatch(s, columns, vectors, func(b batch) error {
+			selected, err := selectRows(f.predicate, b)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range selected {
+				rows = append(rows, t.pickRow(b.start+i, positions))
+			}
+
+			return nil
+		})
+
+		kept[part] = rows
+		return err
+	})
+	if err != nil {
+		return nil, true, err
+	}
+
+	rows := [][]MemoryCell{}
+	for _, part := range kept {
+		rows = append(rows, part...)
+	}
+
+	return &relation{columns: columns, rows: rows}, true, nil
+}
+
+// accumulator is the running state of one aggregate of one group,
+// unboxed so that folding a value in doesn't allocate.
+type accumulator struct {
+	seen bool
+	i    int32
+	// sum is summed in 64 bits, which no number of rows held in
+	// memory can overflow
+	sum int64
+	s   string
+}
+
+// batchAggregate folds the values of vectors into accumulators.
+type batchAggregate struct {
+	step   func(acc *accumulator, v *vector, i int)
+	result func(acc accumulator) MemoryCell
+}
+
+func newBatchAggregate(name string, typ ColumnType) batchAggregate {
+	result := func(acc accumulator) MemoryCell {
+		switch {
+		case !acc.seen:
+			return nil
+		case typ == IntType:
+			return intToCell(acc.i)
+		case typ == BoolType:
+			return boolToCell(acc.i != 0)
+		}
+
+		return MemoryCell(acc.s)
+	}
+
+	// extreme keeps the value that sorts first when multiplied by sign
+	extreme := func(sign int) func(acc *accumulator, v *vector, i int) {
+		return func(acc *accumulator, v *vector, i int) {
+			if typ != TextType {
+				if !acc.seen || compareInts(v.ints[i], acc.i)*sign < 0 {
+					acc.i = v.ints[i]
+				}
+			} else if !acc.seen || strings.Compare(v.texts[i], acc.s)*sign < 0 {
+				acc.s = v.texts[i]
+			}
+
+			acc.seen = true
+		}
+	}
+
+	switch name {
+	case "count":
+		return batchAggregate{
+			step: func(acc *accumulator, _ *vector, _ int) {
+				acc.i++
+			},
+			result: func(acc accumulator) MemoryCell {
+				return intToCell(acc.i)
+			},
+		}
+	case "sum":
+		return batchAggregate{
+			step: func(acc *accumulator, v *vector, i int) {
+				acc.sum += int64(v.ints[i])
+				acc.seen = true
+			},
+			result: func(acc accumulator) MemoryCell {
+				if !acc.seen {
+					return nil
+				}
+
+				cell, _ := integerToCell(acc.sum, BigIntType)
+				return cell
+			},
+		}
+	case "min":
+		return batchAggregate{step: extreme(1), result: result}
+	}
+
+	return batchAggregate{step: extreme(-1), result: result}
+}
+
+// appendKey appends the value of a row of a vector to a group key,
+// encoded as encodeKey would encode its cell.
+func appendKey(key []byte, v *vector, i int) []byte {
+	if v.null(i) {
+		return binary.BigEndian.AppendUint32(key, 0)
+	}
+
+	switch v.typ {
+	case IntType:
+		key = binary.BigEndian.AppendUint32(key, 4)
+		return binary.BigEndian.AppendUint32(key, uint32(v.ints[i]))
+	case BoolType:
+		key = binary.BigEndian.AppendUint32(key, 1)
+		return append(key, byte(v.ints[i]))
+	}
+
+	key = binary.BigEndian.AppendUint32(key, uint32(len(v.texts[i])))
+	return append(key, v.texts[i]...)
+}
+
+// executeColumnarAggregate evaluates an aggregation directly over a
+// columnar table, or a filter of one, a batch at a time. ok is false
+// if the aggregation can't be executed this way.
+func (mb *MemoryBackend) executeColumnarAggregate(a *aggregatePlan) (rel *relation, ok bool, err error) {
+	scan, filter := a.child, (*plan)(nil)
+	exps := append([]expression{}, a.groupBy...)
+	if scan != nil && scan.kind == filterPlanKind {
+		scan, filter = scan.filter.child, scan
+		exps = append(exps, filter.filter.predicate)
+	}
+
+	for _, aggregate := range a.aggregates {
+		exps = append(exps, aggregate.function.args...)
+	}
+
+	t, _, vectors, input, ok := mb.columnarScan(scan, exps)
+	if !ok {
+		return nil, false, nil
+	}
+
+	columns, functions, types, err := aggregateColumns(a, input)
+	if err != nil {
+		return nil, true, err
+	}
+
+	aggregates := []batchAggregate{}
+	for i, aggregate := range a.aggregates {
+		aggregates = append(aggregates, newBatchAggregate(aggregate.function.name.Value, types[i]))
+	}
+
+	spans := mb.partition(t.rowCount())
+	parts := make([]*aggregateGroups, len(spans))
+	selected := make([]int, len(spans))
+	err = parallel(spans, func(part int, s span) error {
+		groups := newAggregateGroups(keyTypes(columns, a))
+		accumulators := [][]accumulator{}
+		var key []byte
+
+		err := forEachBatch(s, input, vectors, func(b batch) error {
+			var rows []int
+			if filter != nil {
+				var err error
+				rows, err = selectRows(filter.filter.predicate, b)
+				if err != nil {
+					return err
+				}
+			} else {
+				for i := 0; i < b.end-b.start; i++ {
+					rows = append(rows, i)
+				}
+			}
+			selected[part] += len(rows)
+
+			keys := []*vector{}
+			for _, exp := range a.groupBy {
+				v, err := evaluateBatch(exp, b)
+				if err != nil {
+					return err
+				}
+
+				keys = append(keys, v)
+			}
+
+			args := []*vector{}
+			for _, aggregate := range a.aggregates {
+				var v *vector
+				if !aggregate.function.asterisk {
+					var err error
+					v, err = evaluateBatch(aggregate.function.args[0], b)
+					if err != nil {
+						return err
+					}
+				}
+
+				args = append(args, v)
+			}
+
+			for _, i := range rows {
+				key = key[:0]
+				for _, v := range keys {
+					key = appendKey(key, v, i)
+				}
+
+				g, ok := groups.index[string(key)]
+				if !ok {
+					cells := []MemoryCell{}
+					for _, v := range keys {
+						cells = append(cells, v.cell(i))
+					}
+
+					groups.group(cells, functions)
+					g = len(groups.keys) - 1
+					accumulators = append(accumulators, make([]accumulator, len(aggregates)))
+				}
+
+				// Aggregates leave out unknown values, other than
+				// count(*), which has no argument
+				for j, aggregate := range aggregates {
+					if args[j] != nil && args[j].null(i) {
+						continue
+					}
+
+					aggregate.step(&accumulators[g][j], args[j], i)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for g, states := range groups.states {
+			for j, aggregate := range aggregates {
+				states[j] = aggregate.result(accumulators[g][j])
+			}
+		}
+
+		parts[part] = groups
+		return nil
+	})
+	if err != nil {
+		return nil, true, err
+	}
+
+	if filter != nil && filter.analysis != nil {
+		filter.analysis.rows = 0
+		for _, n := range selected {
+			filter.analysis.rows += n
+		}
+	}
+
+	groups := parts[0]
+	for _, part := range parts[1:] {
+		if err := groups.merge(part, functions, types); err != nil {
+			return nil, true, err
+		}
+	}
+
+	return &relation{columns: columns, rows: aggregateRows(a, groups, functions)}, true, nil
+}
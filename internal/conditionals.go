@@ -0,0 +1,174 @@
+package ashudb
+
+// CASE, COALESCE, NULLIF, GREATEST and LEAST pick one of the values of
+// their arguments. CASE and COALESCE only evaluate as many arguments
+// as it takes to pick one, so the type of their result is worked out
+// without running the others.
+
+// isConditionalFunction reports whether name is a function picking
+// one of the values of its arguments.
+func isConditionalFunction(name string) bool {
+	switch name {
+	case "coalesce", "nullif", "greatest", "least":
+		return true
+	}
+
+	return false
+}
+
+// reconcileTypes returns the type the values of exps are all converted
+// to. Their types are worked out without evaluating any of them, so
+// nothing in a branch that isn't taken runs.
+func reconcileTypes(exps []expression, columns []ResultColumn) (ColumnType, error) {
+	var typ ColumnType
+	for i, exp := range exps {
+		col, err := describe(exp, columns)
+		if err != nil {
+			return 0, err
+		}
+
+		if i == 0 {
+			typ = col.Type
+			continue
+		}
+
+		var ok bool
+		typ, ok = commonType(typ, col.Type)
+		if !ok {
+			return 0, ErrBranchTypes
+		}
+	}
+
+	return typ, nil
+}
+
+// evaluateResultCell evaluates exp, converting its value to the type
+// of result.
+func evaluateResultCell(exp expression, result ResultColumn, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	cell, col, err := evaluateCell(exp, columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	cell, err = convertCell(cell, col.Type, result.Type)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	return cell, result, nil
+}
+
+// evaluateCaseCell evaluates the conditions of a CASE in turn, and
+// then the result of the first that holds, or else the ELSE result.
+// Without an ELSE the result is unknown if none holds.
+func evaluateCaseCell(exp functionExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	var conditions, results []expression
+	args := exp.args
+	for ; len(args) >= 2; args = args[2:] {
+		conditions = append(conditions, args[0])
+		results = append(results, args[1])
+	}
+
+	// What's left is the ELSE result, if there is one
+	results = append(results, args...)
+
+	typ, err := reconcileTypes(results, columns)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	result := ResultColumn{Type: typ, Name: "?column?"}
+	for i, condition := range conditions {
+		cell, col, err := evaluateCell(condition, columns, row)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		if col.Type != BoolType {
+			return nil, ResultColumn{}, ErrInvalidOperands
+		}
+
+		if cell != nil && cell.AsBool() {
+			return evaluateResultCell(results[i], result, columns, row)
+		}
+	}
+
+	if len(results) > len(conditions) {
+		return evaluateResultCell(results[len(conditions)], result, columns, row)
+	}
+
+	return nil, result, nil
+}
+
+// evaluateConditionalCell evaluates COALESCE, the first of its
+// arguments that isn't unknown; NULLIF, unknown if its arguments are
+// equal and otherwise the first; and GREATEST and LEAST, the largest
+// and smallest of their arguments that aren't unknown.
+func evaluateConditionalCell(exp functionExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	if exp.asterisk || len(exp.args) == 0 {
+		return nil, ResultColumn{}, ErrInvalidArguments
+	}
+
+	if exp.name.Value == "nullif" {
+		if len(exp.args) != 2 {
+			return nil, ResultColumn{}, ErrInvalidArguments
+		}
+
+		a, aCol, err := evaluateCell(exp.args[0], columns, row)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		b, bCol, err := evaluateCell(exp.args[1], columns, row)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		result := ResultColumn{Type: aCol.Type, Name: "?column?"}
+		x, y, typ, err := promoteOperands(a, b, aCol.Type, bCol.Type)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		if x != nil && y != nil && compareCells(x, y, typ) == 0 {
+			return nil, result, nil
+		}
+
+		return a, result, nil
+	}
+
+	typ, err := reconcileTypes(exp.args, columns)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	result := ResultColumn{Type: typ, Name: "?column?"}
+
+	var picked MemoryCell
+	for _, arg := range exp.args {
+		cell, _, err := evaluateResultCell(arg, result, columns, row)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		if cell == nil {
+			continue
+		}
+
+		if exp.name.Value == "coalesce" {
+			return cell, result, nil
+		}
+
+		if picked == nil {
+			picked = cell
+			continue
+		}
+
+		cmp := compareCells(cell, picked, typ)
+		if (exp.name.Value == "greatest" && cmp > 0) || (exp.name.Value == "least" && cmp < 0) {
+			picked = cell
+		}
+	}
+
+	return picked, result, nil
+}
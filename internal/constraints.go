@@ -0,0 +1,347 @@
+package ashudb
+
+import "slices"
+
+// uniqueConstraint is a PRIMARY KEY or UNIQUE constraint: no two rows
+// may hold equal values in its columns. Rows with an unknown value in
+// any of them never conflict, and a primary key allows none.
+type uniqueConstraint struct {
+	columns    []int
+	primaryKey bool
+	// rows maps the encoded values of the columns to the position of
+	// the row holding them
+	rows map[string]int
+}
+
+// newUniqueConstraint resolves the columns of a constraint on t.
+func (t *table) newUniqueConstraint(tc *tableConstraint) (*uniqueConstraint, error) {
+	columns, err := t.targets(&tc.columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uniqueConstraint{
+		columns:    columns,
+		primaryKey: tc.primaryKey,
+		rows:       map[string]int{},
+	}, nil
+}
+
+// key encodes the values of row in the constraint's columns, false if
+// any is unknown.
+func (uc *uniqueConstraint) key(t *table, row []MemoryCell) (string, bool) {
+	cells := []MemoryCell{}
+	types := []ColumnType{}
+	for _, i := range uc.columns {
+		if row[i] == nil {
+			return "", false
+		}
+
+		cells = append(cells, row[i])
+		types = append(types, t.columnTypes[i])
+	}
+
+	return hashKey(cells, types), true
+}
+
+// arbiters returns the positions of the constraints rows conflicting
+// on are handled by ON CONFLICT: the one on exactly columns, or every
+// constraint if columns is nil.
+func (t *table) arbiters(columns *[]Token) ([]int, error) {
+	if columns == nil {
+		arbiters := []int{}
+		for i := range t.constraints {
+			arbiters = append(arbiters, i)
+		}
+
+		return arbiters, nil
+	}
+
+	targets, err := t.targets(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, uc := range t.constraints {
+		if len(uc.columns) == len(targets) && !slices.ContainsFunc(targets, func(column int) bool {
+			return !slices.Contains(uc.columns, column)
+		}) {
+			return []int{i}, nil
+		}
+	}
+
+	return nil, ErrNoConflictConstraint
+}
+
+// changes are the rows an INSERT adds and updates, worked out in full
+// before the table is touched so that a failure leaves it unchanged.
+type changes struct {
+	t       *table
+	inserts [][]MemoryCell
+	// updates maps the positions of the rows updated to their new
+	// values
+	updates map[int][]MemoryCell
+	// written lists the rows inserted and updated, in order
+	written [][]MemoryCell
+	// added and removed hold, for every constraint, the keys the
+	// changes take, with the positions of the rows taking them, and
+	// the keys they free
+	added   []map[string]int
+	removed []map[string]bool
+}
+
+func (t *table) newChanges() *changes {
+	c := &changes{t: t, updates: map[int][]MemoryCell{}}
+	for range t.constraints {
+		c.added = append(c.added, map[string]int{})
+		c.removed = append(c.removed, map[string]bool{})
+	}
+
+	return c
+}
+
+// conflict returns the position of the row, other than the one at
+// self, conflicting with row on the i'th constraint once the changes
+// are made.
+func (c *changes) conflict(i int, row []MemoryCell, self int) (int, bool) {
+	key, ok := c.t.constraints[i].key(c.t, row)
+	if !ok {
+		return 0, false
+	}
+
+	position, ok := c.added[i][key]
+	if !ok && !c.removed[i][key] {
+		position, ok = c.t.constraints[i].rows[key]
+	}
+
+	return position, ok && position != self
+}
+
+// check checks that row can be stored at self, -1 for a new row,
+// without violating a constraint.
+func (c *changes) check(row []MemoryCell, self int) error {
+	for i, uc := range c.t.constraints {
+		if uc.primaryKey {
+			for _, column := range uc.columns {
+				if row[column] == nil {
+					return ErrNullViolation
+				}
+			}
+		}
+
+		if _, ok := c.conflict(i, row, self); ok {
+			return ErrUniqueViolation
+		}
+	}
+
+	return c.t.check(row)
+}
+
+// take records the row at position taking its keys, freeing those of
+// its old values if it had any.
+func (c *changes) take(position int, row, old []MemoryCell) {
+	for i, uc := range c.t.constraints {
+		if old != nil {
+			if key, ok := uc.key(c.t, old); ok {
+				delete(c.added[i], key)
+				c.removed[i][key] = true
+			}
+		}
+
+		if key, ok := uc.key(c.t, row); ok {
+			c.added[i][key] = position
+		}
+	}
+}
+
+func (c *changes) insert(row []MemoryCell) error {
+	if err := c.check(row, -1); err != nil {
+		return err
+	}
+
+	c.take(c.t.rowCount()+len(c.inserts), row, nil)
+	c.inserts = append(c.inserts, row)
+	c.written = append(c.written, row)
+	return nil
+}
+
+// update sets the row at position, conflicting with excluded, as set
+// says. A row can only be updated once, and only if it was there
+// before the INSERT.
+func (c *changes) update(position int, excluded []MemoryCell, set []*assignment, alias string) error {
+	if _, ok := c.updates[position]; ok || position >= c.t.rowCount() {
+		return ErrRowAffectedTwice
+	}
+
+	// The values are computed from the row as it was, with the row
+	// that would have been inserted as `excluded`
+	old := c.t.row(position)
+	columns := append(c.t.resultColumns(alias), c.t.resultColumns("excluded")...)
+	both := append(slices.Clone(old), excluded...)
+
+	row := slices.Clone(old)
+	assigned := []int{}
+	for _, a := range set {
+		i := slices.Index(c.t.columns, a.column.Value)
+		if i == -1 {
+			return ErrColumnDoesNotExist
+		}
+
+		if slices.Contains(assigned, i) {
+			return ErrDuplicateColumn
+		}
+
+		if c.t.generatedAlways[i] {
+			return ErrGeneratedAlways
+		}
+		assigned = append(assigned, i)
+
+		cell, col, err := evaluateCell(a.value, columns, both)
+		if err != nil {
+			return err
+		}
+
+		row[i], err = c.t.coerce(i, cell, col.Type)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.check(row, position); err != nil {
+		return err
+	}
+
+	c.take(position, row, old)
+	c.updates[position] = row
+	c.written = append(c.written, row)
+	return nil
+}
+
+// write works out the changes inserting rows makes, doing what
+// conflict says with rows conflicting with others. Without it a
+// conflict is an error.
+func (t *table) write(rows [][]MemoryCell, alias string, conflict *onConflict) (*changes, error) {
+	c := t.newChanges()
+
+	var arbiters []int
+	if conflict != nil {
+		var err error
+		arbiters, err = t.arbiters(conflict.columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, row := range rows {
+		position, conflicting := 0, false
+		for _, i := range arbiters {
+			if position, conflicting = c.conflict(i, row, -1); conflicting {
+				break
+			}
+		}
+
+		var err error
+		switch {
+		case !conflicting:
+			err = c.insert(row)
+		case conflict.set != nil:
+			err = c.update(position, row, conflict.set, alias)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// apply makes the changes to the table, its indexes and constraints.
+func (t *table) apply(c *changes, alias string) error {
+	// Compute every index key before touching the table so a failure
+	// leaves it unchanged
+	columns := t.resultColumns(alias)
+	indexKey := func(idx *index, row []MemoryCell) (string, error) {
+		cell, col, err := evaluateCell(idx.exp, columns, row)
+		if err != nil {
+			return "", err
+		}
+
+		return string(canonicalCell(cell, col.Type)), nil
+	}
+
+	insertKeys := [][]string{}
+	for _, row := range c.inserts {
+		keys := []string{}
+		for _, idx := range t.indexes {
+			key, err := indexKey(idx, row)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, key)
+		}
+
+		insertKeys = append(insertKeys, keys)
+	}
+
+	// Updated rows move from the keys of their old values to those of
+	// their new ones
+	type move struct{ from, to string }
+	moves := map[int][]move{}
+	for position, row := range c.updates {
+		for _, idx := range t.indexes {
+			from, err := indexKey(idx, t.row(position))
+			if err != nil {
+				return err
+			}
+
+			to, err := indexKey(idx, row)
+			if err != nil {
+				return err
+			}
+
+			moves[position] = append(moves[position], move{from, to})
+		}
+	}
+
+	for j, row := range c.inserts {
+		position := t.rowCount()
+		if err := t.appendRow(row); err != nil {
+			return err
+		}
+
+		for i, idx := range t.indexes {
+			idx.rows[insertKeys[j][i]] = append(idx.rows[insertKeys[j][i]], position)
+		}
+	}
+
+	for position, row := range c.updates {
+		t.setRow(position, row)
+		for i, idx := range t.indexes {
+			m := moves[position][i]
+			idx.rows[m.from] = slices.DeleteFunc(idx.rows[m.from], func(p int) bool {
+				return p == position
+			})
+			if len(idx.rows[m.from]) == 0 {
+				delete(idx.rows, m.from)
+			}
+
+			// Keep the positions in order
+			at, _ := slices.BinarySearch(idx.rows[m.to], position)
+			idx.rows[m.to] = slices.Insert(idx.rows[m.to], at, position)
+		}
+	}
+
+	for i, uc := range t.constraints {
+		for key := range c.removed[i] {
+			delete(uc.rows, key)
+		}
+
+		for key, position := range c.added[i] {
+			uc.rows[key] = position
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package ashudb
+
+import "sync"
+
+// maxRecursion bounds how many times the recursive term of a common
+// table expression runs, so that a query that never reaches a fixpoint
+// fails instead of running forever.
+const maxRecursion = 1000
+
+// cteScope is the common table expressions a query can refer to,
+// innermost first.
+type cteScope struct {
+	cte    *boundCTE
+	parent *cteScope
+}
+
+// lookup returns the common table expression named name, nil if there
+// is none.
+func (s *cteScope) lookup(name string) *boundCTE {
+	for ; s != nil; s = s.parent {
+		if s.cte.cte.name.Value == name {
+			return s.cte
+		}
+	}
+
+	return nil
+}
+
+// boundCTE is a common table expression bound to the backend it runs
+// against. Its rows are materialized the first time they're read, and
+// every reference to it reads the same rows.
+type boundCTE struct {
+	mb  *MemoryBackend
+	cte *commonTableExpression
+	// ctes is the scope the expression's query runs in
+	ctes *cteScope
+
+	once   sync.Once
+	err    error
+	result *relation
+
+	// self is what the recursive term of a recursive expression refers
+	// to itself as. Its working rows are those found by the last run.
+	self       *boundCTE
+	working    *relation
+	referenced bool
+}
+
+// columnNames returns the names of the expression's columns, or false
+// if they can't be known from the catalog.
+func (b *boundCTE) columnNames(c catalog) ([]string, bool) {
+	columns, ok := planColumns(newPlan(b.cte.slct), c)
+	if !ok {
+		return nil, false
+	}
+
+	names := []string{}
+	for i, column := range columns {
+		if b.cte.columns != nil && i < len(*b.cte.columns) {
+			column.name = (*b.cte.columns)[i].Value
+		}
+
+		names = append(names, column.name)
+	}
+
+	return names, true
+}
+
+// rows returns the rows of the expression, running it if it hasn't
+// been yet.
+func (b *boundCTE) rows() (*relation, error) {
+	if b.working != nil {
+		return b.working, nil
+	}
+
+	b.once.Do(func() {
+		b.result, b.err = b.materialize()
+	})
+
+	return b.result, b.err
+}
+
+// nameColumns names the columns the expression's query returns as the
+// expression does.
+func (b *boundCTE) nameColumns(returned []ResultColumn) ([]ResultColumn, error) {
+	columns := []ResultColumn{}
+	for _, col := range returned {
+		columns = append(columns, ResultColumn{Type: col.Type, Name: col.Name})
+	}
+
+	if b.cte.columns != nil {
+		if len(*b.cte.columns) > len(columns) {
+			return nil, ErrCTEColumns
+		}
+
+		for i, name := range *b.cte.columns {
+			columns[i].Name = name.Value
+		}
+	}
+
+	return columns, nil
+}
+
+// describe works out the columns of the expression with a dry run,
+// without materializing it.
+func (b *boundCTE) describe() ([]ResultColumn, error) {
+	if b.working != nil {
+		return b.working.columns, nil
+	}
+
+	returned, err := b.mb.dryRun(b.cte.slct, b.ctes)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.nameColumns(returned)
+}
+
+func (b *boundCTE) materialize() (*relation, error) {
+	rel, err := b.mb.run(b.cte.slct, b.ctes)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := b.nameColumns(rel.columns)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.cte.recursive == nil {
+		return &relation{columns: columns, rows: rel.rows}, nil
+	}
+
+	rows, err := b.iterate(columns, rel.rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &relation{columns: columns, rows: rows}, nil
+}
+
+// iterate runs the recursive term over the rows the last run found,
+// starting from those of the non-recursive term, until no new rows are
+// found. Without UNION ALL rows found before aren't new.
+func (b *boundCTE) iterate(columns []ResultColumn, rows [][]MemoryCell) ([][]MemoryCell, error) {
+	types := []ColumnType{}
+	for _, col := range columns {
+		types = append(types, col.Type)
+	}
+
+	seen := map[string]bool{}
+	unseen := func(rows [][]MemoryCell) [][]MemoryCell {
+		if b.cte.all {
+			return rows
+		}
+
+		return distinctRows(rows, types, seen)
+	}
+
+	result := unseen(rows)
+	working := result
+	scope := &cteScope{cte: b.self, parent: b.ctes}
+	for i := 0; len(working) > 0; i++ {
+		// A recursive term that doesn't refer to its expression finds
+		// the same rows every time
+		if i == 1 && !b.self.referenced {
+			break
+		}
+
+		if i == maxRecursion {
+			return nil, ErrRecursionLimit
+		}
+
+		b.self.working = &relation{columns: columns, rows: working}
+		rel, err := b.mb.run(b.cte.recursive, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := setColumns(columns, rel.columns); err != nil {
+			return nil, err
+		}
+
+		found, err := convertRows(rel, types)
+		if err != nil {
+			return nil, err
+		}
+
+		working = unseen(found)
+		result = append(result, working...)
+	}
+
+	return result, nil
+}
+
+func (mb *MemoryBackend) executeCTEScan(s *cteScanPlan) (*relation, error) {
+	if mb.dry {
+		columns, err := s.cte.describe()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range columns {
+			columns[i].table = qualifier(s.table, s.as)
+		}
+
+		return &relation{columns: columns}, nil
+	}
+
+	rel, err := s.cte.rows()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []ResultColumn{}
+	for _, col := range rel.columns {
+		columns = append(columns, ResultColumn{Type: col.Type, Name: col.Name, table: qualifier(s.table, s.as)})
+	}
+
+	// Other scans of the expression read the same rows
+	rows := append([][]MemoryCell{}, rel.rows...)
+	return &relation{columns: columns, rows: rows}, nil
+}
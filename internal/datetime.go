@@ -0,0 +1,565 @@
+package ashudb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dates are stored as the days since 1970-01-01, in 4 bytes like an
+// INT, and times of day and timestamps as the microseconds since
+// midnight and since 1970-01-01 00:00:00, in 8 bytes like a BIGINT.
+// Everything is in UTC.
+
+const (
+	microsPerSecond = int64(1e6)
+	microsPerMinute = 60 * microsPerSecond
+	microsPerHour   = 60 * microsPerMinute
+	microsPerDay    = 24 * microsPerHour
+	// daysPerMonth is how long a month of an interval counts as when
+	// intervals are compared
+	daysPerMonth = 30
+)
+
+// Interval is a span of time. Months and days are kept apart from the
+// rest because their length depends on when they start: a month added
+// to January 31 ends on the last day of February.
+type Interval struct {
+	Months int32
+	Days   int32
+	Micros int64
+}
+
+func plural(n int64, unit string) string {
+	if n == 1 || n == -1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// formatClock formats microseconds as hours, minutes and seconds, with
+// as many fractional digits as needed.
+func formatClock(micros int64) string {
+	sign := ""
+	if micros < 0 {
+		sign, micros = "-", -micros
+	}
+
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, micros/microsPerHour, micros%microsPerHour/microsPerMinute, micros%microsPerMinute/microsPerSecond)
+	if fraction := micros % microsPerSecond; fraction != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", fraction), "0")
+	}
+
+	return s
+}
+
+// String formats an interval like 1 year 2 mons 3 days 04:05:06.
+func (i Interval) String() string {
+	parts := []string{}
+	if years := int64(i.Months / 12); years != 0 {
+		parts = append(parts, plural(years, "year"))
+	}
+
+	if months := int64(i.Months % 12); months != 0 {
+		parts = append(parts, plural(months, "mon"))
+	}
+
+	if i.Days != 0 {
+		parts = append(parts, plural(int64(i.Days), "day"))
+	}
+
+	if i.Micros != 0 || len(parts) == 0 {
+		parts = append(parts, formatClock(i.Micros))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// total returns the length of an interval in microseconds, counting
+// months as daysPerMonth days.
+func (i Interval) total() *big.Int {
+	days := big.NewInt(int64(i.Months)*daysPerMonth + int64(i.Days))
+	total := new(big.Int).Mul(days, big.NewInt(microsPerDay))
+	return total.Add(total, big.NewInt(i.Micros))
+}
+
+func microsToCell(micros int64) MemoryCell {
+	cell := make(MemoryCell, 8)
+	binary.BigEndian.PutUint64(cell, uint64(micros))
+	return cell
+}
+
+func intervalToCell(i Interval) MemoryCell {
+	cell := make(MemoryCell, 16)
+	binary.BigEndian.PutUint32(cell, uint32(i.Months))
+	binary.BigEndian.PutUint32(cell[4:], uint32(i.Days))
+	binary.BigEndian.PutUint64(cell[8:], uint64(i.Micros))
+	return cell
+}
+
+func parseDate(s string) (int32, error) {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return 0, ErrInvalidDatetime
+	}
+
+	return int32(t.Unix() / (microsPerDay / microsPerSecond)), nil
+}
+
+// parseTime parses a time of day like 12:30, 12:30:00 or 12:30:00.5.
+func parseTime(s string) (int64, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return int64(t.Hour())*microsPerHour + int64(t.Minute())*microsPerMinute + int64(t.Second())*microsPerSecond + int64(t.Nanosecond()/1000), nil
+		}
+	}
+
+	return 0, ErrInvalidDatetime
+}
+
+// parseTimestamp parses a date, optionally followed by a time of day,
+// like 2024-01-01 12:30:00.
+func parseTimestamp(s string) (int64, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t.UnixMicro(), nil
+		}
+	}
+
+	return 0, ErrInvalidDatetime
+}
+
+// intervalUnits are the microseconds, days or months every unit of an
+// interval stands for.
+var intervalUnits = map[string]Interval{
+	"microsecond": {Micros: 1},
+	"millisecond": {Micros: 1000},
+	"second":      {Micros: microsPerSecond},
+	"sec":         {Micros: microsPerSecond},
+	"minute":      {Micros: microsPerMinute},
+	"min":         {Micros: microsPerMinute},
+	"hour":        {Micros: microsPerHour},
+	"day":         {Days: 1},
+	"week":        {Days: 7},
+	"month":       {Months: 1},
+	"mon":         {Months: 1},
+	"year":        {Months: 12},
+}
+
+// parseInterval parses quantities of units, like 1 year 2 months, and
+// a clock, like 04:05:06, in any combination.
+func parseInterval(s string) (Interval, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return Interval{}, ErrInvalidDatetime
+	}
+
+	var months, days, micros int64
+	for i := 0; i < len(fields); i++ {
+		if strings.Contains(fields[i], ":") {
+			clock, err := parseClock(fields[i])
+			if err != nil {
+				return Interval{}, err
+			}
+
+			micros += clock
+			continue
+		}
+
+		n, err := strconv.ParseInt(fields[i], 10, 32)
+		if err != nil || i+1 == len(fields) {
+			return Interval{}, ErrInvalidDatetime
+		}
+
+		i++
+		unit, ok := intervalUnits[strings.TrimSuffix(fields[i], "s")]
+		if !ok {
+			return Interval{}, ErrInvalidDatetime
+		}
+
+		months += n * int64(unit.Months)
+		days += n * int64(unit.Days)
+		micros += n * unit.Micros
+	}
+
+	if months != int64(int32(months)) || days != int64(int32(days)) {
+		return Interval{}, ErrDatetimeOverflow
+	}
+
+	return Interval{Months: int32(months), Days: int32(days), Micros: micros}, nil
+}
+
+// parseClock parses hours and minutes, and optionally seconds, of an
+// interval, which unlike a time of day may be negative or past 24
+// hours.
+func parseClock(s string) (int64, error) {
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, ErrInvalidDatetime
+	}
+
+	hours, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, ErrInvalidDatetime
+	}
+
+	minutes, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || minutes > 59 {
+		return 0, ErrInvalidDatetime
+	}
+
+	var seconds float64
+	if len(parts) == 3 {
+		seconds, err = strconv.ParseFloat(parts[2], 64)
+		if err != nil || seconds < 0 || seconds >= 60 {
+			return 0, ErrInvalidDatetime
+		}
+	}
+
+	micros := int64(hours)*microsPerHour + int64(minutes)*microsPerMinute + int64(math.Round(seconds*float64(microsPerSecond)))
+	return sign * micros, nil
+}
+
+func isDatetime(typ ColumnType) bool {
+	return typ == DateType || typ == TimeType || typ == TimestampType || typ == IntervalType
+}
+
+func isDateOrTimestamp(typ ColumnType) bool {
+	return typ == DateType || typ == TimestampType
+}
+
+// timestampMicros returns the value of a DATE or TIMESTAMP cell as the
+// microseconds since 1970-01-01 00:00:00.
+func timestampMicros(cell MemoryCell, typ ColumnType) int64 {
+	if typ == DateType {
+		return int64(cell.AsInt()) * microsPerDay
+	}
+
+	return cell.AsBigInt()
+}
+
+// floorDiv divides rounding towards negative infinity, so that times
+// before 1970 fall on the day they are in.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+
+	return q
+}
+
+// addInterval adds an interval, times sign, to a timestamp: first the
+// months, keeping the day of the month unless that month is shorter,
+// then the days and finally the rest.
+func addInterval(micros int64, i Interval, sign int64) (int64, error) {
+	day := floorDiv(micros, microsPerDay)
+	clock := micros - day*microsPerDay
+
+	if i.Months != 0 {
+		date := time.Unix(day*(microsPerDay/microsPerSecond), 0).UTC()
+		months := int64(date.Month()-1) + sign*int64(i.Months)
+		year := int64(date.Year()) + floorDiv(months, 12)
+		month := time.Month(months-floorDiv(months, 12)*12) + 1
+
+		// The day after the last day of the month, less one
+		last := time.Date(int(year), month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		dayOfMonth := min(date.Day(), last)
+		day = time.Date(int(year), month, dayOfMonth, 0, 0, 0, 0, time.UTC).Unix() / (microsPerDay / microsPerSecond)
+	}
+
+	day += sign * int64(i.Days)
+	if day > math.MaxInt64/microsPerDay || day < math.MinInt64/microsPerDay {
+		return 0, ErrDatetimeOverflow
+	}
+
+	result, err := integerArithmetic(PlusSymbol, day*microsPerDay, clock, BigIntType)
+	if err != nil {
+		return 0, ErrDatetimeOverflow
+	}
+
+	result, err = integerArithmetic(PlusSymbol, result.AsBigInt(), sign*i.Micros, BigIntType)
+	if err != nil {
+		return 0, ErrDatetimeOverflow
+	}
+
+	return result.AsBigInt(), nil
+}
+
+// scaleInterval multiplies an interval by a number, carrying the
+// fractions of months into days and of days into microseconds.
+func scaleInterval(i Interval, f float64) (Interval, error) {
+	months := float64(i.Months) * f
+	days := float64(i.Days)*f + (months-math.Trunc(months))*daysPerMonth
+	micros := float64(i.Micros)*f + (days-math.Trunc(days))*float64(microsPerDay)
+
+	if math.Abs(months) > math.MaxInt32 || math.Abs(days) > math.MaxInt32 || math.Abs(micros) >= math.MaxInt64 || math.IsNaN(micros) {
+		return Interval{}, ErrDatetimeOverflow
+	}
+
+	return Interval{Months: int32(months), Days: int32(days), Micros: int64(math.Round(micros))}, nil
+}
+
+// datetimeResultType returns the type of an arithmetic operation
+// involving dates, times or intervals. ok is false if the operands are
+// none of these or can't be combined.
+func datetimeResultType(op Symbol, a, b ColumnType) (typ ColumnType, ok bool) {
+	if !isDatetime(a) && !isDatetime(b) {
+		return 0, false
+	}
+
+	switch op {
+	case PlusSymbol:
+		// Addition goes either way round
+		if isDatetime(b) && (!isDatetime(a) || b != IntervalType) {
+			a, b = b, a
+		}
+
+		switch {
+		case a == DateType && isInteger(b):
+			return DateType, true
+		case (a == DateType && b == TimeType) || (a == TimeType && b == DateType):
+			return TimestampType, true
+		}
+
+		fallthrough
+	case MinusSymbol:
+		switch {
+		case a == DateType && isInteger(b):
+			return DateType, true
+		case a == DateType && b == DateType && op == MinusSymbol:
+			return IntType, true
+		case (a == DateType || a == TimestampType) && b == IntervalType:
+			return TimestampType, true
+		case isDateOrTimestamp(a) && isDateOrTimestamp(b) && op == MinusSymbol:
+			// A date less a timestamp, or the other way round, is taken
+			// as midnight on that date
+			return IntervalType, true
+		case a == TimeType && b == IntervalType:
+			return TimeType, true
+		case a == TimeType && b == TimeType && op == MinusSymbol:
+			return IntervalType, true
+		case a == IntervalType && b == IntervalType:
+			return IntervalType, true
+		}
+	case AsteriskSymbol:
+		if a == IntervalType && isNumeric(b) || isNumeric(a) && b == IntervalType {
+			return IntervalType, true
+		}
+	case SlashSymbol:
+		if a == IntervalType && isNumeric(b) {
+			return IntervalType, true
+		}
+	}
+
+	return 0, false
+}
+
+// datetimeArithmetic carries out an operation datetimeResultType
+// allows.
+func datetimeArithmetic(op Symbol, a, b MemoryCell, aType, bType ColumnType) (MemoryCell, error) {
+	sign := int64(1)
+	if op == MinusSymbol {
+		sign = -1
+	}
+
+	// Put the date or time first, as datetimeResultType does
+	if op == PlusSymbol && isDatetime(bType) && (!isDatetime(aType) || bType != IntervalType) || op == AsteriskSymbol && bType == IntervalType {
+		a, b, aType, bType = b, a, bType, aType
+	}
+
+	switch {
+	case aType == DateType && isInteger(bType):
+		days := int64(a.AsInt()) + sign*integerValue(b, bType)
+		if days != int64(int32(days)) {
+			return nil, ErrDatetimeOverflow
+		}
+
+		return intToCell(int32(days)), nil
+	case aType == DateType && bType == DateType:
+		return intToCell(a.AsInt() - b.AsInt()), nil
+	case aType == DateType && bType == TimeType, aType == TimeType && bType == DateType:
+		if aType == TimeType {
+			a, b = b, a
+		}
+
+		return microsToCell(int64(a.AsInt())*microsPerDay + b.AsBigInt()), nil
+	case aType == TimeType && bType == TimeType:
+		return intervalToCell(Interval{Micros: a.AsBigInt() - b.AsBigInt()}), nil
+	case aType == TimeType:
+		// Times of day wrap around midnight
+		micros := (a.AsBigInt() + sign*(b.AsInterval().Micros%microsPerDay)) % microsPerDay
+		if micros < 0 {
+			micros += microsPerDay
+		}
+
+		return microsToCell(micros), nil
+	case isDateOrTimestamp(aType) && isDateOrTimestamp(bType):
+		diff, err := integerArithmetic(MinusSymbol, timestampMicros(a, aType), timestampMicros(b, bType), BigIntType)
+		if err != nil {
+			return nil, ErrDatetimeOverflow
+		}
+
+		// Whole days of the difference are counted as days
+		micros := diff.AsBigInt()
+		return intervalToCell(Interval{Days: int32(micros / microsPerDay), Micros: micros % microsPerDay}), nil
+	case bType == IntervalType && aType != IntervalType:
+		micros, err := addInterval(timestampMicros(a, aType), b.AsInterval(), sign)
+		if err != nil {
+			return nil, err
+		}
+
+		return microsToCell(micros), nil
+	case aType == IntervalType && bType == IntervalType:
+		x, y := a.AsInterval(), b.AsInterval()
+		months := int64(x.Months) + sign*int64(y.Months)
+		days := int64(x.Days) + sign*int64(y.Days)
+		micros, err := integerArithmetic(op, x.Micros, y.Micros, BigIntType)
+		if err != nil || months != int64(int32(months)) || days != int64(int32(days)) {
+			return nil, ErrDatetimeOverflow
+		}
+
+		return intervalToCell(Interval{Months: int32(months), Days: int32(days), Micros: micros.AsBigInt()}), nil
+	}
+
+	// An interval scaled by a number
+	f := numericFloat(b, bType)
+	if op == SlashSymbol {
+		if f == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		f = 1 / f
+	}
+
+	i, err := scaleInterval(a.AsInterval(), f)
+	if err != nil {
+		return nil, err
+	}
+
+	return intervalToCell(i), nil
+}
+
+// extractField returns a field of a date, time, timestamp or interval,
+// as EXTRACT does.
+func extractField(field string, cell MemoryCell, typ ColumnType) (Decimal, error) {
+	whole := func(n int64) (Decimal, error) {
+		return Decimal{Value: n}, nil
+	}
+
+	// Seconds come with their fraction, in microseconds
+	seconds := func(micros int64) (Decimal, error) {
+		return Decimal{Value: micros, Scale: 6}, nil
+	}
+
+	switch typ {
+	case TimeType:
+		micros := cell.AsBigInt()
+		switch field {
+		case "hour":
+			return whole(micros / microsPerHour)
+		case "minute":
+			return whole(micros % microsPerHour / microsPerMinute)
+		case "second":
+			return seconds(micros % microsPerMinute)
+		case "epoch":
+			return seconds(micros)
+		}
+	case IntervalType:
+		i := cell.AsInterval()
+		switch field {
+		case "year":
+			return whole(int64(i.Months / 12))
+		case "month":
+			return whole(int64(i.Months % 12))
+		case "day":
+			return whole(int64(i.Days))
+		case "hour":
+			return whole(i.Micros / microsPerHour)
+		case "minute":
+			return whole(i.Micros % microsPerHour / microsPerMinute)
+		case "second":
+			return seconds(i.Micros % microsPerMinute)
+		case "epoch":
+			// Years count as 365.25 days and other months as 30
+			days := float64(i.Months/12)*365.25 + float64(i.Months%12*daysPerMonth) + float64(i.Days)
+			return makeDecimal(big.NewInt(int64(days*float64(microsPerDay))+i.Micros), 6)
+		}
+	default:
+		micros := timestampMicros(cell, typ)
+		t := time.UnixMicro(micros).UTC()
+		switch field {
+		case "year":
+			return whole(int64(t.Year()))
+		case "quarter":
+			return whole(int64(t.Month()-1)/3 + 1)
+		case "month":
+			return whole(int64(t.Month()))
+		case "week":
+			_, week := t.ISOWeek()
+			return whole(int64(week))
+		case "day":
+			return whole(int64(t.Day()))
+		case "dow":
+			return whole(int64(t.Weekday()))
+		case "doy":
+			return whole(int64(t.YearDay()))
+		case "hour":
+			return whole(int64(t.Hour()))
+		case "minute":
+			return whole(int64(t.Minute()))
+		case "second":
+			return seconds(int64(t.Second())*microsPerSecond + int64(t.Nanosecond()/1000))
+		case "epoch":
+			if typ == DateType {
+				return whole(micros / microsPerSecond)
+			}
+
+			return seconds(micros)
+		}
+	}
+
+	return Decimal{}, ErrInvalidArguments
+}
+
+// truncateTimestamp rounds a timestamp down to the start of the unit
+// it is in, as DATE_TRUNC does. Weeks start on Monday.
+func truncateTimestamp(field string, micros int64) (int64, error) {
+	t := time.UnixMicro(micros).UTC()
+	year, month, day := t.Date()
+
+	var truncated time.Time
+	switch field {
+	case "second":
+		truncated = t.Truncate(time.Second)
+	case "minute":
+		truncated = t.Truncate(time.Minute)
+	case "hour":
+		truncated = time.Date(year, month, day, t.Hour(), 0, 0, 0, time.UTC)
+	case "day":
+		truncated = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	case "week":
+		sinceMonday := (int(t.Weekday()) + 6) % 7
+		truncated = time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, time.UTC)
+	case "month":
+		truncated = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		truncated = time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		truncated = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return 0, ErrInvalidArguments
+	}
+
+	return truncated.UnixMicro(), nil
+}
@@ -0,0 +1,245 @@
+package ashudb
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// scalarFunction describes a function computing a value from the
+// values of its arguments in a single row.
+type scalarFunction struct {
+	// resultType returns the type of the result for arguments of types
+	// args, or an error if the function can't take them
+	resultType func(args []ColumnType) (ColumnType, error)
+	// call computes the result. It is only called once every argument
+	// is known, an unknown argument making the result unknown.
+	call func(args []MemoryCell, types []ColumnType) (MemoryCell, error)
+}
+
+// fieldArgument checks that the first of two arguments names a field,
+// like 'year', and that the second is of one of types.
+func fieldArgument(args []ColumnType, types ...ColumnType) error {
+	if len(args) != 2 || args[0] != TextType {
+		return ErrInvalidArguments
+	}
+
+	for _, typ := range types {
+		if args[1] == typ {
+			return nil
+		}
+	}
+
+	return ErrInvalidArguments
+}
+
+var scalarFunctions = map[string]scalarFunction{
+	"now": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if len(args) != 0 {
+				return 0, ErrInvalidArguments
+			}
+
+			return TimestampType, nil
+		},
+		call: func([]MemoryCell, []ColumnType) (MemoryCell, error) {
+			return microsToCell(time.Now().UnixMicro()), nil
+		},
+	},
+	"extract": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if err := fieldArgument(args, DateType, TimeType, TimestampType, IntervalType); err != nil {
+				return 0, err
+			}
+
+			return DecimalType, nil
+		},
+		call: func(args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			d, err := extractField(args[0].AsText(), args[1], types[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return decimalToCell(d), nil
+		},
+	},
+	"date_trunc": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if err := fieldArgument(args, DateType, TimestampType); err != nil {
+				return 0, err
+			}
+
+			return TimestampType, nil
+		},
+		call: func(args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			micros, err := truncateTimestamp(args[0].AsText(), timestampMicros(args[1], types[1]))
+			if err != nil {
+				return nil, err
+			}
+
+			return microsToCell(micros), nil
+		},
+	},
+	"gen_random_uuid": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if len(args) != 0 {
+				return 0, ErrInvalidArguments
+			}
+
+			return UuidType, nil
+		},
+		call: func([]MemoryCell, []ColumnType) (MemoryCell, error) {
+			u, err := newRandomUUID()
+			if err != nil {
+				return nil, err
+			}
+
+			return MemoryCell(u[:]), nil
+		},
+	},
+	"json_extract": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if err := documentArgument(args, 2); err != nil {
+				return 0, err
+			}
+
+			return JsonType, nil
+		},
+		call: func(args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			doc, err := jsonDocument(args[0], types[0])
+			if err != nil {
+				return nil, err
+			}
+
+			value, ok, err := jsonPath(doc, args[1].AsText())
+			if err != nil || !ok {
+				return nil, err
+			}
+
+			return MemoryCell(value), nil
+		},
+	},
+	"json_array_length": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if err := documentArgument(args, 1); err != nil {
+				return 0, err
+			}
+
+			return IntType, nil
+		},
+		call: func(args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			doc, err := jsonDocument(args[0], types[0])
+			if err != nil {
+				return nil, err
+			}
+
+			var array []json.RawMessage
+			if err := json.Unmarshal(doc, &array); err != nil {
+				return nil, ErrInvalidArguments
+			}
+
+			return intToCell(int32(len(array))), nil
+		},
+	},
+	// json_object_keys returns the keys of an object as a JSON array
+	"json_object_keys": {
+		resultType: func(args []ColumnType) (ColumnType, error) {
+			if err := documentArgument(args, 1); err != nil {
+				return 0, err
+			}
+
+			return JsonType, nil
+		},
+		call: func(args []MemoryCell, types []ColumnType) (MemoryCell, error) {
+			doc, err := jsonDocument(args[0], types[0])
+			if err != nil {
+				return nil, err
+			}
+
+			keys, ok := jsonObjectKeys(doc)
+			if !ok {
+				return nil, ErrInvalidArguments
+			}
+
+			return json.Marshal(keys)
+		},
+	},
+}
+
+// documentArgument checks that the first of count arguments is a JSON
+// document and that any other is text.
+func documentArgument(args []ColumnType, count int) error {
+	if len(args) != count || !isJSONDocument(args[0]) {
+		return ErrInvalidArguments
+	}
+
+	for _, typ := range args[1:] {
+		if typ != TextType {
+			return ErrInvalidArguments
+		}
+	}
+
+	return nil
+}
+
+// evaluateFunctionCell evaluates a call to a scalar function.
+func evaluateFunctionCell(exp functionExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	if exp.cast != nil {
+		return evaluateCastCell(exp, columns, row)
+	}
+
+	if exp.isUnary() {
+		return evaluateUnaryCell(exp, columns, row)
+	}
+
+	if exp.isCase() {
+		return evaluateCaseCell(exp, columns, row)
+	}
+
+	if isConditionalFunction(exp.name.Value) {
+		return evaluateConditionalCell(exp, columns, row)
+	}
+
+	if isSequenceFunction(exp.name.Value) {
+		return evaluateSequenceCell(exp, columns, row)
+	}
+
+	function, ok := scalarFunctions[exp.name.Value]
+	if !ok {
+		return nil, ResultColumn{}, ErrFunctionDoesNotExist
+	}
+
+	if exp.asterisk {
+		return nil, ResultColumn{}, ErrInvalidArguments
+	}
+
+	args := []MemoryCell{}
+	types := []ColumnType{}
+	unknown := false
+	for _, arg := range exp.args {
+		cell, col, err := evaluateCell(arg, columns, row)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		args = append(args, cell)
+		types = append(types, col.Type)
+		unknown = unknown || cell == nil
+	}
+
+	typ, err := function.resultType(types)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	result := ResultColumn{Type: typ, Name: "?column?"}
+	if unknown {
+		return nil, result, nil
+	}
+
+	cell, err := function.call(args, types)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	return cell, result, nil
+}
@@ -0,0 +1,179 @@
+package ashudb
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// JSON values are stored as the text they were written as, checked to
+// be valid JSON.
+
+func jsonToCell(s string) (MemoryCell, error) {
+	if !json.Valid([]byte(s)) {
+		return nil, ErrInvalidJSON
+	}
+
+	return MemoryCell(s), nil
+}
+
+// isJSONDocument reports whether values of type typ can be read as
+// JSON documents: JSON itself, or text holding JSON.
+func isJSONDocument(typ ColumnType) bool {
+	return typ == JsonType || typ == TextType
+}
+
+// jsonDocument returns a cell of type typ as a JSON document, checking
+// text holds JSON.
+func jsonDocument(cell MemoryCell, typ ColumnType) ([]byte, error) {
+	if typ == TextType {
+		return jsonToCell(cell.AsText())
+	}
+
+	return cell, nil
+}
+
+// jsonMember returns the member of an object with key. ok is false if
+// doc isn't an object or has no such member.
+func jsonMember(doc []byte, key string) (member []byte, ok bool) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &object); err != nil {
+		return nil, false
+	}
+
+	member, ok = object[key]
+	return member, ok
+}
+
+// jsonElement returns the i'th element of an array, counting from the
+// end if i is negative. ok is false if doc isn't an array or is too
+// short.
+func jsonElement(doc []byte, i int64) (element []byte, ok bool) {
+	var array []json.RawMessage
+	if err := json.Unmarshal(doc, &array); err != nil {
+		return nil, false
+	}
+
+	if i < 0 {
+		i += int64(len(array))
+	}
+
+	if i < 0 || i >= int64(len(array)) {
+		return nil, false
+	}
+
+	return array[i], true
+}
+
+// jsonText returns a JSON value as text: strings without their quotes
+// and escapes, null as unknown and anything else as written.
+func jsonText(value []byte) MemoryCell {
+	if bytes.Equal(value, []byte("null")) {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return MemoryCell(s)
+	}
+
+	return MemoryCell(value)
+}
+
+// jsonField applies -> or, if asText, ->> to a document: the member
+// with key if key is text, or the element at key if it is an integer.
+// A missing member or element is unknown.
+func jsonField(doc []byte, key MemoryCell, keyType ColumnType, asText bool) MemoryCell {
+	var value []byte
+	var ok bool
+	if keyType == TextType {
+		value, ok = jsonMember(doc, key.AsText())
+	} else {
+		value, ok = jsonElement(doc, integerValue(key, keyType))
+	}
+
+	if !ok {
+		return nil
+	}
+
+	if asText {
+		return jsonText(value)
+	}
+
+	return MemoryCell(value)
+}
+
+// jsonPath follows a path like $.items[0].name through a document. ok
+// is false if the path leads nowhere.
+func jsonPath(doc []byte, path string) (value []byte, ok bool, err error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, false, ErrInvalidArguments
+	}
+
+	value, rest := doc, path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+
+			if end == 1 {
+				return nil, false, ErrInvalidArguments
+			}
+
+			value, ok = jsonMember(value, rest[1:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, false, ErrInvalidArguments
+			}
+
+			i, err := strconv.ParseInt(rest[1:end], 10, 64)
+			if err != nil {
+				return nil, false, ErrInvalidArguments
+			}
+
+			value, ok = jsonElement(value, i)
+			rest = rest[end+1:]
+		default:
+			return nil, false, ErrInvalidArguments
+		}
+
+		if !ok {
+			return nil, false, nil
+		}
+	}
+
+	return value, true, nil
+}
+
+// jsonObjectKeys returns the keys of an object in the order they are
+// written in. ok is false if doc isn't an object.
+func jsonObjectKeys(doc []byte) (keys []string, ok bool) {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return nil, false
+	}
+
+	keys = []string{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, false
+		}
+
+		// Skip the member's value
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, false
+		}
+
+		keys = append(keys, key.(string))
+	}
+
+	return keys, true
+}
@@ -13,17 +13,85 @@ type Location struct {
 type Keyword string
 
 const (
-	SelectKeyword Keyword = "select"
-	FromKeyword   Keyword = "from"
-	AsKeyword     Keyword = "as"
-	TableKeyword  Keyword = "table"
-	CreateKeyword Keyword = "create"
-	WhereKeyword  Keyword = "where"
-	InsertKeyword Keyword = "insert"
-	IntoKeyword   Keyword = "into"
-	ValuesKeyword Keyword = "values"
-	IntKeyword    Keyword = "int"
-	TextKeyword   Keyword = "text"
+	SelectKeyword    Keyword = "select"
+	FromKeyword      Keyword = "from"
+	AsKeyword        Keyword = "as"
+	TableKeyword     Keyword = "table"
+	CreateKeyword    Keyword = "create"
+	WhereKeyword     Keyword = "where"
+	InsertKeyword    Keyword = "insert"
+	IntoKeyword      Keyword = "into"
+	ValuesKeyword    Keyword = "values"
+	IntKeyword       Keyword = "int"
+	SmallIntKeyword  Keyword = "smallint"
+	BigIntKeyword    Keyword = "bigint"
+	TextKeyword      Keyword = "text"
+	ByteaKeyword     Keyword = "bytea"
+	BlobKeyword      Keyword = "blob"
+	JsonKeyword      Keyword = "json"
+	UuidKeyword      Keyword = "uuid"
+	DefaultKeyword   Keyword = "default"
+	CastKeyword      Keyword = "cast"
+	VarcharKeyword   Keyword = "varchar"
+	CharKeyword      Keyword = "char"
+	BooleanKeyword   Keyword = "boolean"
+	RealKeyword      Keyword = "real"
+	DoubleKeyword    Keyword = "double"
+	PrecisionKeyword Keyword = "precision"
+	DecimalKeyword   Keyword = "decimal"
+	NumericKeyword   Keyword = "numeric"
+	DateKeyword      Keyword = "date"
+	TimeKeyword      Keyword = "time"
+	TimestampKeyword Keyword = "timestamp"
+	IntervalKeyword  Keyword = "interval"
+	TrueKeyword      Keyword = "true"
+	FalseKeyword     Keyword = "false"
+	AndKeyword       Keyword = "and"
+	OrKeyword        Keyword = "or"
+	IndexKeyword     Keyword = "index"
+	OnKeyword        Keyword = "on"
+	ExplainKeyword   Keyword = "explain"
+	AnalyzeKeyword   Keyword = "analyze"
+	JoinKeyword      Keyword = "join"
+	InnerKeyword     Keyword = "inner"
+	GroupKeyword     Keyword = "group"
+	ByKeyword        Keyword = "by"
+	UsingKeyword     Keyword = "using"
+	ReturningKeyword Keyword = "returning"
+	PrimaryKeyword   Keyword = "primary"
+	KeyKeyword       Keyword = "key"
+	UniqueKeyword    Keyword = "unique"
+	ConflictKeyword  Keyword = "conflict"
+	DoKeyword        Keyword = "do"
+	NothingKeyword   Keyword = "nothing"
+	UpdateKeyword    Keyword = "update"
+	SetKeyword       Keyword = "set"
+	SequenceKeyword  Keyword = "sequence"
+	StartKeyword     Keyword = "start"
+	WithKeyword      Keyword = "with"
+	IncrementKeyword Keyword = "increment"
+	SerialKeyword    Keyword = "serial"
+	BigSerialKeyword Keyword = "bigserial"
+	GeneratedKeyword Keyword = "generated"
+	AlwaysKeyword    Keyword = "always"
+	IdentityKeyword  Keyword = "identity"
+	ExistsKeyword    Keyword = "exists"
+	InKeyword        Keyword = "in"
+	NotKeyword       Keyword = "not"
+	RecursiveKeyword Keyword = "recursive"
+	UnionKeyword     Keyword = "union"
+	AllKeyword       Keyword = "all"
+	IntersectKeyword Keyword = "intersect"
+	ExceptKeyword    Keyword = "except"
+	DistinctKeyword  Keyword = "distinct"
+	BetweenKeyword   Keyword = "between"
+	LikeKeyword      Keyword = "like"
+	IlikeKeyword     Keyword = "ilike"
+	CaseKeyword      Keyword = "case"
+	WhenKeyword      Keyword = "when"
+	ThenKeyword      Keyword = "then"
+	ElseKeyword      Keyword = "else"
+	EndKeyword       Keyword = "end"
 )
 
 type Symbol string
@@ -34,6 +102,32 @@ const (
 	CommaSymbol      Symbol = ","
 	LeftParenSymbol  Symbol = "("
 	RightParenSymbol Symbol = ")"
+	EqSymbol         Symbol = "="
+	NeqSymbol        Symbol = "<>"
+	BangEqSymbol     Symbol = "!="
+	LtSymbol         Symbol = "<"
+	LteSymbol        Symbol = "<="
+	GtSymbol         Symbol = ">"
+	GteSymbol        Symbol = ">="
+	PlusSymbol       Symbol = "+"
+	MinusSymbol      Symbol = "-"
+	SlashSymbol      Symbol = "/"
+	PercentSymbol    Symbol = "%"
+	ConcatSymbol     Symbol = "||"
+	DotSymbol        Symbol = "."
+	ArrowSymbol      Symbol = "->"
+	ArrowTextSymbol  Symbol = "->>"
+	CastSymbol       Symbol = "::"
+	// x LIKE y is parsed as x ~~ y, and NOT LIKE, ILIKE and NOT ILIKE
+	// likewise
+	LikeSymbol      Symbol = "~~"
+	NotLikeSymbol   Symbol = "!~~"
+	IlikeSymbol     Symbol = "~~*"
+	NotIlikeSymbol  Symbol = "!~~*"
+	RegexSymbol     Symbol = "~"
+	NotRegexSymbol  Symbol = "!~"
+	IregexSymbol    Symbol = "~*"
+	NotIregexSymbol Symbol = "!~*"
 )
 
 type TokenKind uint
@@ -44,6 +138,9 @@ const (
 	IdentifierKind
 	StringKind
 	NumericKind
+	// HexKind is a binary string written in hexadecimal, as in
+	// X'DEADBEEF'. The token holds the digits.
+	HexKind
 )
 
 type Token struct {
@@ -69,7 +166,11 @@ func lex(source string) ([]*Token, error) {
 
 lex:
 	for cur.Pointer < uint(len(source)) {
-		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexNumeric, lexIdentifier}
+		if err := checkHex(source, cur); err != nil {
+			return nil, err
+		}
+
+		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexNumeric, lexHex, lexIdentifier}
 		for _, l := range lexers {
 			if token, newCursor, ok := l(source, cur); ok {
 				cur = newCursor
@@ -211,6 +312,55 @@ func lexString(source string, ic Cursor) (*Token, Cursor, bool) {
 	return lexCharacterDelimited(source, ic, '\'')
 }
 
+// lexHex lexes a binary string like X'DEADBEEF', an even number of
+// hexadecimal digits.
+func lexHex(source string, ic Cursor) (*Token, Cursor, bool) {
+	if source[ic.Pointer] != 'x' && source[ic.Pointer] != 'X' {
+		return nil, ic, false
+	}
+
+	cur := ic
+	cur.Pointer++
+	cur.Loc.Col++
+	token, cur, ok := lexCharacterDelimited(source, cur, '\'')
+	if !ok || len(token.Value)%2 != 0 {
+		return nil, ic, false
+	}
+
+	for _, c := range token.Value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return nil, ic, false
+		}
+	}
+
+	token.Kind = HexKind
+	token.Loc = ic.Loc
+	return token, cur, true
+}
+
+// checkHex returns an error if a binary string lexHex rejects starts
+// at ic, which would otherwise be lexed as the identifier X followed by
+// a string.
+func checkHex(source string, ic Cursor) error {
+	if source[ic.Pointer] != 'x' && source[ic.Pointer] != 'X' {
+		return nil
+	}
+
+	cur := ic
+	cur.Pointer++
+	cur.Loc.Col++
+	token, _, ok := lexCharacterDelimited(source, cur, '\'')
+	if !ok {
+		return nil
+	}
+
+	if _, _, ok := lexHex(source, ic); ok {
+		return nil
+	}
+
+	return fmt.Errorf("binary string %c'%s' must be an even number of hexadecimal digits, at %d:%d", source[ic.Pointer], token.Value, ic.Loc.Line, ic.Loc.Col)
+}
+
 func lexSymbol(source string, ic Cursor) (*Token, Cursor, bool) {
 	c := source[ic.Pointer]
 	cur := ic
@@ -228,6 +378,11 @@ func lexSymbol(source string, ic Cursor) (*Token, Cursor, bool) {
 		fallthrough
 	case ' ':
 		return nil, cur, true
+	case '.':
+		// Leave numbers like `.5` to lexNumeric
+		if cur.Pointer < uint(len(source)) && source[cur.Pointer] >= '0' && source[cur.Pointer] <= '9' {
+			return nil, ic, false
+		}
 	}
 
 	// Syntax that should be kept
@@ -237,6 +392,30 @@ func lexSymbol(source string, ic Cursor) (*Token, Cursor, bool) {
 		RightParenSymbol,
 		SemicolonSymbol,
 		AsteriskSymbol,
+		EqSymbol,
+		NeqSymbol,
+		BangEqSymbol,
+		LtSymbol,
+		LteSymbol,
+		GtSymbol,
+		GteSymbol,
+		PlusSymbol,
+		MinusSymbol,
+		SlashSymbol,
+		PercentSymbol,
+		ConcatSymbol,
+		DotSymbol,
+		ArrowSymbol,
+		ArrowTextSymbol,
+		CastSymbol,
+		LikeSymbol,
+		NotLikeSymbol,
+		IlikeSymbol,
+		NotIlikeSymbol,
+		RegexSymbol,
+		NotRegexSymbol,
+		IregexSymbol,
+		NotIregexSymbol,
 	}
 
 	var options []string
@@ -274,7 +453,75 @@ func lexKeyword(source string, ic Cursor) (*Token, Cursor, bool) {
 		IntoKeyword,
 		ValuesKeyword,
 		IntKeyword,
+		SmallIntKeyword,
+		BigIntKeyword,
 		TextKeyword,
+		ByteaKeyword,
+		BlobKeyword,
+		JsonKeyword,
+		UuidKeyword,
+		DefaultKeyword,
+		CastKeyword,
+		VarcharKeyword,
+		CharKeyword,
+		BooleanKeyword,
+		RealKeyword,
+		DoubleKeyword,
+		PrecisionKeyword,
+		DecimalKeyword,
+		NumericKeyword,
+		DateKeyword,
+		TimeKeyword,
+		TimestampKeyword,
+		IntervalKeyword,
+		TrueKeyword,
+		FalseKeyword,
+		AndKeyword,
+		OrKeyword,
+		IndexKeyword,
+		OnKeyword,
+		ExplainKeyword,
+		AnalyzeKeyword,
+		JoinKeyword,
+		InnerKeyword,
+		GroupKeyword,
+		ByKeyword,
+		UsingKeyword,
+		ReturningKeyword,
+		PrimaryKeyword,
+		KeyKeyword,
+		UniqueKeyword,
+		ConflictKeyword,
+		DoKeyword,
+		NothingKeyword,
+		UpdateKeyword,
+		SetKeyword,
+		SequenceKeyword,
+		StartKeyword,
+		WithKeyword,
+		IncrementKeyword,
+		SerialKeyword,
+		BigSerialKeyword,
+		GeneratedKeyword,
+		AlwaysKeyword,
+		IdentityKeyword,
+		ExistsKeyword,
+		InKeyword,
+		NotKeyword,
+		RecursiveKeyword,
+		UnionKeyword,
+		AllKeyword,
+		IntersectKeyword,
+		ExceptKeyword,
+		DistinctKeyword,
+		BetweenKeyword,
+		LikeKeyword,
+		IlikeKeyword,
+		CaseKeyword,
+		WhenKeyword,
+		ThenKeyword,
+		ElseKeyword,
+		EndKeyword,
 	}
 
 	var options []string
@@ -290,6 +537,13 @@ func lexKeyword(source string, ic Cursor) (*Token, Cursor, bool) {
 	cur.Pointer = ic.Pointer + uint(len(match))
 	cur.Loc.Col = ic.Loc.Col + uint(len(match))
 
+	// Keywords must end on a word boundary, otherwise this is an
+	// identifier that happens to start with a keyword (e.g. `order_id`
+	// starting with `or`).
+	if cur.Pointer < uint(len(source)) && isIdentifierChar(source[cur.Pointer]) {
+		return nil, ic, false
+	}
+
 	return &Token{
 		Value: match,
 		Kind:  KeywordKind,
@@ -354,7 +608,6 @@ func lexIdentifier(source string, ic Cursor) (*Token, Cursor, bool) {
 	cur := ic
 
 	c := source[cur.Pointer]
-	// Other characters count too, big ignoring non-ascii for now
 	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
 	if !isAlphabetical {
 		return nil, ic, false
@@ -366,10 +619,7 @@ func lexIdentifier(source string, ic Cursor) (*Token, Cursor, bool) {
 	for ; cur.Pointer < uint(len(source)); cur.Pointer++ {
 		c = source[cur.Pointer]
 
-		// Other characters count too, big ignoring non-ascii for now
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
+		if isIdentifierChar(c) {
 			value = append(value, c)
 			cur.Loc.Col++
 			continue
@@ -389,3 +639,12 @@ func lexIdentifier(source string, ic Cursor) (*Token, Cursor, bool) {
 		Kind:  IdentifierKind,
 	}, cur, true
 }
+
+// isIdentifierChar reports whether c may appear after the first
+// character of an unquoted identifier.
+func isIdentifierChar(c byte) bool {
+	// Other characters count too, big ignoring non-ascii for now
+	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	isNumeric := c >= '0' && c <= '9'
+	return isAlphabetical || isNumeric || c == '$' || c == '_'
+}
@@ -1,6 +1,7 @@
 package ashudb
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -134,6 +135,53 @@ func TestToken_lexString(t *testing.T) {
 	}
 }
 
+func TestToken_lexHex(t *testing.T) {
+	tests := []struct {
+		hex   bool
+		value string
+	}{
+		{
+			hex:   true,
+			value: "X'DEADBEEF'",
+		},
+		{
+			hex:   true,
+			value: "x'00ff' ",
+		},
+		{
+			hex:   true,
+			value: "X''",
+		},
+		// false tests
+		{
+			hex:   false,
+			value: "X'ABC'",
+		},
+		{
+			hex:   false,
+			value: "X'ZZ'",
+		},
+		{
+			hex:   false,
+			value: "X 'AB'",
+		},
+		{
+			hex:   false,
+			value: "'AB'",
+		},
+	}
+
+	for _, test := range tests {
+		tok, _, ok := lexHex(test.value, Cursor{})
+		assert.Equal(t, test.hex, ok, test.value)
+		if ok {
+			test.value = strings.TrimSpace(test.value)
+			assert.Equal(t, test.value[2:len(test.value)-1], tok.Value, test.value)
+			assert.Equal(t, HexKind, tok.Kind, test.value)
+		}
+	}
+}
+
 func TestToken_lexIdentifier(t *testing.T) {
 	tests := []struct {
 		identifier bool
@@ -223,6 +271,130 @@ func TestToken_lexKeyword(t *testing.T) {
 			keyword: true,
 			value:   "into",
 		},
+		{
+			keyword: true,
+			value:   "TRUE",
+		},
+		{
+			keyword: true,
+			value:   "boolean",
+		},
+		{
+			keyword: true,
+			value:   "double",
+		},
+		{
+			keyword: true,
+			value:   "bigint",
+		},
+		{
+			keyword: true,
+			value:   "numeric",
+		},
+		{
+			keyword: true,
+			value:   "timestamp",
+		},
+		{
+			keyword: true,
+			value:   "varchar",
+		},
+		{
+			keyword: true,
+			value:   "char",
+		},
+		{
+			keyword: true,
+			value:   "INTERVAL",
+		},
+		{
+			keyword: true,
+			value:   "cast",
+		},
+		{
+			keyword: true,
+			value:   "RETURNING",
+		},
+		{
+			keyword: true,
+			value:   "conflict",
+		},
+		{
+			keyword: true,
+			value:   "sequence",
+		},
+		{
+			keyword: true,
+			value:   "IDENTITY",
+		},
+		{
+			keyword: true,
+			value:   "bigserial",
+		},
+		{
+			keyword: true,
+			value:   "exists",
+		},
+		{
+			keyword: true,
+			value:   "IN",
+		},
+		{
+			keyword: true,
+			value:   "not",
+		},
+		{
+			keyword: true,
+			value:   "RECURSIVE",
+		},
+		{
+			keyword: true,
+			value:   "union",
+		},
+		{
+			keyword: true,
+			value:   "INTERSECT",
+		},
+		{
+			keyword: true,
+			value:   "except",
+		},
+		{
+			keyword: true,
+			value:   "DISTINCT",
+		},
+		{
+			keyword: true,
+			value:   "BETWEEN",
+		},
+		{
+			keyword: true,
+			value:   "like",
+		},
+		{
+			keyword: true,
+			value:   "ILIKE",
+		},
+		{
+			keyword: true,
+			value:   "CASE",
+		},
+		{
+			keyword: true,
+			value:   "when",
+		},
+		{
+			keyword: true,
+			value:   "then",
+		},
+		{
+			keyword: true,
+			value:   "ELSE",
+		},
+		{
+			keyword: true,
+			value:   "end",
+		},
 		// false tests
 		{
 			keyword: false,
@@ -232,6 +404,30 @@ func TestToken_lexKeyword(t *testing.T) {
 			keyword: false,
 			value:   "flubbrety",
 		},
+		{
+			keyword: false,
+			value:   "done",
+		},
+		{
+			keyword: false,
+			value:   "order_id",
+		},
+		{
+			keyword: false,
+			value:   "falsehood",
+		},
+		{
+			keyword: false,
+			value:   "integer",
+		},
+		{
+			keyword: false,
+			value:   "realm",
+		},
+		{
+			keyword: false,
+			value:   "chart",
+		},
 	}
 
 	for _, test := range tests {
@@ -403,6 +599,169 @@ func TestLex(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			input: "id>=10 AND name<>'a'||b",
+			tokens: []Token{
+				{
+					Loc:   Location{Col: 0, Line: 0},
+					Value: "id",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 2, Line: 0},
+					Value: string(GteSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 4, Line: 0},
+					Value: "10",
+					Kind:  NumericKind,
+				},
+				{
+					Loc:   Location{Col: 8, Line: 0},
+					Value: string(AndKeyword),
+					Kind:  KeywordKind,
+				},
+				{
+					Loc:   Location{Col: 12, Line: 0},
+					Value: "name",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 16, Line: 0},
+					Value: string(NeqSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 18, Line: 0},
+					Value: "a",
+					Kind:  StringKind,
+				},
+				{
+					Loc:   Location{Col: 20, Line: 0},
+					Value: string(ConcatSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 22, Line: 0},
+					Value: "b",
+					Kind:  IdentifierKind,
+				},
+			},
+			err: nil,
+		},
+		{
+			input: "p->'a'->>0-1",
+			tokens: []Token{
+				{
+					Loc:   Location{Col: 0, Line: 0},
+					Value: "p",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 1, Line: 0},
+					Value: string(ArrowSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 3, Line: 0},
+					Value: "a",
+					Kind:  StringKind,
+				},
+				{
+					Loc:   Location{Col: 5, Line: 0},
+					Value: string(ArrowTextSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 8, Line: 0},
+					Value: "0",
+					Kind:  NumericKind,
+				},
+				{
+					Loc:   Location{Col: 10, Line: 0},
+					Value: string(MinusSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 11, Line: 0},
+					Value: "1",
+					Kind:  NumericKind,
+				},
+			},
+			err: nil,
+		},
+		{
+			input: "x::int",
+			tokens: []Token{
+				{
+					Loc:   Location{Col: 0, Line: 0},
+					Value: "x",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 1, Line: 0},
+					Value: string(CastSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 3, Line: 0},
+					Value: "int",
+					Kind:  KeywordKind,
+				},
+			},
+			err: nil,
+		},
+		{
+			input: "a !~~* b~*c !~ d",
+			tokens: []Token{
+				{
+					Loc:   Location{Col: 0, Line: 0},
+					Value: "a",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 2, Line: 0},
+					Value: string(NotIlikeSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 7, Line: 0},
+					Value: "b",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 8, Line: 0},
+					Value: string(IregexSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 10, Line: 0},
+					Value: "c",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 12, Line: 0},
+					Value: string(NotRegexSymbol),
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 15, Line: 0},
+					Value: "d",
+					Kind:  IdentifierKind,
+				},
+			},
+			err: nil,
+		},
+		{
+			// Binary strings hold whole bytes of hexadecimal digits
+			input: "select X'ABC'",
+			err:   fmt.Errorf("binary string X'ABC' must be an even number of hexadecimal digits, at 0:7"),
+		},
+		{
+			input: "select 1, x'0G'",
+			err:   fmt.Errorf("binary string x'0G' must be an even number of hexadecimal digits, at 0:11"),
+		},
 	}
 
 	for _, test := range tests {
@@ -2,178 +2,2116 @@ package ashudb
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/binary"
-	"fmt"
+	"encoding/hex"
+	"math"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type MemoryCell []byte
 
 func (mc MemoryCell) AsInt() int32 {
-	var i int32
-	err := binary.Read(bytes.NewBuffer(mc), binary.BigEndian, &i)
-	if err != nil {
-		panic(err)
-	}
+	return int32(binary.BigEndian.Uint32(mc))
+}
+
+func (mc MemoryCell) AsSmallInt() int16 {
+	return int16(binary.BigEndian.Uint16(mc))
+}
 
-	return i
+func (mc MemoryCell) AsBigInt() int64 {
+	return int64(binary.BigEndian.Uint64(mc))
 }
 
 func (mc MemoryCell) AsText() string {
 	return string(mc)
 }
 
+func (mc MemoryCell) AsBool() bool {
+	return mc[0] != 0
+}
+
+// AsFloat returns the value of a REAL, stored in 4 bytes, or of a
+// DOUBLE, stored in 8.
+func (mc MemoryCell) AsFloat() float64 {
+	if len(mc) == 4 {
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(mc)))
+	}
+
+	return math.Float64frombits(binary.BigEndian.Uint64(mc))
+}
+
+// AsDecimal returns the value of a DECIMAL, stored as its scale
+// followed by its unscaled value.
+func (mc MemoryCell) AsDecimal() Decimal {
+	return Decimal{Value: int64(binary.BigEndian.Uint64(mc[1:])), Scale: mc[0]}
+}
+
+// AsDate returns the value of a DATE, stored as the days since
+// 1970-01-01, as midnight UTC of that day.
+func (mc MemoryCell) AsDate() time.Time {
+	return time.UnixMicro(int64(mc.AsInt()) * microsPerDay).UTC()
+}
+
+// AsTime returns the value of a TIME, stored as the microseconds since
+// midnight, as that time on 1970-01-01 UTC.
+func (mc MemoryCell) AsTime() time.Time {
+	return time.UnixMicro(mc.AsBigInt()).UTC()
+}
+
+// AsTimestamp returns the value of a TIMESTAMP, stored as the
+// microseconds since 1970-01-01 00:00:00 UTC.
+func (mc MemoryCell) AsTimestamp() time.Time {
+	return time.UnixMicro(mc.AsBigInt()).UTC()
+}
+
+// AsInterval returns the value of an INTERVAL, stored as its months,
+// days and microseconds.
+func (mc MemoryCell) AsInterval() Interval {
+	return Interval{
+		Months: int32(binary.BigEndian.Uint32(mc)),
+		Days:   int32(binary.BigEndian.Uint32(mc[4:])),
+		Micros: int64(binary.BigEndian.Uint64(mc[8:])),
+	}
+}
+
+// AsBytes returns the value of a BYTEA, stored as is.
+func (mc MemoryCell) AsBytes() []byte {
+	return mc
+}
+
+// AsUUID returns the value of a UUID, stored as its 16 bytes.
+func (mc MemoryCell) AsUUID() UUID {
+	return UUID(mc)
+}
+
+func intToCell(i int32) MemoryCell {
+	cell := make(MemoryCell, 4)
+	binary.BigEndian.PutUint32(cell, uint32(i))
+	return cell
+}
+
+var (
+	trueMemoryCell  = MemoryCell{1}
+	falseMemoryCell = MemoryCell{0}
+)
+
+func boolToCell(b bool) MemoryCell {
+	if b {
+		return trueMemoryCell
+	}
+
+	return falseMemoryCell
+}
+
+// isTruthy reports whether a predicate result selects a row. Unknown
+// values don't.
+func isTruthy(cell MemoryCell, typ ColumnType) (bool, error) {
+	if typ != BoolType {
+		return false, ErrInvalidOperands
+	}
+
+	return cell != nil && cell.AsBool(), nil
+}
+
+type index struct {
+	name string
+	exp  expression
+	// rows maps the encoded value of exp to the positions of the rows
+	// holding it.
+	rows map[string][]int
+}
+
+// typeModifiers constrain the values of a column beyond its type, like
+// the precision and scale of DECIMAL(10, 2) or the length of
+// VARCHAR(20). A zero precision or length doesn't constrain.
+type typeModifiers struct {
+	precision int
+	scale     int
+	length    int
+	// padded is set for CHAR(n), whose values are padded with spaces
+	// to the full length. The spaces don't count in comparisons, so
+	// they are only added as the values are returned.
+	padded bool
+}
+
 type table struct {
-	columns     []string
-	columnTypes []ColumnType
-	rows        [][]MemoryCell
+	columns         []string
+	columnTypes     []ColumnType
+	columnModifiers []typeModifiers
+	// defaults are the expressions inserted when a column is given no
+	// value, nil for NULL
+	defaults []*expression
+	rows     [][]MemoryCell
+	// generatedAlways is set for the GENERATED ALWAYS AS IDENTITY
+	// columns, which only take their default
+	generatedAlways []bool
+	// vectors holds the values of a columnar table, column by column,
+	// in place of rows
+	vectors []*columnVector
+	indexes []*index
+	// constraints are the table's PRIMARY KEY and UNIQUE constraints
+	constraints []*uniqueConstraint
+	// statistics is nil until the table is analyzed
+	statistics *tableStatistics
+}
+
+func (t *table) columnar() bool {
+	return t.vectors != nil
+}
+
+func (t *table) rowCount() int {
+	if t.columnar() {
+		if len(t.vectors) == 0 {
+			return 0
+		}
+
+		return t.vectors[0].len()
+	}
+
+	return len(t.rows)
+}
+
+// row returns the i'th row of the table, assembling it from the
+// vectors of a columnar table.
+func (t *table) row(i int) []MemoryCell {
+	if !t.columnar() {
+		return t.rows[i]
+	}
+
+	row := make([]MemoryCell, 0, len(t.vectors))
+	for _, vector := range t.vectors {
+		row = append(row, vector.cell(i))
+	}
+
+	return row
+}
+
+// check reports whether a row can be stored in the table.
+func (t *table) check(row []MemoryCell) error {
+	for i, vector := range t.vectors {
+		if err := vector.check(row[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *table) appendRow(row []MemoryCell) error {
+	if !t.columnar() {
+		t.rows = append(t.rows, row)
+		return nil
+	}
+
+	if err := t.check(row); err != nil {
+		return err
+	}
+
+	for i, vector := range t.vectors {
+		vector.append(row[i])
+	}
+
+	return nil
+}
+
+// setRow replaces the row at position.
+func (t *table) setRow(position int, row []MemoryCell) {
+	if !t.columnar() {
+		t.rows[position] = row
+		return
+	}
+
+	for i, vector := range t.vectors {
+		vector.set(position, row[i])
+	}
+}
+
+// newTypeModifiers checks the modifiers of a column definition against
+// its type, named name. DECIMAL takes a precision and optionally a
+// scale, which defaults to 0, and VARCHAR and CHAR a length, which for
+// CHAR defaults to 1.
+func newTypeModifiers(name string, typ ColumnType, tokens []Token) (typeModifiers, error) {
+	values := []int{}
+	for _, t := range tokens {
+		v, err := strconv.Atoi(t.Value)
+		if err != nil {
+			return typeModifiers{}, ErrInvalidDatatype
+		}
+
+		values = append(values, v)
+	}
+
+	switch {
+	case name == string(VarcharKeyword) || name == string(CharKeyword):
+		modifiers := typeModifiers{padded: name == string(CharKeyword)}
+		if modifiers.padded {
+			modifiers.length = 1
+		}
+
+		if len(values) > 1 {
+			return typeModifiers{}, ErrInvalidDatatype
+		}
+
+		if len(values) == 1 {
+			modifiers.length = values[0]
+			if modifiers.length < 1 {
+				return typeModifiers{}, ErrInvalidDatatype
+			}
+		}
+
+		return modifiers, nil
+	case len(values) == 0:
+		return typeModifiers{}, nil
+	case typ != DecimalType || len(values) > 2:
+		return typeModifiers{}, ErrInvalidDatatype
+	}
+
+	modifiers := typeModifiers{precision: values[0]}
+	if len(values) == 2 {
+		modifiers.scale = values[1]
+	}
+
+	if modifiers.precision < 1 || modifiers.precision > maxDecimalPrecision || modifiers.scale < 0 || modifiers.scale > modifiers.precision {
+		return typeModifiers{}, ErrInvalidDatatype
+	}
+
+	return modifiers, nil
+}
+
+// fit fits a value of type typ to the modifiers: decimals to their
+// precision and scale and text to its length. Text too long is cut
+// short if truncate is set, as casts do, rather than rejected.
+func (m typeModifiers) fit(cell MemoryCell, typ ColumnType, truncate bool) (MemoryCell, error) {
+	if cell == nil {
+		return nil, nil
+	}
+
+	if typ == DecimalType && m.precision > 0 {
+		d, err := fitDecimal(cell.AsDecimal(), m.precision, m.scale)
+		if err != nil {
+			return nil, err
+		}
+
+		return decimalToCell(d), nil
+	}
+
+	if typ == TextType && m.length > 0 {
+		s := cell.AsText()
+		if truncate && utf8.RuneCountInString(s) > m.length {
+			s = string([]rune(s)[:m.length])
+		}
+
+		text, err := fitText(s, m.length, m.padded)
+		if err != nil {
+			return nil, err
+		}
+
+		return MemoryCell(text), nil
+	}
+
+	return cell, nil
+}
+
+// coerce converts a value of type typ into one the i'th column stores,
+// as an assignment does, and fits it to the column's modifiers. A value
+// that can't be converted is a *ColumnTypeError.
+func (t *table) coerce(i int, cell MemoryCell, typ ColumnType) (MemoryCell, error) {
+	target := t.columnTypes[i]
+	if !convertible(typ, target, assignmentCoercion) {
+		return nil, &ColumnTypeError{Column: t.columns[i], Expected: target, Actual: typ, Err: ErrInvalidDatatype}
+	}
+
+	cell, err := convertCell(cell, typ, target)
+	if err == ErrNumericOverflow || err == ErrDatetimeOverflow {
+		// The value has the right type, but is out of its range
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, &ColumnTypeError{Column: t.columns[i], Expected: target, Actual: typ, Err: err}
+	}
+
+	return t.columnModifiers[i].fit(cell, target, false)
+}
+
+// pad returns the length values are padded to in results, 0 for
+// anything other than CHAR(n).
+func (m typeModifiers) pad() int {
+	if !m.padded {
+		return 0
+	}
+
+	return m.length
+}
+
+// value evaluates the value given for the i'th column, or its default
+// if the value is DEFAULT.
+func (t *table) value(i int, exp expression) (MemoryCell, ColumnType, error) {
+	if isDefault(exp) {
+		if t.defaults[i] == nil {
+			return nil, t.columnTypes[i], nil
+		}
+
+		exp = *t.defaults[i]
+	}
+
+	cell, col, err := evaluateCell(exp, nil, nil)
+	return cell, col.Type, err
+}
+
+// isDefault reports whether exp is DEFAULT, standing for the default
+// of a column.
+func isDefault(exp expression) bool {
+	return exp.kind == literalKind && exp.literal.Kind == KeywordKind && exp.literal.Value == string(DefaultKeyword)
+}
+
+// resultColumns describes the table's columns, qualified with alias.
+func (t *table) resultColumns(alias string) []ResultColumn {
+	columns := []ResultColumn{}
+	for i, name := range t.columns {
+		columns = append(columns, ResultColumn{
+			Type:  t.columnTypes[i],
+			Name:  name,
+			table: alias,
+			pad:   t.columnModifiers[i].pad(),
+		})
+	}
+
+	return columns
+}
+
+// pick returns the positions of the named columns, or of every column
+// if names is nil.
+func (t *table) pick(names []string, alias string) ([]int, []ResultColumn, error) {
+	all := t.resultColumns(alias)
+	if names == nil {
+		positions := []int{}
+		for i := range all {
+			positions = append(positions, i)
+		}
+
+		return positions, all, nil
+	}
+
+	positions := []int{}
+	columns := []ResultColumn{}
+	for _, name := range names {
+		found := false
+		for i, col := range all {
+			if col.Name == name {
+				positions = append(positions, i)
+				columns = append(columns, col)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, nil, ErrColumnDoesNotExist
+		}
+	}
+
+	return positions, columns, nil
+}
+
+// pickRow returns the cells at positions of the i'th row.
+func (t *table) pickRow(i int, positions []int) []MemoryCell {
+	picked := make([]MemoryCell, 0, len(positions))
+	for _, position := range positions {
+		if t.columnar() {
+			picked = append(picked, t.vectors[position].cell(i))
+		} else {
+			picked = append(picked, t.rows[i][position])
+		}
+	}
+
+	return picked
+}
+
+// lookup returns the positions of the rows whose indexed value, of
+// type indexType, may equal value, of type typ.
+func (idx *index) lookup(value MemoryCell, typ, indexType ColumnType, rowCount int) []int {
+	if _, ok := commonType(typ, indexType); ok && typ != indexType {
+		// Many decimals can be equal to the same float, and many texts
+		// to the same date, so every row has to be checked
+		if indexType == DecimalType && !isInteger(typ) || indexType == TextType {
+			all := make([]int, rowCount)
+			for i := range all {
+				all[i] = i
+			}
+
+			return all
+		}
+
+		converted, err := convertCell(value, typ, indexType)
+		if err != nil {
+			// Nothing the index holds is that large, or the text
+			// isn't a date
+			return nil
+		}
+
+		value, typ = converted, indexType
+	}
+
+	return idx.rows[string(canonicalCell(value, typ))]
 }
 
 type MemoryBackend struct {
-	tables map[string]*table
+	tables    map[string]*table
+	sequences map[string]*sequence
+	// workers is how many goroutines a large scan, filter or
+	// aggregation is split across
+	workers int
+	// dry is set for a run that only works out the columns of a query:
+	// its scans read no rows, so nothing is computed from them
+	dry bool
 }
 
 func NewMemoryBackend() *MemoryBackend {
 	return &MemoryBackend{
-		tables: map[string]*table{},
+		tables:    map[string]*table{},
+		sequences: map[string]*sequence{},
+		workers:   runtime.GOMAXPROCS(0),
+	}
+}
+
+// SetWorkers sets how many goroutines large scans, filters and
+// aggregations are split across. One runs every query on the calling
+// goroutine.
+func (mb *MemoryBackend) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
 	}
+
+	mb.workers = workers
 }
 
 func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
+	// The table is only registered once all of it is known to be
+	// valid, so a failed CREATE TABLE leaves nothing behind
 	t := table{}
-	mb.tables[crt.name.Value] = &t
 	if crt.cols == nil {
+		mb.tables[crt.name.Value] = &t
 		return nil
 	}
 
+	owned := map[string]*sequence{}
 	for _, col := range *crt.cols {
 		t.columns = append(t.columns, col.name.Value)
 
-		var dt ColumnType
-		switch col.datatype.Value {
-		case "int":
-			dt = IntType
-		case "text":
-			dt = TextType
-		default:
-			return ErrInvalidDatatype
+		dt, serial := serialTypes[col.datatype.Value]
+		if !serial {
+			var err error
+			dt, err = parseColumnType(col.datatype.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		modifiers, err := newTypeModifiers(col.datatype.Value, dt, col.modifiers)
+		if err != nil {
+			return err
+		}
+
+		// SERIAL and identity columns default to the next value of a
+		// sequence of their own
+		defaultValue := col.defaultValue
+		if serial || col.identity {
+			if defaultValue != nil {
+				return ErrIdentityDefault
+			}
+
+			if !isInteger(dt) {
+				return ErrInvalidDatatype
+			}
+
+			name := crt.name.Value + "_" + col.name.Value + "_seq"
+			if _, ok := mb.sequences[name]; ok {
+				return ErrSequenceExists
+			}
+
+			owned[name] = &sequence{start: 1, increment: 1}
+			defaultValue = nextvalExpression(name)
+		}
+
+		// Defaults can't refer to columns
+		if defaultValue != nil {
+			if _, _, err := evaluateCell(*defaultValue, nil, nil); err != nil {
+				return err
+			}
+
+			mb.bind(*defaultValue, nil)
 		}
 
 		t.columnTypes = append(t.columnTypes, dt)
+		t.columnModifiers = append(t.columnModifiers, modifiers)
+		t.defaults = append(t.defaults, defaultValue)
+		t.generatedAlways = append(t.generatedAlways, col.always)
+	}
+
+	for _, tc := range crt.constraints {
+		if tc.primaryKey && slices.ContainsFunc(t.constraints, func(uc *uniqueConstraint) bool {
+			return uc.primaryKey
+		}) {
+			return ErrMultiplePrimaryKeys
+		}
+
+		uc, err := t.newUniqueConstraint(tc)
+		if err != nil {
+			return err
+		}
+
+		t.constraints = append(t.constraints, uc)
+	}
+
+	if crt.using != nil {
+		switch crt.using.Value {
+		case "columnar":
+			t.vectors = []*columnVector{}
+			for _, typ := range t.columnTypes {
+				t.vectors = append(t.vectors, newColumnVector(typ))
+			}
+		case "row":
+		default:
+			return ErrInvalidStorage
+		}
+	}
+
+	mb.tables[crt.name.Value] = &t
+	for name, s := range owned {
+		mb.sequences[name] = s
 	}
 
 	return nil
 }
 
-func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
-	table, ok := mb.tables[inst.table.Value]
+func (mb *MemoryBackend) CreateIndex(ci *CreateIndexStatement) error {
+	t, ok := mb.tables[ci.table.Value]
 	if !ok {
 		return ErrTableDoesNotExist
 	}
 
-	if inst.values == nil {
-		return nil
+	for _, idx := range t.indexes {
+		if idx.name == ci.name.Value {
+			return ErrIndexAlreadyExists
+		}
 	}
 
-	row := []MemoryCell{}
-
-	if len(*inst.values) != len(table.columns) {
-		return ErrMissingValues
+	idx := &index{
+		name: ci.name.Value,
+		exp:  ci.exp,
+		rows: map[string][]int{},
 	}
 
-	for _, value := range *inst.values {
-		if value.kind != literalKind {
-			fmt.Println("Skipping non-literal.")
-			continue
+	columns := t.resultColumns(ci.table.Value)
+	for i := 0; i < t.rowCount(); i++ {
+		cell, col, err := evaluateCell(idx.exp, columns, t.row(i))
+		if err != nil {
+			return err
 		}
-		row = append(row, mb.tokenToCell(value.literal))
+
+		key := string(canonicalCell(cell, col.Type))
+		idx.rows[key] = append(idx.rows[key], i)
 	}
 
-	table.rows = append(table.rows, row)
+	t.indexes = append(t.indexes, idx)
 	return nil
 }
 
-func (mb *MemoryBackend) tokenToCell(t *Token) MemoryCell {
-	if t.Kind == NumericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.Value)
-		if err != nil {
-			panic(err)
-		}
+// indexFor implements catalog for the optimizer.
+func (mb *MemoryBackend) indexFor(table string, exp expression) (string, bool) {
+	t, ok := mb.tables[table]
+	if !ok {
+		return "", false
+	}
 
-		err = binary.Write(buf, binary.BigEndian, int32(i))
-		if err != nil {
-			panic(err)
+	for _, idx := range t.indexes {
+		if idx.exp.generateCode() == exp.generateCode() {
+			return idx.name, true
 		}
-		return MemoryCell(buf.Bytes())
 	}
 
-	if t.Kind == StringKind {
-		return MemoryCell(t.Value)
+	return "", false
+}
+
+// tableColumns implements catalog for the optimizer.
+func (mb *MemoryBackend) tableColumns(table string) ([]string, bool) {
+	t, ok := mb.tables[table]
+	if !ok {
+		return nil, false
 	}
 
-	return nil
+	return t.columns, true
 }
 
-func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
-	table, ok := mb.tables[slct.from.table.Value]
+// statistics implements catalog for the optimizer.
+func (mb *MemoryBackend) statistics(table string) *tableStatistics {
+	t, ok := mb.tables[table]
 	if !ok {
-		return nil, ErrTableDoesNotExist
+		return nil
 	}
 
-	results := [][]Cell{}
-	columns := []struct {
-		Type ColumnType
-		Name string
-	}{}
+	return t.statistics
+}
 
-	for i, row := range table.rows {
-		result := []Cell{}
-		isFirstRow := i == 0
+// Analyze refreshes the statistics of one table, or of every table if
+// none is named.
+func (mb *MemoryBackend) Analyze(a *AnalyzeStatement) error {
+	tables := mb.tables
+	if a.table != nil {
+		t, ok := mb.tables[a.table.Value]
+		if !ok {
+			return ErrTableDoesNotExist
+		}
 
-		for _, itm := range *slct.item {
-			if itm.exp.kind != literalKind {
-				// Unsupported, doesn't currently exist, ignore.
-				fmt.Println("Skipping non-literal expression.")
-				continue
-			}
+		tables = map[string]*table{a.table.Value: t}
+	}
 
-			lit := itm.exp.literal
-			if lit.Kind == IdentifierKind {
-				found := false
-				for i, tableCol := range table.columns {
-					if tableCol == lit.Value {
-						if isFirstRow {
-							columns = append(columns, struct {
-								Type ColumnType
-								Name string
-							}{
-								Type: table.columnTypes[i],
-								Name: lit.Value,
-							})
-						}
-
-						result = append(result, row[i])
-						found = true
-						break
-					}
-				}
+	for _, t := range tables {
+		stats := tableStatistics{
+			rows:    t.rowCount(),
+			columns: map[string]*columnStatistics{},
+		}
 
-				if !found {
-					return nil, ErrColumnDoesNotExist
+		for i, name := range t.columns {
+			cells := []MemoryCell{}
+			for j := 0; j < t.rowCount(); j++ {
+				if t.columnar() {
+					cells = append(cells, t.vectors[i].cell(j))
+				} else {
+					cells = append(cells, t.rows[j][i])
 				}
-
-				continue
 			}
 
-			return nil, ErrColumnDoesNotExist
+			stats.columns[name] = analyzeColumn(cells, t.columnTypes[i])
 		}
 
-		results = append(results, result)
+		t.statistics = &stats
 	}
 
-	return &Results{
-		Columns: columns,
-		Rows:    results,
-	}, nil
+	return nil
+}
+
+func (mb *MemoryBackend) Insert(inst *InsertStatement) (*Results, error) {
+	table, ok := mb.tables[inst.table.Value]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+
+	targets, err := table.targets(inst.columns)
+	if err != nil {
+		return nil, err
+	}
+
+	mb.bindInsert(inst)
+
+	var rows [][]MemoryCell
+	if inst.query != nil {
+		rows, err = mb.queryRows(table, targets, inst.query)
+	} else {
+		rows, err = table.valueRows(targets, inst.values)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := table.write(rows, inst.table.Value, inst.onConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out what to return before writing, so a bad RETURNING
+	// clause leaves the table unchanged
+	var returned *relation
+	if inst.returning != nil {
+		written := &relation{columns: table.resultColumns(inst.table.Value), rows: c.written}
+		returned, err = mb.project(written, *inst.returning, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := table.apply(c, inst.table.Value); err != nil {
+		return nil, err
+	}
+
+	if returned == nil {
+		return nil, nil
+	}
+
+	return returned.results(), nil
+}
+
+// targets returns the positions of the named columns an insert gives
+// values for, or of every column if names is nil.
+func (t *table) targets(names *[]Token) ([]int, error) {
+	if names == nil {
+		targets := []int{}
+		for i := range t.columns {
+			targets = append(targets, i)
+		}
+
+		return targets, nil
+	}
+
+	targets := []int{}
+	for _, name := range *names {
+		i := slices.Index(t.columns, name.Value)
+		if i == -1 {
+			return nil, ErrColumnDoesNotExist
+		}
+
+		if slices.Contains(targets, i) {
+			return nil, ErrDuplicateColumn
+		}
+
+		targets = append(targets, i)
+	}
+
+	return targets, nil
+}
+
+// newRow returns a row holding the default of every column, ready to
+// be given values for targets.
+func (t *table) newRow(targets []int) ([]MemoryCell, error) {
+	row := make([]MemoryCell, len(t.columns))
+	for i := range t.columns {
+		if slices.Contains(targets, i) || t.defaults[i] == nil {
+			continue
+		}
+
+		cell, typ, err := t.value(i, *t.defaults[i])
+		if err != nil {
+			return nil, err
+		}
+
+		row[i], err = t.coerce(i, cell, typ)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+// valueRows builds the rows given by the tuples after VALUES, each
+// holding a value for every one of targets.
+func (t *table) valueRows(targets []int, values []*[]*expression) ([][]MemoryCell, error) {
+	rows := [][]MemoryCell{}
+	for _, tuple := range values {
+		if len(*tuple) != len(targets) {
+			return nil, ErrMissingValues
+		}
+
+		row, err := t.newRow(targets)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, value := range *tuple {
+			i := targets[j]
+			if t.generatedAlways[i] && !isDefault(*value) {
+				return nil, ErrGeneratedAlways
+			}
+
+			cell, typ, err := t.value(i, *value)
+			if err != nil {
+				return nil, err
+			}
+
+			row[i], err = t.coerce(i, cell, typ)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// queryRows builds the rows of an INSERT ... SELECT, with the columns
+// query returns going to targets.
+func (mb *MemoryBackend) queryRows(t *table, targets []int, query *SelectStatement) ([][]MemoryCell, error) {
+	for _, i := range targets {
+		if t.generatedAlways[i] {
+			return nil, ErrGeneratedAlways
+		}
+	}
+
+	results, err := mb.Select(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Columns) != len(targets) {
+		return nil, ErrMissingValues
+	}
+
+	rows := [][]MemoryCell{}
+	for _, result := range results.Rows {
+		row, err := t.newRow(targets)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, cell := range result {
+			i := targets[j]
+			value, _ := cell.(MemoryCell)
+			row[i], err = t.coerce(i, value, results.Columns[j].Type)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// literalCell returns the value and type of a constant. Whole numbers
+// are INT, or BIGINT if they don't fit, and any other number is an
+// exact DECIMAL, or a DOUBLE if it has too many digits for one.
+func literalCell(t *Token) (MemoryCell, ColumnType, error) {
+	switch t.Kind {
+	case StringKind:
+		return MemoryCell(t.Value), TextType, nil
+	case HexKind:
+		b, err := hex.DecodeString(t.Value)
+		if err != nil {
+			return nil, 0, ErrInvalidDatatype
+		}
+
+		return MemoryCell(b), BlobType, nil
+	case KeywordKind:
+		if t.Value == string(DefaultKeyword) {
+			return nil, 0, ErrMisplacedDefault
+		}
+
+		return boolToCell(t.Value == string(TrueKeyword)), BoolType, nil
+	}
+
+	if i, err := strconv.ParseInt(t.Value, 10, 32); err == nil {
+		return intToCell(int32(i)), IntType, nil
+	}
+
+	if i, err := strconv.ParseInt(t.Value, 10, 64); err == nil {
+		cell, err := integerToCell(i, BigIntType)
+		return cell, BigIntType, err
+	}
+
+	d, err := parseDecimal(t.Value)
+	if err == ErrNumericOverflow {
+		f, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil {
+			return nil, 0, ErrNumericOverflow
+		}
+
+		return floatToCell(f, DoubleType), DoubleType, nil
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return decimalToCell(d), DecimalType, nil
+}
+
+// evaluateCell evaluates an expression against a row described by
+// columns. A nil cell in the row stands for an unknown value: any
+// operation on it yields a nil cell of the right type, which is how
+// result types are worked out without a row to evaluate.
+func evaluateCell(exp expression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	switch exp.kind {
+	case literalKind:
+		return evaluateLiteralCell(exp, columns, row)
+	case binaryKind:
+		return evaluateBinaryCell(*exp.binary, columns, row)
+	case functionKind:
+		if isAggregate(exp) {
+			return nil, ResultColumn{}, ErrMisplacedAggregate
+		}
+
+		return evaluateFunctionCell(*exp.function, columns, row)
+	case subqueryKind:
+		return evaluateSubqueryCell(exp.subquery, columns, row)
+	case valueKind:
+		return exp.value.cell, ResultColumn{Type: exp.value.typ, Name: "?column?"}, nil
+	}
+
+	return nil, ResultColumn{}, ErrInvalidSelectItem
+}
+
+// resolveColumn returns the position in columns of the column an
+// identifier refers to.
+func resolveColumn(exp expression, columns []ResultColumn) (int, error) {
+	found := -1
+	for i, col := range columns {
+		if col.Name != exp.literal.Value || (exp.table != nil && col.table != exp.table.Value) {
+			continue
+		}
+
+		if found != -1 {
+			return 0, ErrColumnAmbiguous
+		}
+
+		found = i
+	}
+
+	if found == -1 {
+		return 0, ErrColumnDoesNotExist
+	}
+
+	return found, nil
+}
+
+// describe works out what exp evaluates to against rows of columns
+// without evaluating any of it: its constants and subqueries are
+// replaced by unknown values of their types before it is evaluated
+// against a row of unknowns. String literals are kept, since their
+// text decides whether they compare as numbers.
+func describe(exp expression, columns []ResultColumn) (ResultColumn, error) {
+	typed, err := unknownValues(exp, columns)
+	if err != nil {
+		return ResultColumn{}, err
+	}
+
+	_, col, err := evaluateCell(typed, columns, make([]MemoryCell, len(columns)))
+	return col, err
+}
+
+// unknownValues returns a copy of e with unknown values of the same
+// types in place of its constants and subqueries, and no sequences
+// bound, so that evaluating it computes and advances nothing.
+func unknownValues(e expression, columns []ResultColumn) (expression, error) {
+	unknown := func(typ ColumnType) expression {
+		return expression{kind: valueKind, value: &boundValue{typ: typ}}
+	}
+
+	switch e.kind {
+	case literalKind:
+		if e.literal.Kind == IdentifierKind || isStringLiteral(e) {
+			return e, nil
+		}
+
+		_, col, err := evaluateLiteralCell(e, nil, nil)
+		if err != nil {
+			return expression{}, err
+		}
+
+		return unknown(col.Type), nil
+	case binaryKind:
+		a, err := unknownValues(e.binary.a, columns)
+		if err != nil {
+			return expression{}, err
+		}
+
+		b, err := unknownValues(e.binary.b, columns)
+		if err != nil {
+			return expression{}, err
+		}
+
+		binary := *e.binary
+		binary.a, binary.b = a, b
+		e.binary = &binary
+	case functionKind:
+		function := *e.function
+		function.sequences = nil
+		function.args = nil
+		for _, arg := range e.function.args {
+			typed, err := unknownValues(arg, columns)
+			if err != nil {
+				return expression{}, err
+			}
+
+			function.args = append(function.args, typed)
+		}
+		e.function = &function
+	case subqueryKind:
+		col, err := describeSubquery(e.subquery, columns)
+		if err != nil {
+			return expression{}, err
+		}
+
+		return unknown(col.Type), nil
+	case valueKind:
+		return unknown(e.value.typ), nil
+	}
+
+	return e, nil
+}
+
+func evaluateLiteralCell(exp expression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	t := *exp.literal
+	if t.Kind == IdentifierKind {
+		found, err := resolveColumn(exp, columns)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		return row[found], columns[found], nil
+	}
+
+	if exp.typ != nil {
+		typ, err := parseColumnType(exp.typ.Value)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		cell, err := parseDatetime(t.Value, typ)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		return cell, ResultColumn{Type: typ, Name: "?column?"}, nil
+	}
+
+	cell, columnType, err := literalCell(&t)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	return cell, ResultColumn{Type: columnType, Name: "?column?"}, nil
+}
+
+func compareInts(i, j int32) int {
+	switch {
+	case i < j:
+		return -1
+	case i > j:
+		return 1
+	}
+
+	return 0
+}
+
+func compareCells(a, b MemoryCell, typ ColumnType) int {
+	switch typ {
+	case IntType:
+		return compareInts(a.AsInt(), b.AsInt())
+	case SmallIntType, BigIntType:
+		return cmp.Compare(integerValue(a, typ), integerValue(b, typ))
+	case RealType, DoubleType:
+		return cmp.Compare(a.AsFloat(), b.AsFloat())
+	case DecimalType:
+		return compareDecimals(a.AsDecimal(), b.AsDecimal())
+	case DateType:
+		return compareInts(a.AsInt(), b.AsInt())
+	case TimeType, TimestampType:
+		return cmp.Compare(a.AsBigInt(), b.AsBigInt())
+	case IntervalType:
+		return a.AsInterval().total().Cmp(b.AsInterval().total())
+	}
+
+	return bytes.Compare(a, b)
+}
+
+// comparisonHolds reports whether a comparison operator holds for
+// operands that compared as cmp.
+func comparisonHolds(op Symbol, cmp int) bool {
+	switch op {
+	case EqSymbol:
+		return cmp == 0
+	case NeqSymbol, BangEqSymbol:
+		return cmp != 0
+	case LtSymbol:
+		return cmp < 0
+	case LteSymbol:
+		return cmp <= 0
+	case GtSymbol:
+		return cmp > 0
+	}
+
+	return cmp >= 0
+}
+
+func evaluateBinaryCell(exp binaryExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	a, aCol, err := evaluateCell(exp.a, columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	b, bCol, err := evaluateCell(exp.b, columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	result := ResultColumn{Type: IntType, Name: "?column?"}
+
+	switch exp.op.Kind {
+	case KeywordKind:
+		switch Keyword(exp.op.Value) {
+		case AndKeyword, OrKeyword:
+			if aCol.Type != BoolType || bCol.Type != BoolType {
+				return nil, ResultColumn{}, ErrInvalidOperands
+			}
+
+			result.Type = BoolType
+
+			// An unknown operand doesn't matter if the other one
+			// decides the result: FALSE AND x or TRUE OR x
+			decisive := Keyword(exp.op.Value) == OrKeyword
+			if (a != nil && a.AsBool() == decisive) || (b != nil && b.AsBool() == decisive) {
+				return boolToCell(decisive), result, nil
+			}
+
+			if a == nil || b == nil {
+				return nil, result, nil
+			}
+
+			return boolToCell(!decisive), result, nil
+		}
+	case SymbolKind:
+		switch Symbol(exp.op.Value) {
+		case EqSymbol, NeqSymbol, BangEqSymbol, LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+			a, aCol.Type, err = coerceLiteral(exp.a, a, aCol.Type, bCol.Type)
+			if err != nil {
+				return nil, ResultColumn{}, err
+			}
+
+			b, bCol.Type, err = coerceLiteral(exp.b, b, bCol.Type, aCol.Type)
+			if err != nil {
+				return nil, ResultColumn{}, err
+			}
+
+			typ := aCol.Type
+			if aCol.Type != bCol.Type {
+				a, b, typ, err = promoteOperands(a, b, aCol.Type, bCol.Type)
+				if err != nil {
+					return nil, ResultColumn{}, err
+				}
+			}
+
+			result.Type = BoolType
+			if a == nil || b == nil {
+				return nil, result, nil
+			}
+
+			cmp := compareCells(a, b, typ)
+			return boolToCell(comparisonHolds(Symbol(exp.op.Value), cmp)), result, nil
+		case PlusSymbol, MinusSymbol, AsteriskSymbol, SlashSymbol, PercentSymbol:
+			op := Symbol(exp.op.Value)
+			if typ, ok := datetimeResultType(op, aCol.Type, bCol.Type); ok {
+				result.Type = typ
+				if a == nil || b == nil {
+					return nil, result, nil
+				}
+
+				cell, err := datetimeArithmetic(op, a, b, aCol.Type, bCol.Type)
+				if err != nil {
+					return nil, ResultColumn{}, err
+				}
+
+				return cell, result, nil
+			}
+
+			if !isNumeric(aCol.Type) || !isNumeric(bCol.Type) {
+				return nil, ResultColumn{}, ErrInvalidOperands
+			}
+
+			a, b, result.Type, err = promoteOperands(a, b, aCol.Type, bCol.Type)
+			if err != nil {
+				return nil, ResultColumn{}, err
+			}
+
+			if a == nil || b == nil {
+				return nil, result, nil
+			}
+
+			cell, err := numericArithmetic(op, a, b, result.Type)
+			if err != nil {
+				return nil, ResultColumn{}, err
+			}
+
+			return cell, result, nil
+		case ArrowSymbol, ArrowTextSymbol:
+			if !isJSONDocument(aCol.Type) || (bCol.Type != TextType && !isInteger(bCol.Type)) {
+				return nil, ResultColumn{}, ErrInvalidOperands
+			}
+
+			result.Type = JsonType
+			if Symbol(exp.op.Value) == ArrowTextSymbol {
+				result.Type = TextType
+			}
+
+			if a == nil || b == nil {
+				return nil, result, nil
+			}
+
+			doc, err := jsonDocument(a, aCol.Type)
+			if err != nil {
+				return nil, ResultColumn{}, err
+			}
+
+			return jsonField(doc, b, bCol.Type, result.Type == TextType), result, nil
+		case LikeSymbol, NotLikeSymbol, IlikeSymbol, NotIlikeSymbol, RegexSymbol, NotRegexSymbol, IregexSymbol, NotIregexSymbol:
+			if aCol.Type != TextType || bCol.Type != TextType {
+				return nil, ResultColumn{}, ErrInvalidOperands
+			}
+
+			result.Type = BoolType
+			if a == nil || b == nil {
+				return nil, result, nil
+			}
+
+			matched, err := matchPattern(Symbol(exp.op.Value), a.AsText(), b.AsText())
+			if err != nil {
+				return nil, ResultColumn{}, err
+			}
+
+			return boolToCell(matched), result, nil
+		case ConcatSymbol:
+			if aCol.Type != bCol.Type || (aCol.Type != TextType && aCol.Type != BlobType) {
+				return nil, ResultColumn{}, ErrInvalidOperands
+			}
+
+			result.Type = aCol.Type
+			if a == nil || b == nil {
+				return nil, result, nil
+			}
+
+			return append(append(MemoryCell{}, a...), b...), result, nil
+		}
+	}
+
+	return nil, ResultColumn{}, ErrInvalidOperands
+}
+
+// relation is the materialized output of a plan node.
+type relation struct {
+	columns []ResultColumn
+	rows    [][]MemoryCell
+}
+
+// minRowsPerWorker is the fewest rows worth handing to a goroutine of
+// their own, below it starting the goroutine costs more than it saves.
+const minRowsPerWorker = 16384
+
+// span is a contiguous range of rows, from start up to but not
+// including end.
+type span struct {
+	start int
+	end   int
+}
+
+// partition splits n rows into contiguous spans, one for each worker
+// there are enough rows to keep busy.
+func (mb *MemoryBackend) partition(n int) []span {
+	workers := n / minRowsPerWorker
+	if workers > mb.workers {
+		workers = mb.workers
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	spans := []span{}
+	for i := 0; i < workers; i++ {
+		spans = append(spans, span{start: i * n / workers, end: (i + 1) * n / workers})
+	}
+
+	return spans
+}
+
+// parallel calls fn for every span, each on its own goroutine, and
+// waits for them all. Callers merge per-span results in span order so
+// that the output doesn't depend on scheduling, and for the same
+// reason the error returned is that of the earliest failing span.
+func parallel(spans []span, fn func(part int, s span) error) error {
+	if len(spans) == 1 {
+		return fn(0, spans[0])
+	}
+
+	errs := make([]error, len(spans))
+	var wg sync.WaitGroup
+	for i, s := range spans {
+		wg.Add(1)
+		go func(i int, s span) {
+			defer wg.Done()
+			errs[i] = fn(i, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mb *MemoryBackend) execute(p *plan) (*relation, error) {
+	if p == nil && mb.dry {
+		return &relation{}, nil
+	}
+
+	if p == nil {
+		return &relation{rows: [][]MemoryCell{{}}}, nil
+	}
+
+	if p.analysis == nil {
+		return mb.executeNode(p)
+	}
+
+	start := time.Now()
+	rel, err := mb.executeNode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	p.analysis.rows = len(rel.rows)
+	p.analysis.duration = time.Since(start)
+	return rel, nil
+}
+
+func (mb *MemoryBackend) executeNode(p *plan) (*relation, error) {
+	switch p.kind {
+	case scanPlanKind:
+		return mb.executeScan(p.scan)
+	case indexScanPlanKind:
+		return mb.executeIndexScan(p.indexScan)
+	case filterPlanKind:
+		return mb.executeFilter(p.filter)
+	case projectPlanKind:
+		return mb.executeProject(p.project)
+	case joinPlanKind:
+		return mb.executeJoin(p.join)
+	case aggregatePlanKind:
+		return mb.executeAggregate(p.aggregate)
+	case subqueryScanPlanKind:
+		return mb.executeSubqueryScan(p.subquery)
+	case cteScanPlanKind:
+		return mb.executeCTEScan(p.cteScan)
+	case setOperationPlanKind:
+		return mb.executeSetOperation(p.set)
+	case distinctPlanKind:
+		return mb.executeDistinct(p.distinct)
+	}
+
+	return nil, ErrInvalidPlan
+}
+
+func (mb *MemoryBackend) executeScan(s *scanPlan) (*relation, error) {
+	t, ok := mb.tables[s.table.Value]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+
+	positions, columns, err := t.pick(s.columns, qualifier(s.table, s.as))
+	if err != nil {
+		return nil, err
+	}
+
+	if mb.dry {
+		return &relation{columns: columns}, nil
+	}
+
+	rows := make([][]MemoryCell, t.rowCount())
+	err = parallel(mb.partition(t.rowCount()), func(_ int, s span) error {
+		for i := s.start; i < s.end; i++ {
+			rows[i] = t.pickRow(i, positions)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &relation{columns: columns, rows: rows}, nil
+}
+
+func (mb *MemoryBackend) executeIndexScan(s *indexScanPlan) (*relation, error) {
+	t, ok := mb.tables[s.table.Value]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+
+	var idx *index
+	for _, candidate := range t.indexes {
+		if candidate.name == s.index {
+			idx = candidate
+		}
+	}
+
+	if idx == nil {
+		return nil, ErrIndexDoesNotExist
+	}
+
+	positions, columns, err := t.pick(s.columns, qualifier(s.table, s.as))
+	if err != nil {
+		return nil, err
+	}
+
+	all := t.resultColumns(qualifier(s.table, s.as))
+	indexCol, err := describe(idx.exp, all)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out the condition's type first, so operands that can't be
+	// compared are an error as they are without the index, not a
+	// lookup that finds nothing
+	if _, err := describe(s.cond, all); err != nil {
+		return nil, err
+	}
+
+	if mb.dry {
+		return &relation{columns: columns}, nil
+	}
+
+	value, valueCol, err := evaluateCell(s.value, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	value, valueCol.Type, err = coerceLiteral(s.value, value, valueCol.Type, indexCol.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]MemoryCell{}
+	for _, i := range idx.lookup(value, valueCol.Type, indexCol.Type, t.rowCount()) {
+		row := t.row(i)
+
+		// Different types can encode to the same bytes
+		cell, col, err := evaluateCell(s.cond, all, row)
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := isTruthy(cell, col.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		rows = append(rows, t.pickRow(i, positions))
+	}
+
+	return &relation{columns: columns, rows: rows}, nil
+}
+
+func (mb *MemoryBackend) executeFilter(f *filterPlan) (*relation, error) {
+	if rel, ok, err := mb.executeColumnarFilter(f); ok {
+		return rel, err
+	}
+
+	child, err := mb.execute(f.child)
+	if err != nil {
+		return nil, err
+	}
+
+	spans := mb.partition(len(child.rows))
+	kept := make([][][]MemoryCell, len(spans))
+	err = parallel(spans, func(part int, s span) error {
+		rows := [][]MemoryCell{}
+		for _, row := range child.rows[s.start:s.end] {
+			cell, col, err := evaluateCell(f.predicate, child.columns, row)
+			if err != nil {
+				return err
+			}
+
+			ok, err := isTruthy(cell, col.Type)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				rows = append(rows, row)
+			}
+		}
+
+		kept[part] = rows
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]MemoryCell{}
+	for _, part := range kept {
+		rows = append(rows, part...)
+	}
+
+	return &relation{columns: child.columns, rows: rows}, nil
+}
+
+func (mb *MemoryBackend) executeProject(p *projectPlan) (*relation, error) {
+	child, err := mb.execute(p.child)
+	if err != nil {
+		return nil, err
+	}
+
+	return mb.project(child, p.items, p.from)
+}
+
+// project computes items over every row of child, expanding * to the
+// columns of the tables in from, or every column if from is empty.
+func (mb *MemoryBackend) project(child *relation, items []*selectItem, from []string) (*relation, error) {
+	asterisk := asteriskColumns(child.columns, from)
+
+	// Work out the result columns without evaluating anything, so they
+	// are known even when there are no rows
+	columns := []ResultColumn{}
+	for _, item := range items {
+		if item.asterisk {
+			for _, i := range asterisk {
+				columns = append(columns, child.columns[i])
+			}
+			continue
+		}
+
+		col, err := describe(*item.exp, child.columns)
+		if err != nil {
+			return nil, err
+		}
+
+		col.Name = selectItemName(item)
+		col.table = ""
+		columns = append(columns, col)
+	}
+
+	rows := make([][]MemoryCell, len(child.rows))
+	err := parallel(mb.partition(len(child.rows)), func(_ int, s span) error {
+		for i, row := range child.rows[s.start:s.end] {
+			result := make([]MemoryCell, 0, len(columns))
+			for _, item := range items {
+				if item.asterisk {
+					for _, position := range asterisk {
+						result = append(result, row[position])
+					}
+					continue
+				}
+
+				cell, _, err := evaluateCell(*item.exp, child.columns, row)
+				if err != nil {
+					return err
+				}
+
+				result = append(result, cell)
+			}
+
+			rows[s.start+i] = result
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &relation{columns: columns, rows: rows}, nil
+}
+
+// aggregateColumns works out the result columns of an aggregation over
+// input, and the functions and argument types of its aggregates.
+func aggregateColumns(a *aggregatePlan, input []ResultColumn) ([]ResultColumn, []aggregateFunction, []ColumnType, error) {
+	columns := []ResultColumn{}
+	for _, key := range a.groupBy {
+		col, err := describe(key, input)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if !isColumn(key) {
+			col = ResultColumn{Type: col.Type, Name: key.generateCode()}
+		}
+
+		columns = append(columns, col)
+	}
+
+	functions := []aggregateFunction{}
+	types := []ColumnType{}
+	for _, aggregate := range a.aggregates {
+		call := aggregate.function
+		function := aggregateFunctions[call.name.Value]
+
+		// Only count takes *, everything else takes one argument
+		var typ ColumnType
+		if call.asterisk {
+			if call.name.Value != "count" {
+				return nil, nil, nil, ErrInvalidArguments
+			}
+		} else {
+			if len(call.args) != 1 {
+				return nil, nil, nil, ErrInvalidArguments
+			}
+
+			col, err := describe(call.args[0], input)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			typ = col.Type
+		}
+
+		resultType, err := function.resultType(typ)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		functions = append(functions, function)
+		types = append(types, typ)
+		columns = append(columns, ResultColumn{Type: resultType, Name: aggregate.generateCode()})
+	}
+
+	return columns, functions, types, nil
+}
+
+// keyTypes returns the types of the group keys of an aggregation, the
+// first of its result columns.
+func keyTypes(columns []ResultColumn, a *aggregatePlan) []ColumnType {
+	types := []ColumnType{}
+	for _, col := range columns[:len(a.groupBy)] {
+		types = append(types, col.Type)
+	}
+
+	return types
+}
+
+// aggregateRows turns the groups of an aggregation into its rows.
+func aggregateRows(a *aggregatePlan, groups *aggregateGroups, functions []aggregateFunction) [][]MemoryCell {
+	// Aggregating without groups always produces a row, even over no
+	// input
+	if len(a.groupBy) == 0 && len(groups.keys) == 0 {
+		groups.group(nil, functions)
+	}
+
+	rows := [][]MemoryCell{}
+	for i, key := range groups.keys {
+		rows = append(rows, append(append([]MemoryCell{}, key...), groups.states[i]...))
+	}
+
+	return rows
+}
+
+func (mb *MemoryBackend) executeAggregate(a *aggregatePlan) (*relation, error) {
+	if rel, ok, err := mb.executeColumnarAggregate(a); ok {
+		return rel, err
+	}
+
+	child, err := mb.execute(a.child)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, functions, types, err := aggregateColumns(a, child.columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a GROUP BY there is a row even for no input
+	if mb.dry {
+		return &relation{columns: columns}, nil
+	}
+
+	// Every part of the input is grouped on its own and the parts are
+	// then merged in order, so groups come out in the order they first
+	// appear in the input
+	spans := mb.partition(len(child.rows))
+	parts := make([]*aggregateGroups, len(spans))
+	err = parallel(spans, func(part int, s span) error {
+		groups := newAggregateGroups(keyTypes(columns, a))
+		for _, row := range child.rows[s.start:s.end] {
+			key := make([]MemoryCell, 0, len(a.groupBy))
+			for _, exp := range a.groupBy {
+				cell, _, err := evaluateCell(exp, child.columns, row)
+				if err != nil {
+					return err
+				}
+
+				key = append(key, cell)
+			}
+
+			states := groups.group(key, functions)
+			for i, aggregate := range a.aggregates {
+				// count(*) counts rows, whatever is in them
+				value := MemoryCell{}
+				if !aggregate.function.asterisk {
+					cell, _, err := evaluateCell(aggregate.function.args[0], child.columns, row)
+					if err != nil {
+						return err
+					}
+
+					value = cell
+				}
+
+				state, err := functions[i].step(states[i], value, types[i])
+				if err != nil {
+					return err
+				}
+
+				states[i] = state
+			}
+		}
+
+		parts[part] = groups
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := parts[0]
+	for _, part := range parts[1:] {
+		if err := groups.merge(part, functions, types); err != nil {
+			return nil, err
+		}
+	}
+
+	return &relation{columns: columns, rows: aggregateRows(a, groups, functions)}, nil
+}
+
+// asteriskColumns returns the positions of the columns * expands to:
+// all of them, grouped by table in FROM clause order when there are
+// joins.
+func asteriskColumns(columns []ResultColumn, from []string) []int {
+	positions := []int{}
+	if len(from) == 0 {
+		for i := range columns {
+			positions = append(positions, i)
+		}
+
+		return positions
+	}
+
+	for _, table := range from {
+		for i, col := range columns {
+			if col.table == table {
+				positions = append(positions, i)
+			}
+		}
+	}
+
+	return positions
+}
+
+// evaluateKeys evaluates join keys against every row of a relation.
+func evaluateKeys(rel *relation, keys []expression) ([][]MemoryCell, []ColumnType, error) {
+	types := []ColumnType{}
+	for _, key := range keys {
+		col, err := describe(key, rel.columns)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		types = append(types, col.Type)
+	}
+
+	values := [][]MemoryCell{}
+	for _, row := range rel.rows {
+		value := []MemoryCell{}
+		for _, key := range keys {
+			cell, _, err := evaluateCell(key, rel.columns, row)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			value = append(value, cell)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, types, nil
+}
+
+// encodeKey packs cells into a string usable as a map key, length
+// prefixing each so that different cells can't run together.
+func encodeKey(cells []MemoryCell) string {
+	var buf []byte
+	for _, cell := range cells {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(cell)))
+		buf = append(buf, cell...)
+	}
+
+	return string(buf)
+}
+
+// hashKey encodes a key like encodeKey, but so that keys that are
+// equal, not just identical, encode the same.
+func hashKey(cells []MemoryCell, types []ColumnType) string {
+	canonical := make([]MemoryCell, len(cells))
+	for i, cell := range cells {
+		canonical[i] = canonicalCell(cell, types[i])
+	}
+
+	return encodeKey(canonical)
+}
+
+// convertKeys converts the i'th cell of every key from one type to
+// another.
+func convertKeys(keys [][]MemoryCell, i int, from, to ColumnType) error {
+	for _, key := range keys {
+		cell, err := convertCell(key[i], from, to)
+		if err != nil {
+			return err
+		}
+
+		key[i] = cell
+	}
+
+	return nil
+}
+
+func compareKeys(a, b []MemoryCell, types []ColumnType) int {
+	for i, typ := range types {
+		if cmp := compareCells(a[i], b[i], typ); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return 0
+}
+
+// hasUnknownKey reports whether any of a row's join keys is unknown.
+// Unknown keys equal nothing, not even each other, so such rows never
+// join.
+func hasUnknownKey(key []MemoryCell) bool {
+	for _, cell := range key {
+		if cell == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (mb *MemoryBackend) executeJoin(j *joinPlan) (*relation, error) {
+	left, err := mb.execute(j.left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := mb.execute(j.right)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append(append([]ResultColumn{}, left.columns...), right.columns...)
+	rows := [][]MemoryCell{}
+
+	// emit adds a pair of rows to the result if they meet the join
+	// condition
+	emit := func(l, r []MemoryCell) error {
+		row := append(append([]MemoryCell{}, l...), r...)
+		if j.cond != nil {
+			cell, col, err := evaluateCell(*j.cond, columns, row)
+			if err != nil {
+				return err
+			}
+
+			ok, err := isTruthy(cell, col.Type)
+			if err != nil || !ok {
+				return err
+			}
+		}
+
+		rows = append(rows, row)
+		return nil
+	}
+
+	if j.algorithm == nestedLoopJoin {
+		for _, l := range left.rows {
+			for _, r := range right.rows {
+				if err := emit(l, r); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return &relation{columns: columns, rows: rows}, nil
+	}
+
+	leftKeys, leftTypes, err := evaluateKeys(left, j.leftKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	rightKeys, rightTypes, err := evaluateKeys(right, j.rightKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys of different types are compared in a common one
+	for i := range leftTypes {
+		if leftTypes[i] == rightTypes[i] {
+			continue
+		}
+
+		typ, ok := commonType(leftTypes[i], rightTypes[i])
+		if !ok {
+			return nil, ErrInvalidOperands
+		}
+
+		if err := convertKeys(leftKeys, i, leftTypes[i], typ); err != nil {
+			return nil, err
+		}
+
+		if err := convertKeys(rightKeys, i, rightTypes[i], typ); err != nil {
+			return nil, err
+		}
+
+		leftTypes[i] = typ
+	}
+
+	if j.algorithm == hashJoin {
+		built := map[string][]int{}
+		for i, key := range rightKeys {
+			if hasUnknownKey(key) {
+				continue
+			}
+
+			encoded := hashKey(key, leftTypes)
+			built[encoded] = append(built[encoded], i)
+		}
+
+		for i, key := range leftKeys {
+			if hasUnknownKey(key) {
+				continue
+			}
+
+			for _, match := range built[hashKey(key, leftTypes)] {
+				if err := emit(left.rows[i], right.rows[match]); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return &relation{columns: columns, rows: rows}, nil
+	}
+
+	// Merge join: sort both sides on their keys and walk them in step
+	sorted := func(keys [][]MemoryCell) []int {
+		order := []int{}
+		for i, key := range keys {
+			if !hasUnknownKey(key) {
+				order = append(order, i)
+			}
+		}
+
+		sort.SliceStable(order, func(a, b int) bool {
+			return compareKeys(keys[order[a]], keys[order[b]], leftTypes) < 0
+		})
+		return order
+	}
+
+	leftOrder, rightOrder := sorted(leftKeys), sorted(rightKeys)
+	for l, r := 0, 0; l < len(leftOrder) && r < len(rightOrder); {
+		cmp := compareKeys(leftKeys[leftOrder[l]], rightKeys[rightOrder[r]], leftTypes)
+		if cmp < 0 {
+			l++
+			continue
+		}
+
+		if cmp > 0 {
+			r++
+			continue
+		}
+
+		// Join the runs of equal keys on either side
+		lEnd := l
+		for lEnd < len(leftOrder) && compareKeys(leftKeys[leftOrder[lEnd]], leftKeys[leftOrder[l]], leftTypes) == 0 {
+			lEnd++
+		}
+
+		rEnd := r
+		for rEnd < len(rightOrder) && compareKeys(rightKeys[rightOrder[rEnd]], rightKeys[rightOrder[r]], leftTypes) == 0 {
+			rEnd++
+		}
+
+		for _, li := range leftOrder[l:lEnd] {
+			for _, ri := range rightOrder[r:rEnd] {
+				if err := emit(left.rows[li], right.rows[ri]); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		l, r = lEnd, rEnd
+	}
+
+	return &relation{columns: columns, rows: rows}, nil
+}
+
+func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
+	rel, err := mb.run(slct, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rel.results(), nil
+}
+
+// run plans and executes a SELECT that sees the common table
+// expressions of ctes.
+func (mb *MemoryBackend) run(slct *SelectStatement, ctes *cteScope) (*relation, error) {
+	mb.bindSelect(slct, ctes)
+	return mb.execute(optimize(newPlan(slct), mb))
+}
+
+func (mb *MemoryBackend) Explain(explain *ExplainStatement) (*Results, error) {
+	mb.bindSelect(explain.slct, nil)
+	p := optimize(newPlan(explain.slct), mb)
+	if explain.analyze {
+		p.analyze()
+
+		_, err := mb.execute(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows := [][]Cell{}
+	for _, line := range p.explain() {
+		rows = append(rows, []Cell{MemoryCell(line)})
+	}
+
+	return &Results{
+		Columns: []ResultColumn{{Type: TextType, Name: "QUERY PLAN"}},
+		Rows:    rows,
+	}, nil
+}
+
+func (rel *relation) results() *Results {
+	rows := [][]Cell{}
+	for _, row := range rel.rows {
+		result := []Cell{}
+		for i, cell := range row {
+			// Unknown values, like the sum of no rows, are nil cells
+			if cell == nil {
+				result = append(result, nil)
+				continue
+			}
+
+			if pad := rel.columns[i].pad; pad > 0 {
+				cell = MemoryCell(padText(cell.AsText(), pad))
+			}
+
+			result = append(result, cell)
+		}
+
+		rows = append(rows, result)
+	}
+
+	return &Results{
+		Columns: rel.columns,
+		Rows:    rows,
+	}
 }
@@ -0,0 +1,2679 @@
+package ashudb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBackend runs setup statements against a fresh backend.
+func newTestBackend(t testing.TB, source string) *MemoryBackend {
+	mb := NewMemoryBackend()
+
+	ast, err := Parse(source)
+	assert.Nil(t, err, source)
+
+	for _, stmt := range ast.Statements {
+		switch stmt.Kind {
+		case CreateTableKind:
+			err = mb.CreateTable(stmt.CreateTableStatement)
+		case CreateIndexKind:
+			err = mb.CreateIndex(stmt.CreateIndexStatement)
+		case CreateSequenceKind:
+			err = mb.CreateSequence(stmt.CreateSequenceStatement)
+		case InsertKind:
+			_, err = mb.Insert(stmt.InsertStatement)
+		case AnalyzeKind:
+			err = mb.Analyze(stmt.AnalyzeStatement)
+		}
+		assert.Nil(t, err, source)
+	}
+
+	return mb
+}
+
+func sortedRows(results *Results) []string {
+	var rows []string
+	for _, row := range results.Rows {
+		var line string
+		for i, cell := range row {
+			switch {
+			case cell == nil:
+				line += "|"
+			case results.Columns[i].Type == IntType:
+				line += fmt.Sprintf("%d|", cell.AsInt())
+			case results.Columns[i].Type == SmallIntType:
+				line += fmt.Sprintf("%d|", cell.AsSmallInt())
+			case results.Columns[i].Type == BigIntType:
+				line += fmt.Sprintf("%d|", cell.AsBigInt())
+			case results.Columns[i].Type == BoolType:
+				line += fmt.Sprintf("%t|", cell.AsBool())
+			case results.Columns[i].Type == RealType:
+				line += strconv.FormatFloat(cell.AsFloat(), 'g', -1, 32) + "|"
+			case results.Columns[i].Type == DoubleType:
+				line += strconv.FormatFloat(cell.AsFloat(), 'g', -1, 64) + "|"
+			case results.Columns[i].Type == DecimalType:
+				line += cell.AsDecimal().String() + "|"
+			case results.Columns[i].Type == DateType:
+				line += cell.AsDate().Format("2006-01-02") + "|"
+			case results.Columns[i].Type == TimeType:
+				line += cell.AsTime().Format("15:04:05.999999") + "|"
+			case results.Columns[i].Type == TimestampType:
+				line += cell.AsTimestamp().Format("2006-01-02 15:04:05.999999") + "|"
+			case results.Columns[i].Type == IntervalType:
+				line += cell.AsInterval().String() + "|"
+			case results.Columns[i].Type == UuidType:
+				line += cell.AsUUID().String() + "|"
+			case results.Columns[i].Type == BlobType:
+				line += fmt.Sprintf("\\x%x|", cell.AsBytes())
+			default:
+				line += cell.AsText() + "|"
+			}
+		}
+		rows = append(rows, line)
+	}
+
+	sort.Strings(rows)
+	return rows
+}
+
+const joinSetup = `
+CREATE TABLE users (id INT, name TEXT);
+INSERT INTO users VALUES (1, 'Phil');
+INSERT INTO users VALUES (2, 'Kate');
+INSERT INTO users VALUES (3, 'Sam');
+CREATE TABLE orders (id INT, user_id INT);
+INSERT INTO orders VALUES (1, 1);
+INSERT INTO orders VALUES (2, 1);
+INSERT INTO orders VALUES (3, 2);
+INSERT INTO orders VALUES (4, 9);
+`
+
+func TestMemoryBackend_joinAlgorithms(t *testing.T) {
+	mb := newTestBackend(t, joinSetup)
+
+	slct := parseSelect(t, "SELECT u.name, o.id FROM users u JOIN orders o ON u.id = o.user_id;")
+	expected := []string{"Kate|3|", "Phil|1|", "Phil|2|"}
+
+	for _, algorithm := range []joinAlgorithm{nestedLoopJoin, hashJoin, mergeJoin} {
+		p := optimize(newPlan(slct), mb)
+		join := p.project.child
+		assert.Equal(t, joinPlanKind, join.kind)
+		join.join.algorithm = algorithm
+
+		rel, err := mb.execute(p)
+		assert.Nil(t, err, algorithm.String())
+		assert.Equal(t, expected, sortedRows(rel.results()), algorithm.String())
+	}
+}
+
+func TestMemoryBackend_invalidPlan(t *testing.T) {
+	// A plan of a kind nothing executes is an error, not a crash
+	_, err := NewMemoryBackend().execute(&plan{kind: distinctPlanKind + 1})
+	assert.Equal(t, ErrInvalidPlan, err)
+}
+
+func TestMemoryBackend_CreateTable(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{source: "CREATE TABLE t (a INT, b DECIMAL(100, 2));", err: ErrInvalidDatatype},
+		{source: "CREATE TABLE t (a INT, b INT DEFAULT c);", err: ErrColumnDoesNotExist},
+		{source: "CREATE TABLE t (a INT, b TEXT GENERATED ALWAYS AS IDENTITY);", err: ErrInvalidDatatype},
+		{source: "CREATE TABLE t (a INT, b SERIAL DEFAULT 1);", err: ErrIdentityDefault},
+		{source: "CREATE TABLE t (a INT PRIMARY KEY, b INT PRIMARY KEY);", err: ErrMultiplePrimaryKeys},
+		{source: "CREATE TABLE t (a INT, UNIQUE (a, c));", err: ErrColumnDoesNotExist},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		if !assert.Nil(t, err, test.source) {
+			continue
+		}
+
+		mb := NewMemoryBackend()
+		err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+		assert.ErrorIs(t, err, test.err, test.source)
+
+		// A failed CREATE TABLE leaves no table behind
+		_, err = mb.Select(parseSelect(t, "SELECT * FROM t;"))
+		assert.Equal(t, ErrTableDoesNotExist, err, test.source)
+	}
+}
+
+func TestMemoryBackend_joins(t *testing.T) {
+	mb := newTestBackend(t, joinSetup)
+
+	tests := []struct {
+		source  string
+		columns []string
+		rows    []string
+		err     error
+	}{
+		{
+			source:  "SELECT * FROM orders, users WHERE users.id = orders.user_id AND users.name = 'Kate';",
+			columns: []string{"id", "user_id", "id", "name"},
+			rows:    []string{"3|2|2|Kate|"},
+		},
+		{
+			source:  "SELECT name FROM users INNER JOIN orders ON users.id = user_id AND orders.id > 1;",
+			columns: []string{"name"},
+			rows:    []string{"Kate|", "Phil|"},
+		},
+		{
+			source: "SELECT id FROM users, orders;",
+			err:    ErrColumnAmbiguous,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		var columns []string
+		for _, column := range results.Columns {
+			columns = append(columns, column.Name)
+		}
+		assert.Equal(t, test.columns, columns, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_Analyze(t *testing.T) {
+	mb := newTestBackend(t, joinSetup+"ANALYZE;")
+
+	stats := mb.statistics("orders")
+	assert.NotNil(t, stats)
+	assert.Equal(t, 4, stats.rows)
+	assert.Equal(t, 3, stats.columns["user_id"].distinct)
+	assert.Equal(t, int32(1), stats.columns["user_id"].min.AsInt())
+	assert.Equal(t, int32(9), stats.columns["user_id"].max.AsInt())
+
+	lookup := func(column expression) *columnStatistics {
+		return stats.columns[column.literal.Value]
+	}
+
+	where := parseSelect(t, "SELECT 1 FROM orders WHERE user_id = 1;").where
+	assert.InDelta(t, 1.0/3, selectivity(*where, lookup), 0.001)
+
+	where = parseSelect(t, "SELECT 1 FROM orders WHERE 2 < id;").where
+	assert.InDelta(t, 0.6, selectivity(*where, lookup), 0.001)
+}
+
+func TestMemoryBackend_AnalyzeNulls(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE n (a INT, b BIGINT, c DECIMAL(5, 2), d DATE, e TIMESTAMP, f INTERVAL, g TEXT);
+INSERT INTO n (a) VALUES (1);
+INSERT INTO n (a) VALUES (2);
+INSERT INTO n VALUES (3, 4, 1.5, '2024-01-01', '2024-01-01 12:00:00', '1 day', 'x');
+INSERT INTO n (a) VALUES (3);
+ANALYZE n;
+`)
+
+	stats := mb.statistics("n")
+	assert.NotNil(t, stats)
+	assert.Equal(t, 4, stats.rows)
+	assert.Equal(t, 3, stats.columns["a"].distinct)
+	assert.Equal(t, 0.0, stats.columns["a"].nullFraction)
+
+	for _, column := range []string{"b", "c", "d", "e", "f", "g"} {
+		assert.Equal(t, 1, stats.columns[column].distinct, column)
+		assert.Equal(t, 0.75, stats.columns[column].nullFraction, column)
+		assert.Len(t, stats.columns[column].histogram, 2, column)
+	}
+
+	lookup := func(column expression) *columnStatistics {
+		return stats.columns[column.literal.Value]
+	}
+
+	where := parseSelect(t, "SELECT 1 FROM n WHERE b = 4;").where
+	assert.InDelta(t, 0.25, selectivity(*where, lookup), 0.001)
+
+	where = parseSelect(t, "SELECT 1 FROM n WHERE b <> 4;").where
+	assert.InDelta(t, 0.0, selectivity(*where, lookup), 0.001)
+
+	results, err := mb.Select(parseSelect(t, "SELECT a FROM n WHERE b > 1 OR d < '2025-01-01';"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3|"}, sortedRows(results))
+}
+
+func TestMemoryBackend_joinUnknownKeys(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE l (id INT, k INT, s TEXT);
+CREATE TABLE r (id INT, k INT, s TEXT);
+INSERT INTO l VALUES (1, 1, 'a'), (2, 2, '');
+INSERT INTO l (id) VALUES (3);
+INSERT INTO r VALUES (1, 1, 'a'), (2, 2, '');
+INSERT INTO r (id) VALUES (3);
+`)
+
+	tests := []struct {
+		source string
+		rows   []string
+	}{
+		{
+			source: "SELECT l.id, r.id FROM l JOIN r ON l.k = r.k;",
+			rows:   []string{"1|1|", "2|2|"},
+		},
+		{
+			// Unknown text keys don't match '' either
+			source: "SELECT l.id, r.id FROM l JOIN r ON l.s = r.s;",
+			rows:   []string{"1|1|", "2|2|"},
+		},
+	}
+
+	for _, test := range tests {
+		slct := parseSelect(t, test.source)
+		for _, algorithm := range []joinAlgorithm{nestedLoopJoin, hashJoin, mergeJoin} {
+			p := optimize(newPlan(slct), mb)
+			join := p.project.child
+			assert.Equal(t, joinPlanKind, join.kind)
+			join.join.algorithm = algorithm
+
+			rel, err := mb.execute(p)
+			assert.Nil(t, err, algorithm.String())
+			assert.Equal(t, test.rows, sortedRows(rel.results()), test.source, algorithm.String())
+		}
+	}
+}
+
+func TestMemoryBackend_aggregates(t *testing.T) {
+	mb := newTestBackend(t, joinSetup)
+
+	tests := []struct {
+		source  string
+		columns []string
+		rows    []string
+		err     error
+	}{
+		{
+			source:  "SELECT user_id, count(*), sum(id), min(id) + max(id) AS spread FROM orders GROUP BY user_id;",
+			columns: []string{"user_id", "count", "sum", "spread"},
+			rows:    []string{"1|2|3|3|", "2|1|3|6|", "9|1|4|8|"},
+		},
+		{
+			source:  "SELECT u.name, count(o.id) FROM users u JOIN orders o ON u.id = o.user_id GROUP BY u.name;",
+			columns: []string{"name", "count"},
+			rows:    []string{"Kate|1|", "Phil|2|"},
+		},
+		{
+			source:  "SELECT id % 2 AS odd, max(name) FROM users GROUP BY id % 2;",
+			columns: []string{"odd", "max"},
+			rows:    []string{"0|Kate|", "1|Sam|"},
+		},
+		{
+			source:  "SELECT count(*), min(name) FROM users WHERE id > 5;",
+			columns: []string{"count", "min"},
+			rows:    []string{"0||"},
+		},
+		{
+			source: "SELECT sum(name) FROM users;",
+			err:    ErrInvalidOperands,
+		},
+		{
+			source: "SELECT name FROM users WHERE count(*) > 1;",
+			err:    ErrMisplacedAggregate,
+		},
+		{
+			source: "SELECT sum(*) FROM users;",
+			err:    ErrInvalidArguments,
+		},
+		{
+			source: "SELECT lower(name) FROM users;",
+			err:    ErrFunctionDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		var columns []string
+		for _, column := range results.Columns {
+			columns = append(columns, column.Name)
+		}
+		assert.Equal(t, test.columns, columns, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+// newEventsBackend creates a table of n rows stored in layout, large
+// enough for queries against it to be split across workers.
+func newEventsBackend(t testing.TB, n int, layout string) *MemoryBackend {
+	mb := newTestBackend(t, fmt.Sprintf("CREATE TABLE events (id INT, kind INT, name TEXT) USING %s;", layout))
+
+	events := mb.tables["events"]
+	for i := 0; i < n; i++ {
+		err := events.appendRow([]MemoryCell{
+			intToCell(int32(i)),
+			intToCell(int32(i % 16)),
+			MemoryCell(fmt.Sprintf("event %d", i%1000)),
+		})
+		assert.Nil(t, err)
+	}
+
+	return mb
+}
+
+func TestMemoryBackend_parallel(t *testing.T) {
+	mb := newEventsBackend(t, minRowsPerWorker*4+3, "row")
+
+	sources := []string{
+		"SELECT id, name FROM events;",
+		"SELECT id FROM events WHERE kind = 3 AND id % 7 = 0;",
+		"SELECT kind, count(*), min(id), max(name) FROM events GROUP BY kind;",
+		"SELECT name, count(*) FROM events WHERE id > 100 GROUP BY name;",
+	}
+
+	for _, source := range sources {
+		mb.SetWorkers(1)
+		expected, err := mb.Select(parseSelect(t, source))
+		assert.Nil(t, err, source)
+
+		// Results, including their order, don't depend on how many
+		// workers produced them
+		for _, workers := range []int{2, 3, 8} {
+			mb.SetWorkers(workers)
+			results, err := mb.Select(parseSelect(t, source))
+			assert.Nil(t, err, source)
+			assert.Equal(t, expected, results, source)
+		}
+	}
+
+	mb.SetWorkers(4)
+	_, err := mb.Select(parseSelect(t, "SELECT id / (id - 40000) FROM events;"))
+	assert.Equal(t, ErrDivisionByZero, err)
+}
+
+func TestMemoryBackend_partition(t *testing.T) {
+	mb := NewMemoryBackend()
+	mb.SetWorkers(4)
+
+	assert.Equal(t, []span{{0, 0}}, mb.partition(0))
+	assert.Equal(t, []span{{0, minRowsPerWorker + 1}}, mb.partition(minRowsPerWorker+1))
+	assert.Equal(t, []span{{0, minRowsPerWorker}, {minRowsPerWorker, minRowsPerWorker * 2}}, mb.partition(minRowsPerWorker*2))
+	assert.Len(t, mb.partition(minRowsPerWorker*100), 4)
+}
+
+// benchmarkRows is the size of the table the parallel execution
+// benchmarks run against.
+const benchmarkRows = 1 << 21
+
+func benchmarkWorkers(b *testing.B, source string) {
+	mb := newEventsBackend(b, benchmarkRows, "row")
+	slct := parseSelect(b, source)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			mb.SetWorkers(workers)
+			for i := 0; i < b.N; i++ {
+				if _, err := mb.Select(slct); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryBackend_scan(b *testing.B) {
+	benchmarkWorkers(b, "SELECT id, name FROM events;")
+}
+
+func BenchmarkMemoryBackend_filter(b *testing.B) {
+	benchmarkWorkers(b, "SELECT id FROM events WHERE kind = 3 AND id % 7 = 0;")
+}
+
+func BenchmarkMemoryBackend_aggregate(b *testing.B) {
+	benchmarkWorkers(b, "SELECT kind, count(*), min(id), max(name) FROM events GROUP BY kind;")
+}
+
+func TestMemoryBackend_columnar(t *testing.T) {
+	n := minRowsPerWorker*2 + batchSize/2
+	rowBackend := newEventsBackend(t, n, "row")
+	columnarBackend := newEventsBackend(t, n, "columnar")
+
+	sources := []string{
+		"SELECT * FROM events WHERE id < 5;",
+		"SELECT id, name FROM events WHERE kind = 3 AND id % 7 = 0 OR name = 'event 12';",
+		"SELECT name || '!' FROM events WHERE name || '!' = 'event 7!';",
+		"SELECT kind, count(*), sum(id / 1000), min(name), max(id) FROM events GROUP BY kind;",
+		"SELECT kind % 4, name, count(id) FROM events WHERE id > 100 GROUP BY kind % 4, name;",
+		"SELECT count(*), max(name) FROM events WHERE id < 0;",
+		"SELECT e.id FROM events e WHERE e.id = 9;",
+		"SELECT id FROM events WHERE name LIKE 'event 1_' OR name ~ '77$' AND id BETWEEN 5 AND 1000 OR id IN (300, 301);",
+	}
+
+	for _, source := range sources {
+		for _, workers := range []int{1, 3} {
+			rowBackend.SetWorkers(workers)
+			columnarBackend.SetWorkers(workers)
+
+			expected, err := rowBackend.Select(parseSelect(t, source))
+			assert.Nil(t, err, source)
+
+			results, err := columnarBackend.Select(parseSelect(t, source))
+			assert.Nil(t, err, source)
+			assert.Equal(t, expected, results, source)
+		}
+	}
+
+	errors := []struct {
+		source string
+		err    error
+	}{
+		{source: "SELECT id FROM events WHERE id / (id - 700) > 0;", err: ErrDivisionByZero},
+		{source: "SELECT id FROM events WHERE name > 3;", err: ErrInvalidOperands},
+		{source: "SELECT id FROM events WHERE name;", err: ErrInvalidOperands},
+		{source: "SELECT sum(name) FROM events;", err: ErrInvalidOperands},
+	}
+
+	for _, test := range errors {
+		_, err := columnarBackend.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+	}
+}
+
+func TestMemoryBackend_columnarStorage(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE users (id INT, name TEXT) USING columnar;
+INSERT INTO users VALUES (1, 'Phil');
+INSERT INTO users VALUES (2, 'Kate');
+INSERT INTO users VALUES (3, 'Phil');
+CREATE INDEX users_id ON users (id);
+ANALYZE users;
+`)
+
+	users := mb.tables["users"]
+	assert.Equal(t, 3, users.rowCount())
+	assert.Equal(t, []int32{1, 2, 3}, users.vectors[0].ints)
+	assert.Equal(t, []uint32{0, 1, 0}, users.vectors[1].codes)
+	assert.Equal(t, []string{"Phil", "Kate"}, users.vectors[1].dictionary)
+	assert.Equal(t, 2, users.statistics.columns["name"].distinct)
+
+	results, err := mb.Select(parseSelect(t, "SELECT name FROM users WHERE id = 2;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Kate|"}, sortedRows(results))
+
+	// A value that doesn't fit the column leaves the table unchanged
+	ast, err := Parse("INSERT INTO users VALUES ('x', 'y');")
+	assert.Nil(t, err)
+	_, err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.ErrorIs(t, err, ErrInvalidCast)
+	assert.Equal(t, 3, users.rowCount())
+	assert.Equal(t, 2, len(users.vectors[1].dictionary))
+
+	ast, err = Parse("CREATE TABLE t (id INT) USING heap;")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInvalidStorage, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+}
+
+func TestMemoryBackend_indexScanTypes(t *testing.T) {
+	tests := []struct {
+		source string
+		rows   []string
+		err    error
+	}{
+		{source: "SELECT name FROM users WHERE id = '2';", rows: []string{"Kate|"}},
+		{source: "SELECT name FROM users WHERE '2' = id;", rows: []string{"Kate|"}},
+		{source: "SELECT name FROM users WHERE id = 'x';", err: ErrInvalidCast},
+		{source: "SELECT name FROM users WHERE id = name;", err: ErrInvalidOperands},
+		{source: "SELECT name FROM users WHERE id = TRUE;", err: ErrInvalidOperands},
+		{source: "SELECT name FROM users WHERE id = 2.0;", rows: []string{"Kate|"}},
+		{source: "SELECT name FROM users WHERE id = 2::BIGINT;", rows: []string{"Kate|"}},
+	}
+
+	// The same conditions without an index to use, and with one
+	for _, setup := range []string{"", "CREATE INDEX users_id ON users (id);"} {
+		for _, layout := range []string{"row", "columnar"} {
+			mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE users (id INT, name TEXT) USING %s;
+INSERT INTO users VALUES (1, 'Phil');
+INSERT INTO users VALUES (2, 'Kate');
+%s
+`, layout, setup))
+
+			for _, test := range tests {
+				results, err := mb.Select(parseSelect(t, test.source))
+				assert.Equal(t, test.err, err, setup, layout, test.source)
+				if err != nil {
+					continue
+				}
+
+				assert.Equal(t, test.rows, sortedRows(results), setup, layout, test.source)
+			}
+		}
+	}
+}
+
+func benchmarkLayouts(b *testing.B, source string) {
+	slct := parseSelect(b, source)
+
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newEventsBackend(b, benchmarkRows, layout)
+		mb.SetWorkers(1)
+
+		b.Run("layout="+layout, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := mb.Select(slct); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkColumnar_filter(b *testing.B) {
+	benchmarkLayouts(b, "SELECT id FROM events WHERE kind = 3 AND id % 7 = 0;")
+}
+
+func BenchmarkColumnar_aggregate(b *testing.B) {
+	benchmarkLayouts(b, "SELECT kind, count(*), min(id), max(name) FROM events GROUP BY kind;")
+}
+
+func TestMemoryBackend_booleans(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE users (id INT, active BOOLEAN) USING %s;
+INSERT INTO users VALUES (1, TRUE);
+INSERT INTO users VALUES (2, false);
+INSERT INTO users VALUES (3, 2 > 1);
+INSERT INTO users (id) VALUES (4);
+`, layout))
+
+		tests := []struct {
+			source string
+			typ    ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT id FROM users WHERE active;",
+				typ:    IntType,
+				rows:   []string{"1|", "3|"},
+			},
+			{
+				source: "SELECT id > 1 AND active FROM users WHERE id < 3 OR FALSE;",
+				typ:    BoolType,
+				rows:   []string{"false|", "false|"},
+			},
+			{
+				source: "SELECT active, count(*) FROM users WHERE active = TRUE GROUP BY active;",
+				typ:    BoolType,
+				rows:   []string{"true|2|"},
+			},
+			{
+				source: "SELECT max(active) FROM users WHERE id <> 1;",
+				typ:    BoolType,
+				rows:   []string{"true|"},
+			},
+			{
+				// Unknown stays unknown
+				source: "SELECT id FROM users WHERE NOT active;",
+				typ:    IntType,
+				rows:   []string{"2|"},
+			},
+			{
+				source: "SELECT id FROM users WHERE NOT id = 1 AND NOT active OR id = 4;",
+				typ:    IntType,
+				rows:   []string{"2|", "4|"},
+			},
+			{
+				source: "SELECT NOT active FROM users WHERE id > 2;",
+				typ:    BoolType,
+				rows:   []string{"false|", "|"},
+			},
+			{
+				source: "SELECT id FROM users WHERE id;",
+				err:    ErrInvalidOperands,
+			},
+			{
+				source: "SELECT id FROM users WHERE NOT id;",
+				err:    ErrInvalidOperands,
+			},
+			{
+				source: "SELECT id FROM users WHERE active AND 1;",
+				err:    ErrInvalidOperands,
+			},
+			{
+				source: "SELECT id FROM users WHERE active = 1;",
+				err:    ErrInvalidOperands,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			assert.Equal(t, test.typ, results.Columns[0].Type, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_numerics(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE items (id INT, price DECIMAL(6, 2), weight REAL, ratio DOUBLE PRECISION, amount NUMERIC) USING %s;
+INSERT INTO items VALUES (1, 3.14159, 1.5, 1e-5, 0.1);
+INSERT INTO items VALUES (2, 2, 3, 2.5, 1.50);
+INSERT INTO items VALUES (3, 0.005, 0.25, 10, 1.5);
+CREATE INDEX items_amount ON items (amount);
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT id, price, weight, ratio, amount FROM items WHERE id = 1;",
+				types:  []ColumnType{IntType, DecimalType, RealType, DoubleType, DecimalType},
+				rows:   []string{"1|3.14|1.5|1e-05|0.1|"},
+			},
+			{
+				// Values are rounded to the column's scale
+				source: "SELECT price FROM items WHERE id > 1;",
+				types:  []ColumnType{DecimalType},
+				rows:   []string{"0.01|", "2.00|"},
+			},
+			{
+				source: "SELECT price * 2, price / 3, price + id, weight + 1, weight * 2 FROM items WHERE id = 1;",
+				types:  []ColumnType{DecimalType, DecimalType, DecimalType, DoubleType, DoubleType},
+				rows:   []string{"6.28|1.046667|4.14|2.5|3|"},
+			},
+			{
+				source: "SELECT 3.14, 1e-5, .5, 2.50 - 0.5, 0.1 + 0.2, 10000000000, 7 % 2.5;",
+				types:  []ColumnType{DecimalType, DecimalType, DecimalType, DecimalType, DecimalType, BigIntType, DecimalType},
+				rows:   []string{"3.14|0.00001|0.5|2.00|0.3|10000000000|2.0|"},
+			},
+			{
+				// Numbers of different types compare by value
+				source: "SELECT id FROM items WHERE price >= 2 AND weight >= 1.5 AND ratio < 2.5e1 AND amount <> 1;",
+				types:  []ColumnType{IntType},
+				rows:   []string{"1|", "2|"},
+			},
+			{
+				source: "SELECT id FROM items WHERE amount = 1.5;",
+				types:  []ColumnType{IntType},
+				rows:   []string{"2|", "3|"},
+			},
+			{
+				source: "SELECT amount, count(*), sum(price), max(ratio), min(weight) FROM items GROUP BY amount;",
+				types:  []ColumnType{DecimalType, IntType, DecimalType, DoubleType, RealType},
+				rows:   []string{"0.1|1|3.14|1e-05|1.5|", "1.50|2|2.01|10|0.25|"},
+			},
+			{
+				// Too many digits for a DECIMAL
+				source: "SELECT 1e20, 1234567890123456789.5;",
+				types:  []ColumnType{DoubleType, DoubleType},
+				rows:   []string{"1e+20|1.2345678901234568e+18|"},
+			},
+			{
+				source: "SELECT price / 0 FROM items;",
+				err:    ErrDivisionByZero,
+			},
+			{
+				source: "SELECT ratio / 0 FROM items;",
+				err:    ErrDivisionByZero,
+			},
+			{
+				source: "SELECT 999999999999999999 * 10;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT price || 'x' FROM items;",
+				err:    ErrInvalidOperands,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_numericColumns(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{source: "CREATE TABLE t (a DECIMAL(4, 1)); INSERT INTO t VALUES (999.94);"},
+		{source: "CREATE TABLE t (a DECIMAL(4, 1)); INSERT INTO t VALUES (999.95);", err: ErrNumericOverflow},
+		{source: "CREATE TABLE t (a DECIMAL(4)); INSERT INTO t VALUES (12345);", err: ErrNumericOverflow},
+		{source: "CREATE TABLE t (a INT); INSERT INTO t VALUES (1e10);", err: ErrNumericOverflow},
+		{source: "CREATE TABLE t (a REAL); INSERT INTO t VALUES (1e39);", err: ErrNumericOverflow},
+		{source: "CREATE TABLE t (a DECIMAL(19, 2));", err: ErrInvalidDatatype},
+		{source: "CREATE TABLE t (a DECIMAL(2, 3));", err: ErrInvalidDatatype},
+		{source: "CREATE TABLE t (a INT(4));", err: ErrInvalidDatatype},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+
+		mb := NewMemoryBackend()
+		err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+		if err == nil && len(ast.Statements) > 1 {
+			_, err = mb.Insert(ast.Statements[1].InsertStatement)
+		}
+
+		assert.ErrorIs(t, err, test.err, test.source)
+	}
+}
+
+func TestMemoryBackend_numericJoins(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE a (id INT);
+INSERT INTO a VALUES (1);
+INSERT INTO a VALUES (2);
+CREATE TABLE b (id DECIMAL(4, 2));
+INSERT INTO b VALUES (1);
+INSERT INTO b VALUES (2.5);
+CREATE INDEX a_id ON a (id);
+`)
+
+	slct := parseSelect(t, "SELECT a.id, b.id FROM a JOIN b ON a.id = b.id;")
+	for _, algorithm := range []joinAlgorithm{nestedLoopJoin, hashJoin, mergeJoin} {
+		p := optimize(newPlan(slct), mb)
+		p.project.child.join.algorithm = algorithm
+
+		rel, err := mb.execute(p)
+		assert.Nil(t, err, algorithm)
+		assert.Equal(t, []string{"1|1.00|"}, sortedRows(rel.results()), algorithm)
+	}
+
+	// The index on an INT column is searched for other numeric types
+	for source, rows := range map[string][]string{
+		"SELECT id FROM a WHERE id = 2.0;":  {"2|"},
+		"SELECT id FROM a WHERE id = 1.5;":  nil,
+		"SELECT id FROM a WHERE id = 1e20;": nil,
+	} {
+		p := optimize(newPlan(parseSelect(t, source)), mb)
+		assert.Equal(t, indexScanPlanKind, p.project.child.kind, source)
+
+		results, err := mb.Select(parseSelect(t, source))
+		assert.Nil(t, err, source)
+		assert.Equal(t, rows, sortedRows(results), source)
+	}
+}
+
+func TestMemoryBackend_integers(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE counts (s SMALLINT, i INT, b BIGINT) USING %s;
+INSERT INTO counts VALUES (1, 2147483647, 9999999999);
+INSERT INTO counts VALUES (-32768, -2147483648, -9223372036854775808);
+INSERT INTO counts VALUES (2, 1.6, 3);
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT s, i, b FROM counts;",
+				types:  []ColumnType{SmallIntType, IntType, BigIntType},
+				rows:   []string{"-32768|-2147483648|-9223372036854775808|", "1|2147483647|9999999999|", "2|2|3|"},
+			},
+			{
+				source: "SELECT s + 1, s + i, i * b, 9223372036854775807 FROM counts WHERE s = 2;",
+				types:  []ColumnType{IntType, IntType, BigIntType, BigIntType},
+				rows:   []string{"3|4|6|9223372036854775807|"},
+			},
+			{
+				// Many INTs are summed without overflowing
+				source: "SELECT sum(i), sum(s) FROM counts WHERE i > 0;",
+				types:  []ColumnType{BigIntType, BigIntType},
+				rows:   []string{"2147483649|3|"},
+			},
+			{
+				source: "SELECT i + 1 FROM counts;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT i * 2 FROM counts WHERE s = 1;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT i / -1 FROM counts WHERE s < 0;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT b - 1 FROM counts WHERE s < 0;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT sum(b + 9223372026854775808) FROM counts WHERE s > 0;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT -s, -i, -b, +s FROM counts WHERE s > 0;",
+				types:  []ColumnType{SmallIntType, IntType, BigIntType, SmallIntType},
+				rows:   []string{"-1|-2147483647|-9999999999|1|", "-2|-2|-3|2|"},
+			},
+			{
+				source: "SELECT -s FROM counts WHERE s < 0;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT -i FROM counts WHERE s < 0;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT -b FROM counts WHERE s < 0;",
+				err:    ErrNumericOverflow,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+
+		// Values out of a column's range are rejected, leaving the
+		// table as it was
+		for _, source := range []string{
+			"INSERT INTO counts VALUES (32768, 1, 1);",
+			"INSERT INTO counts VALUES (1, 2147483648, 1);",
+			"INSERT INTO counts VALUES (1, 1, 9223372036854775808);",
+		} {
+			ast, err := Parse(source)
+			assert.Nil(t, err, source)
+			_, err = mb.Insert(ast.Statements[0].InsertStatement)
+			assert.Equal(t, ErrNumericOverflow, err, layout, source)
+		}
+
+		results, err := mb.Select(parseSelect(t, "SELECT count(*) FROM counts;"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"3|"}, sortedRows(results), layout)
+	}
+}
+
+func TestMemoryBackend_unaryOperators(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE t (a INT, b DECIMAL(4, 1), c DOUBLE, d INTERVAL);
+INSERT INTO t VALUES (-1, 2, -2.5, INTERVAL '1 day');
+INSERT INTO t VALUES (+3, -(1.5), +(-0.5), -INTERVAL '2 hours');
+`)
+
+	tests := []struct {
+		source string
+		types  []ColumnType
+		rows   []string
+		err    error
+	}{
+		{
+			source: "SELECT -1, +1, - 1, -(-1);",
+			types:  []ColumnType{IntType, IntType, IntType, IntType},
+			rows:   []string{"-1|1|-1|1|"},
+		},
+		{
+			// The smallest value of each type is written with a minus
+			source: "SELECT -32768::SMALLINT, -2147483648, -9223372036854775808, -999999999999999999::DECIMAL(18, 0);",
+			types:  []ColumnType{SmallIntType, IntType, BigIntType, DecimalType},
+			rows:   []string{"-32768|-2147483648|-9223372036854775808|-999999999999999999|"},
+		},
+		{
+			source: "SELECT -(-32768::SMALLINT);",
+			err:    ErrNumericOverflow,
+		},
+		{
+			source: "SELECT -(-9223372036854775808);",
+			err:    ErrNumericOverflow,
+		},
+		{
+			source: "SELECT CAST(-2.5 AS INT), -2.5::INT, -(2.5::INT), -1.5e2;",
+			types:  []ColumnType{IntType, IntType, IntType, DecimalType},
+			rows:   []string{"-3|-3|-3|-150|"},
+		},
+		{
+			// Only -> and :: bind tighter
+			source: "SELECT -2 * 3, 2 - -1, -a * 2, -(a + 1), 2 * -a FROM t WHERE a < 0;",
+			types:  []ColumnType{IntType, IntType, IntType, IntType, IntType},
+			rows:   []string{"-6|3|2|0|2|"},
+		},
+		{
+			source: "SELECT a, -a, -b, -c, -d, +d FROM t;",
+			types:  []ColumnType{IntType, IntType, DecimalType, DoubleType, IntervalType, IntervalType},
+			rows:   []string{"-1|1|-2.0|2.5|-1 day|1 day|", "3|-3|1.5|0.5|02:00:00|-02:00:00|"},
+		},
+		{
+			source: "SELECT a FROM t WHERE -a > 0;",
+			types:  []ColumnType{IntType},
+			rows:   []string{"-1|"},
+		},
+		{
+			source: "SELECT -sum(a), -count(*) FROM t;",
+			types:  []ColumnType{BigIntType, IntType},
+			rows:   []string{"-2|-2|"},
+		},
+		{
+			source: "SELECT -'a';",
+			err:    ErrInvalidOperands,
+		},
+		{
+			source: "SELECT +TRUE;",
+			err:    ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		var types []ColumnType
+		for _, col := range results.Columns {
+			types = append(types, col.Type)
+		}
+
+		assert.Equal(t, test.types, types, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_datetimes(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE events (id INT, day DATE, at TIMESTAMP, starts TIME, lasts INTERVAL) USING %s;
+INSERT INTO events VALUES (1, DATE '2024-01-31', TIMESTAMP '2024-03-10 12:30:45.5', TIME '23:30', INTERVAL '1 year 2 months 3 days 04:05:06');
+INSERT INTO events VALUES (2, '2023-12-25', '2024-01-01', '08:00', '90 minutes');
+INSERT INTO events VALUES (3, '1969-12-31', '1969-12-31 23:59:59', '00:00:00.25', '-1 day');
+CREATE INDEX events_day ON events (day);
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT day, at, starts, lasts FROM events WHERE id = 1;",
+				types:  []ColumnType{DateType, TimestampType, TimeType, IntervalType},
+				rows:   []string{"2024-01-31|2024-03-10 12:30:45.5|23:30:00|1 year 2 mons 3 days 04:05:06|"},
+			},
+			{
+				// Adding a month keeps to the end of a shorter month
+				source: "SELECT day + 1, day - 1, day + INTERVAL '1 month', day - DATE '2024-01-01', at + lasts FROM events WHERE id = 1;",
+				types:  []ColumnType{DateType, DateType, TimestampType, IntType, TimestampType},
+				rows:   []string{"2024-02-01|2024-01-30|2024-02-29 00:00:00|30|2025-05-13 16:35:51.5|"},
+			},
+			{
+				source: "SELECT at - TIMESTAMP '2024-01-01', starts + INTERVAL '1 hour', starts - TIME '08:00', lasts * 2, lasts / 2 FROM events WHERE id < 3;",
+				types:  []ColumnType{IntervalType, TimeType, IntervalType, IntervalType, IntervalType},
+				rows: []string{
+					"00:00:00|09:00:00|00:00:00|03:00:00|00:45:00|",
+					"69 days 12:30:45.5|00:30:00|15:30:00|2 years 4 mons 6 days 08:10:12|7 mons 1 day 14:02:33|",
+				},
+			},
+			{
+				// A date less a timestamp is taken as midnight on that date
+				source: "SELECT at - day, day - at, DATE '2024-01-01' - TIMESTAMP '2024-01-01 06:00' FROM events WHERE id = 1;",
+				types:  []ColumnType{IntervalType, IntervalType, IntervalType},
+				rows:   []string{"39 days 12:30:45.5|-39 days -12:30:45.5|-06:00:00|"},
+			},
+			{
+				// Text compares as the date or time it's compared with
+				source: "SELECT id FROM events WHERE day < '2024-01-01' AND at >= DATE '1969-12-31';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"2|", "3|"},
+			},
+			{
+				source: "SELECT id FROM events WHERE day = DATE '2023-12-25';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"2|"},
+			},
+			{
+				// Intervals compare by their length
+				source: "SELECT id FROM events WHERE lasts > INTERVAL '1 hour' AND lasts < INTERVAL '2 years';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"1|", "2|"},
+			},
+			{
+				source: "SELECT min(day), max(at), max(lasts) FROM events;",
+				types:  []ColumnType{DateType, TimestampType, IntervalType},
+				rows:   []string{"1969-12-31|2024-03-10 12:30:45.5|1 year 2 mons 3 days 04:05:06|"},
+			},
+			{
+				source: "SELECT EXTRACT(year FROM day), EXTRACT(dow FROM day), EXTRACT(second FROM at), EXTRACT(hour FROM starts), EXTRACT(epoch FROM lasts) FROM events WHERE id = 1;",
+				types:  []ColumnType{DecimalType, DecimalType, DecimalType, DecimalType, DecimalType},
+				rows:   []string{"2024|3|45.500000|23|37015506.000000|"},
+			},
+			{
+				source: "SELECT date_trunc('month', at), date_trunc('week', at), date_trunc('year', day), date_trunc('day', at) FROM events WHERE id = 3;",
+				types:  []ColumnType{TimestampType, TimestampType, TimestampType, TimestampType},
+				rows:   []string{"1969-12-01 00:00:00|1969-12-29 00:00:00|1969-01-01 00:00:00|1969-12-31 00:00:00|"},
+			},
+			{
+				source: "SELECT INTERVAL '1 day' = INTERVAL '24 hours', INTERVAL '1 mon' > INTERVAL '29 days';",
+				types:  []ColumnType{BoolType, BoolType},
+				rows:   []string{"true|true|"},
+			},
+			{
+				source: "SELECT now() > TIMESTAMP '2024-01-01';",
+				types:  []ColumnType{BoolType},
+				rows:   []string{"true|"},
+			},
+			{
+				source: "SELECT DATE '2024-02-30';",
+				err:    ErrInvalidDatetime,
+			},
+			{
+				source: "SELECT EXTRACT(century FROM day) FROM events;",
+				err:    ErrInvalidArguments,
+			},
+			{
+				source: "SELECT day + at FROM events;",
+				err:    ErrInvalidOperands,
+			},
+			{
+				source: "SELECT date_trunc('month', starts) FROM events;",
+				err:    ErrInvalidArguments,
+			},
+			{
+				source: "SELECT today() FROM events;",
+				err:    ErrFunctionDoesNotExist,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_textColumns(t *testing.T) {
+	tests := []struct {
+		source string
+		rows   []string
+		err    error
+	}{
+		{source: "CREATE TABLE t (a VARCHAR(3)); INSERT INTO t VALUES ('abc');", rows: []string{"abc|"}},
+		{source: "CREATE TABLE t (a VARCHAR(3)); INSERT INTO t VALUES ('ab');", rows: []string{"ab|"}},
+		{source: "CREATE TABLE t (a VARCHAR(3)); INSERT INTO t VALUES ('abcd');", err: ErrValueTooLong},
+		{source: "CREATE TABLE t (a VARCHAR(3)); INSERT INTO t VALUES ('ab    ');", rows: []string{"ab |"}},
+		{source: "CREATE TABLE t (a VARCHAR(3)); INSERT INTO t VALUES ('äöü');", rows: []string{"äöü|"}},
+		{source: "CREATE TABLE t (a VARCHAR); INSERT INTO t VALUES ('unlimited');", rows: []string{"unlimited|"}},
+		{source: "CREATE TABLE t (a CHAR(4)); INSERT INTO t VALUES ('ab');", rows: []string{"ab  |"}},
+		{source: "CREATE TABLE t (a CHAR(4)) USING columnar; INSERT INTO t VALUES ('abcde');", err: ErrValueTooLong},
+		{source: "CREATE TABLE t (a CHAR); INSERT INTO t VALUES ('');", rows: []string{" |"}},
+		{source: "CREATE TABLE t (a VARCHAR(0));", err: ErrInvalidDatatype},
+		{source: "CREATE TABLE t (a CHAR(2, 1));", err: ErrInvalidDatatype},
+		{source: "CREATE TABLE t (a TEXT(2));", err: ErrInvalidDatatype},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+
+		mb := NewMemoryBackend()
+		err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+		if err == nil && len(ast.Statements) > 1 {
+			_, err = mb.Insert(ast.Statements[1].InsertStatement)
+		}
+
+		assert.ErrorIs(t, err, test.err, test.source)
+		if err != nil || test.rows == nil {
+			continue
+		}
+
+		results, err := mb.Select(parseSelect(t, "SELECT a FROM t;"))
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_charPadding(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE codes (c CHAR(3), v VARCHAR(3)) USING %s;
+INSERT INTO codes VALUES ('ab', 'ab');
+INSERT INTO codes VALUES ('ab ', 'ab ');
+INSERT INTO codes VALUES ('abc', 'abc');
+CREATE TABLE names (name TEXT);
+INSERT INTO names VALUES ('ab');
+`, layout))
+
+		tests := []struct {
+			source string
+			rows   []string
+		}{
+			{source: "SELECT c FROM codes WHERE c = 'ab';", rows: []string{"ab |", "ab |"}},
+			{source: "SELECT c || '|' FROM codes;", rows: []string{"abc||", "ab||", "ab||"}},
+			{source: "SELECT v FROM codes WHERE v = 'ab';", rows: []string{"ab|"}},
+			{source: "SELECT c, count(*) FROM codes GROUP BY c;", rows: []string{"ab |2|", "abc|1|"}},
+			{source: "SELECT c FROM codes WHERE c < 'abc';", rows: []string{"ab |", "ab |"}},
+			{source: "SELECT count(*) FROM codes JOIN names ON c = name;", rows: []string{"2|"}},
+			{source: "SELECT CAST('a' AS CHAR(3)) || '|', CAST('a' AS CHAR(3));", rows: []string{"a||a  |"}},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Nil(t, err, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+
+		// An index finds the padded values by their unpadded text too
+		ast, err := Parse("CREATE INDEX codes_c ON codes (c);")
+		assert.Nil(t, err)
+		assert.Nil(t, mb.CreateIndex(ast.Statements[0].CreateIndexStatement), layout)
+
+		results, err := mb.Select(parseSelect(t, "SELECT c FROM codes WHERE c = 'ab';"))
+		assert.Nil(t, err, layout)
+		assert.Equal(t, []string{"ab |", "ab |"}, sortedRows(results), layout)
+	}
+}
+
+func TestMemoryBackend_blobs(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE files (id INT, data BYTEA, thumbnail BLOB) USING %s;
+INSERT INTO files VALUES (1, X'DEADBEEF', x'');
+INSERT INTO files VALUES (2, X'00ff', X'0A');
+INSERT INTO files VALUES (3, X'00FF', X'0B');
+CREATE INDEX files_data ON files (data);
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT id, data, thumbnail FROM files;",
+				types:  []ColumnType{IntType, BlobType, BlobType},
+				rows:   []string{"1|\\xdeadbeef|\\x|", "2|\\x00ff|\\x0a|", "3|\\x00ff|\\x0b|"},
+			},
+			{
+				source: "SELECT id FROM files WHERE data = X'00FF';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"2|", "3|"},
+			},
+			{
+				// Binary strings compare byte by byte
+				source: "SELECT id FROM files WHERE data > X'00' AND thumbnail < X'0B';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"1|", "2|"},
+			},
+			{
+				source: "SELECT data || thumbnail, X'01' || X'02' FROM files WHERE id = 2;",
+				types:  []ColumnType{BlobType, BlobType},
+				rows:   []string{"\\x00ff0a|\\x0102|"},
+			},
+			{
+				source: "SELECT data, count(*) FROM files GROUP BY data;",
+				types:  []ColumnType{BlobType, IntType},
+				rows:   []string{"\\x00ff|2|", "\\xdeadbeef|1|"},
+			},
+			{
+				source: "SELECT data || 'text' FROM files;",
+				err:    ErrInvalidOperands,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_json(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE events (id INT, payload JSON) USING %s;
+INSERT INTO events VALUES (1, '{"type": "click", "pos": {"x": 1, "y": 2}, "tags": ["a", "b"]}');
+INSERT INTO events VALUES (2, '{"type": "view", "n": null}');
+INSERT INTO events VALUES (3, '[1, 2, 3]');
+CREATE INDEX events_type ON events (payload ->> 'type');
+INSERT INTO events VALUES (4, '{"type": "click"}');
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT payload -> 'pos', payload -> 'pos' ->> 'x', payload -> 'tags' -> 0, payload -> 'tags' ->> 1 FROM events WHERE id = 1;",
+				types:  []ColumnType{JsonType, TextType, JsonType, TextType},
+				rows:   []string{`{"x": 1, "y": 2}|1|"a"|b|`},
+			},
+			{
+				// Missing members, nulls and indexing objects are unknown
+				source: "SELECT id, payload ->> 'n', payload -> 'n', payload -> 0 FROM events WHERE id = 2;",
+				types:  []ColumnType{IntType, TextType, JsonType, JsonType},
+				rows:   []string{"2||null||"},
+			},
+			{
+				source: "SELECT id FROM events WHERE payload ->> 'type' = 'click';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"1|", "4|"},
+			},
+			{
+				source: "SELECT id FROM events WHERE payload ->> 2 = '3' OR payload -> 'pos' ->> 'y' = '2';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"1|", "3|"},
+			},
+			{
+				source: "SELECT json_extract(payload, '$.pos.y'), json_extract(payload, '$.tags[1]'), json_extract(payload, '$.missing'), json_array_length(payload -> 'tags'), json_object_keys(payload) FROM events WHERE id = 1;",
+				types:  []ColumnType{JsonType, JsonType, JsonType, IntType, JsonType},
+				rows:   []string{`2|"b"||2|["type","pos","tags"]|`},
+			},
+			{
+				source: "SELECT json_array_length(payload), json_extract(payload, '$[1]') FROM events WHERE id = 3;",
+				types:  []ColumnType{IntType, JsonType},
+				rows:   []string{"3|2|"},
+			},
+			{
+				// Text is read as JSON
+				source: `SELECT '{"a": [10, 20]}' -> 'a' ->> 1;`,
+				types:  []ColumnType{TextType},
+				rows:   []string{"20|"},
+			},
+			{
+				source: "SELECT payload ->> 'type', count(*) FROM events GROUP BY payload ->> 'type';",
+				types:  []ColumnType{TextType, IntType},
+				rows:   []string{"click|2|", "view|1|", "|1|"},
+			},
+			{
+				source: "SELECT json_array_length(payload) FROM events WHERE id = 1;",
+				err:    ErrInvalidArguments,
+			},
+			{
+				source: "SELECT json_extract(payload, 'pos') FROM events;",
+				err:    ErrInvalidArguments,
+			},
+			{
+				source: "SELECT '{bad' -> 'a';",
+				err:    ErrInvalidJSON,
+			},
+			{
+				source: "SELECT payload -> 1.5 FROM events;",
+				err:    ErrInvalidOperands,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+
+		ast, err := Parse("INSERT INTO events VALUES (5, '{\"type\": ');")
+		assert.Nil(t, err)
+		_, err = mb.Insert(ast.Statements[0].InsertStatement)
+		assert.ErrorIs(t, err, ErrInvalidJSON, layout)
+	}
+}
+
+func TestMemoryBackend_uuids(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE users (id UUID DEFAULT gen_random_uuid(), name TEXT, team UUID DEFAULT '00000000-0000-0000-0000-000000000000') USING %s;
+INSERT INTO users VALUES ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', 'Kate', '6ba7b8109dad11d180b400c04fd430c8');
+INSERT INTO users VALUES (DEFAULT, 'Tom', DEFAULT);
+INSERT INTO users VALUES (DEFAULT, 'Ann', '6ba7b810-9dad-11d1-80b4-00c04fd430c8');
+CREATE INDEX users_id ON users (id);
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				source: "SELECT id, team FROM users WHERE name = 'Kate';",
+				types:  []ColumnType{UuidType, UuidType},
+				rows:   []string{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11|6ba7b810-9dad-11d1-80b4-00c04fd430c8|"},
+			},
+			{
+				// Text compares as the UUID it's compared with
+				source: "SELECT name FROM users WHERE id = 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11';",
+				types:  []ColumnType{TextType},
+				rows:   []string{"Kate|"},
+			},
+			{
+				source: "SELECT team, count(*) FROM users GROUP BY team;",
+				types:  []ColumnType{UuidType, IntType},
+				rows:   []string{"00000000-0000-0000-0000-000000000000|1|", "6ba7b810-9dad-11d1-80b4-00c04fd430c8|2|"},
+			},
+			{
+				source: "SELECT id = 'not a uuid' FROM users;",
+				err:    ErrInvalidUUID,
+			},
+			{
+				source: "SELECT DEFAULT;",
+				err:    ErrMisplacedDefault,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+
+		// Generated UUIDs are random, version 4 and variant 1
+		results, err := mb.Select(parseSelect(t, "SELECT id FROM users WHERE name <> 'Kate';"))
+		assert.Nil(t, err, layout)
+		assert.Len(t, results.Rows, 2, layout)
+		first, second := results.Rows[0][0].AsUUID(), results.Rows[1][0].AsUUID()
+		assert.NotEqual(t, first, second, layout)
+		for _, u := range []UUID{first, second} {
+			assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", u.String(), layout)
+		}
+	}
+}
+
+func TestMemoryBackend_defaults(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{source: "CREATE TABLE t (a INT DEFAULT 1 + 2, b TEXT); INSERT INTO t VALUES (DEFAULT, DEFAULT);"},
+		{source: "CREATE TABLE t (a UUID); INSERT INTO t VALUES ('a0eebc99');", err: ErrInvalidUUID},
+		{source: "CREATE TABLE t (a INT DEFAULT b, b INT);", err: ErrColumnDoesNotExist},
+		{source: "CREATE TABLE t (a INT DEFAULT 1); INSERT INTO t VALUES (DEFAULT + 1);", err: ErrMisplacedDefault},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+
+		mb := NewMemoryBackend()
+		err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+		if err == nil && len(ast.Statements) > 1 {
+			_, err = mb.Insert(ast.Statements[1].InsertStatement)
+		}
+
+		assert.ErrorIs(t, err, test.err, test.source)
+	}
+
+	mb := newTestBackend(t, "CREATE TABLE t (a INT DEFAULT 1 + 2, b TEXT DEFAULT 'x', c TEXT); INSERT INTO t VALUES (DEFAULT, DEFAULT, DEFAULT);")
+	results, err := mb.Select(parseSelect(t, "SELECT a, b, c FROM t;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3|x||"}, sortedRows(results))
+}
+
+func TestMemoryBackend_casts(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE items (id INT, name VARCHAR(4), price DECIMAL(5, 2), added TIMESTAMP) USING %s;
+INSERT INTO items VALUES ('1', 'pen', '1.5', '2024-03-01 10:30:00');
+INSERT INTO items VALUES (2, 42, 3, '2024-03-02');
+CREATE INDEX items_added ON items (added);
+`, layout))
+
+		tests := []struct {
+			source string
+			types  []ColumnType
+			rows   []string
+			err    error
+		}{
+			{
+				// Text and numbers are converted on insert
+				source: "SELECT id, name, price FROM items;",
+				types:  []ColumnType{IntType, TextType, DecimalType},
+				rows:   []string{"1|pen|1.50|", "2|42|3.00|"},
+			},
+			{
+				source: "SELECT CAST(price AS INT), price::text, id::boolean FROM items;",
+				types:  []ColumnType{IntType, TextType, BoolType},
+				rows:   []string{"2|1.50|true|", "3|3.00|true|"},
+			},
+			{
+				source: "SELECT added::date, added::time FROM items WHERE id = 1;",
+				types:  []ColumnType{DateType, TimeType},
+				rows:   []string{"2024-03-01|10:30:00|"},
+			},
+			{
+				// Dates compare with timestamps as midnight of their day
+				source: "SELECT id FROM items WHERE added = DATE '2024-03-02';",
+				types:  []ColumnType{IntType},
+				rows:   []string{"2|"},
+			},
+			{
+				source: "SELECT '12'::int + 1, CAST('abcdef' AS VARCHAR(3)), CAST(1.005 AS DECIMAL(3, 2)), true::int;",
+				types:  []ColumnType{IntType, TextType, DecimalType, IntType},
+				rows:   []string{"13|abc|1.01|1|"},
+			},
+			{
+				source: "SELECT 'x'::int;",
+				err:    ErrInvalidCast,
+			},
+			{
+				source: "SELECT '99999999999'::int;",
+				err:    ErrNumericOverflow,
+			},
+			{
+				source: "SELECT added::boolean FROM items;",
+				err:    ErrInvalidCast,
+			},
+			{
+				source: "SELECT 1::uuid;",
+				err:    ErrInvalidCast,
+			},
+			{
+				// A quoted number compares as a number, as a quoted
+				// date does as a date
+				source: "SELECT id FROM items WHERE id = '1' OR price > '2.5' OR id IN ('7', 8);",
+				types:  []ColumnType{IntType},
+				rows:   []string{"1|", "2|"},
+			},
+			{
+				source: "SELECT 10 > '9', '10' > '9';",
+				types:  []ColumnType{BoolType, BoolType},
+				rows:   []string{"true|false|"},
+			},
+			{
+				source: "SELECT id FROM items WHERE id = 'x';",
+				err:    ErrInvalidCast,
+			},
+			{
+				// Text from a column isn't implicitly a number
+				source: "SELECT id FROM items WHERE name = 42;",
+				err:    ErrInvalidOperands,
+			},
+		}
+
+		for _, test := range tests {
+			results, err := mb.Select(parseSelect(t, test.source))
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			var types []ColumnType
+			for _, col := range results.Columns {
+				types = append(types, col.Type)
+			}
+
+			assert.Equal(t, test.types, types, layout, test.source)
+			assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+		}
+
+		// A value that can't be converted is an error rather than a
+		// corrupt cell
+		for _, source := range []string{
+			"INSERT INTO items VALUES ('one', 'pen', 1, '2024-03-01');",
+			"INSERT INTO items VALUES (3, 'pencil', 1, '2024-03-01');",
+			"INSERT INTO items VALUES (3, 'pen', 1, true);",
+		} {
+			ast, err := Parse(source)
+			assert.Nil(t, err, source)
+			_, err = mb.Insert(ast.Statements[0].InsertStatement)
+			assert.NotNil(t, err, layout, source)
+		}
+
+		results, err := mb.Select(parseSelect(t, "SELECT count(*) FROM items;"))
+		assert.Nil(t, err, layout)
+		assert.Equal(t, []string{"2|"}, sortedRows(results), layout)
+	}
+}
+
+func TestMemoryBackend_insertTypes(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{
+			source: "INSERT INTO t VALUES (true, true, '2024-01-01', 'x');",
+			err:    &ColumnTypeError{Column: "id", Expected: IntType, Actual: BoolType, Err: ErrInvalidDatatype},
+		},
+		{
+			source: "INSERT INTO t VALUES (1, 1, '2024-01-01', 'x');",
+			err:    &ColumnTypeError{Column: "done", Expected: BoolType, Actual: IntType, Err: ErrInvalidDatatype},
+		},
+		{
+			source: "INSERT INTO t VALUES (1, true, 'soon', 'x');",
+			err:    &ColumnTypeError{Column: "due", Expected: DateType, Actual: TextType, Err: ErrInvalidDatetime},
+		},
+		{
+			source: "INSERT INTO t VALUES ('one', true, '2024-01-01', 'x');",
+			err:    &ColumnTypeError{Column: "id", Expected: IntType, Actual: TextType, Err: ErrInvalidCast},
+		},
+		{
+			// Any value can be stored as text
+			source: "INSERT INTO t VALUES ('1', 'yes', DATE '2024-01-01' + 1, 2.50);",
+		},
+	}
+
+	for _, test := range tests {
+		mb := newTestBackend(t, "CREATE TABLE t (id INT, done BOOLEAN, due DATE, note TEXT);")
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+		_, err = mb.Insert(ast.Statements[0].InsertStatement)
+		assert.Equal(t, test.err, err, test.source)
+	}
+
+	typeErr := &ColumnTypeError{Column: "id", Expected: IntType, Actual: BoolType, Err: ErrInvalidDatatype}
+	assert.Equal(t, "column id is of type int but value is of type boolean: invalid datatype", typeErr.Error())
+	assert.ErrorIs(t, typeErr, ErrInvalidDatatype)
+
+	mb := newTestBackend(t, "CREATE TABLE t (id INT, done BOOLEAN, due DATE, note TEXT); INSERT INTO t VALUES ('1', 'yes', DATE '2024-01-01' + 1, 2.50);")
+	results, err := mb.Select(parseSelect(t, "SELECT id, done, due, note FROM t;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1|true|2024-01-02|2.50|"}, sortedRows(results))
+}
+
+func TestMemoryBackend_insertRows(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE users (id INT, name TEXT DEFAULT 'anon', age INT);
+INSERT INTO users (age, id) VALUES (30, 1), (40, 2);
+INSERT INTO users (id, name) VALUES (3, 'Kate');
+INSERT INTO users VALUES (4, 'Phil', 50), (5, DEFAULT, 60);
+CREATE TABLE names (id BIGINT, name TEXT) USING columnar;
+CREATE INDEX names_id ON names (id);
+INSERT INTO names SELECT id, name FROM users WHERE age > 35;
+INSERT INTO names (name, id) SELECT 'copy', id + 10 FROM users WHERE id = 1;
+`)
+
+	results, err := mb.Select(parseSelect(t, "SELECT id, name, age FROM users;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1|anon|30|", "2|anon|40|", "3|Kate||", "4|Phil|50|", "5|anon|60|"}, sortedRows(results))
+
+	results, err = mb.Select(parseSelect(t, "SELECT id, name FROM names;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"11|copy|", "2|anon|", "4|Phil|", "5|anon|"}, sortedRows(results))
+
+	results, err = mb.Select(parseSelect(t, "SELECT name FROM names WHERE id = 4;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Phil|"}, sortedRows(results))
+
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{source: "INSERT INTO users (id, id) VALUES (1, 1);", err: ErrDuplicateColumn},
+		{source: "INSERT INTO users (id, email) VALUES (1, 'x');", err: ErrColumnDoesNotExist},
+		{source: "INSERT INTO users (id) VALUES (6), (7, 'x');", err: ErrMissingValues},
+		{source: "INSERT INTO names SELECT id FROM users;", err: ErrMissingValues},
+		// A failing row leaves the table unchanged
+		{source: "INSERT INTO names VALUES (6, 'a'), ('seven', 'b');", err: ErrInvalidCast},
+		{source: "INSERT INTO names SELECT id, name FROM users WHERE id = 3;"},
+		// Columnar tables store unknown values too
+		{source: "INSERT INTO names (id) VALUES (6);"},
+		{source: "INSERT INTO names SELECT age, nullif(name, name) FROM users WHERE id = 3;"},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		if !assert.Nil(t, err, test.source) {
+			continue
+		}
+
+		_, err = mb.Insert(ast.Statements[0].InsertStatement)
+		assert.ErrorIs(t, err, test.err, test.source)
+	}
+
+	results, err = mb.Select(parseSelect(t, "SELECT count(*), count(id), count(name), max(name) FROM names;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"7|6|5|copy|"}, sortedRows(results))
+
+	// Unknown values are unknown to expressions evaluated a batch at a
+	// time too
+	mb = newTestBackend(t, `
+CREATE TABLE tags (id INT UNIQUE, n INT, label TEXT) USING columnar;
+INSERT INTO tags VALUES (1, 1, 'a'), (2, 2, 'b'), (3, 3, 'c');
+INSERT INTO tags (id) VALUES (4);
+INSERT INTO tags VALUES (1, 5, 'a') ON CONFLICT (id) DO UPDATE SET n = nullif(1, 1);
+`)
+
+	columnarTests := []struct {
+		source string
+		rows   []string
+	}{
+		{
+			source: "SELECT id, n, n + 1, label || '!' FROM tags;",
+			rows:   []string{"1|||a!|", "2|2|3|b!|", "3|3|4|c!|", "4||||"},
+		},
+		{
+			source: "SELECT id FROM tags WHERE n > 2 OR label = 'a';",
+			rows:   []string{"1|", "3|"},
+		},
+		{
+			source: "SELECT id FROM tags WHERE n < 3 AND label <> 'z';",
+			rows:   []string{"2|"},
+		},
+		{
+			source: "SELECT label, count(*), count(n), sum(n), min(n) FROM tags WHERE id > 1 GROUP BY label;",
+			rows:   []string{"b|1|1|2|2|", "c|1|1|3|3|", "|1|0|||"},
+		},
+	}
+
+	for _, test := range columnarTests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_insertReturning(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE users (id UUID DEFAULT gen_random_uuid(), name TEXT, age INT DEFAULT 18) USING %s;
+CREATE TABLE staff (name TEXT) USING %s;
+INSERT INTO staff VALUES ('Phil'), ('Sam');
+`, layout, layout))
+
+		tests := []struct {
+			source  string
+			columns []ResultColumn
+			rows    []string
+			err     error
+		}{
+			{
+				source:  "INSERT INTO users (name) VALUES ('Kate') RETURNING name, age + 1 AS next;",
+				columns: []ResultColumn{{Type: TextType, Name: "name"}, {Type: IntType, Name: "next"}},
+				rows:    []string{"Kate|19|"},
+			},
+			{
+				source:  "INSERT INTO users (name, age) SELECT name, 30 FROM staff RETURNING *;",
+				columns: []ResultColumn{{Type: UuidType, Name: "id"}, {Type: TextType, Name: "name"}, {Type: IntType, Name: "age"}},
+			},
+			{
+				source: "INSERT INTO users (name) VALUES ('Ann') RETURNING email;",
+				err:    ErrColumnDoesNotExist,
+			},
+			{
+				source: "INSERT INTO users (name) VALUES ('Ann') RETURNING count(*);",
+				err:    ErrMisplacedAggregate,
+			},
+		}
+
+		for _, test := range tests {
+			ast, err := Parse(test.source)
+			assert.Nil(t, err, test.source)
+
+			results, err := mb.Insert(ast.Statements[0].InsertStatement)
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err != nil {
+				continue
+			}
+
+			for i := range results.Columns {
+				results.Columns[i].table = ""
+			}
+
+			assert.Equal(t, test.columns, results.Columns, layout, test.source)
+			if test.rows != nil {
+				assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+			}
+		}
+
+		// Failed inserts stored nothing
+		results, err := mb.Select(parseSelect(t, "SELECT name FROM users;"))
+		assert.Nil(t, err, layout)
+		assert.Equal(t, []string{"Kate|", "Phil|", "Sam|"}, sortedRows(results), layout)
+
+		// The generated id returned is the one stored
+		ast, err := Parse("INSERT INTO users (name) VALUES ('Tom') RETURNING id;")
+		assert.Nil(t, err)
+		results, err = mb.Insert(ast.Statements[0].InsertStatement)
+		assert.Nil(t, err, layout)
+		id := results.Rows[0][0].AsUUID()
+		results, err = mb.Select(parseSelect(t, fmt.Sprintf("SELECT name FROM users WHERE id = '%s';", id)))
+		assert.Nil(t, err, layout)
+		assert.Equal(t, []string{"Tom|"}, sortedRows(results), layout)
+
+		// Without RETURNING there are no results
+		ast, err = Parse("INSERT INTO staff VALUES ('Ann');")
+		assert.Nil(t, err)
+		results, err = mb.Insert(ast.Statements[0].InsertStatement)
+		assert.Nil(t, err, layout)
+		assert.Nil(t, results, layout)
+	}
+}
+
+func TestMemoryBackend_upsert(t *testing.T) {
+	for _, layout := range []string{"row", "columnar"} {
+		mb := newTestBackend(t, fmt.Sprintf(`
+CREATE TABLE stock (sku TEXT PRIMARY KEY, warehouse INT, qty INT DEFAULT 0, code TEXT, UNIQUE (code, warehouse)) USING %s;
+CREATE INDEX stock_qty ON stock (qty);
+INSERT INTO stock VALUES ('a', 1, 5, 'x'), ('b', 1, 3, 'y');
+`, layout))
+
+		tests := []struct {
+			source string
+			rows   []string
+			err    error
+		}{
+			{
+				source: "INSERT INTO stock VALUES ('a', 2, 1, 'z');",
+				err:    ErrUniqueViolation,
+			},
+			{
+				source: "INSERT INTO stock VALUES ('c', 1, 1, 'x');",
+				err:    ErrUniqueViolation,
+			},
+			{
+				// The same code in another warehouse doesn't conflict
+				source: "INSERT INTO stock VALUES ('c', 2, 1, 'x') RETURNING sku;",
+				rows:   []string{"c|"},
+			},
+			{
+				source: "INSERT INTO stock VALUES ('a', 3, 1, 'p'), ('d', 3, 2, 'q') ON CONFLICT (sku) DO NOTHING RETURNING sku;",
+				rows:   []string{"d|"},
+			},
+			{
+				source: "INSERT INTO stock VALUES ('e', 1, 1, 'y') ON CONFLICT DO NOTHING RETURNING sku;",
+			},
+			{
+				source: "INSERT INTO stock VALUES ('a', 1, 2, 'x'), ('f', 4, 7, 'f') ON CONFLICT (sku) DO UPDATE SET qty = stock.qty + excluded.qty RETURNING sku, qty;",
+				rows:   []string{"a|7|", "f|7|"},
+			},
+			{
+				source: "INSERT INTO stock VALUES ('a', 1, 1, 'x'), ('a', 1, 1, 'x') ON CONFLICT (sku) DO UPDATE SET qty = 0;",
+				err:    ErrRowAffectedTwice,
+			},
+			{
+				// The new code conflicts with b's
+				source: "INSERT INTO stock VALUES ('a', 1, 1, 'x') ON CONFLICT (sku) DO UPDATE SET code = 'y';",
+				err:    ErrUniqueViolation,
+			},
+			{
+				source: "INSERT INTO stock VALUES ('a', 1, 1, 'x') ON CONFLICT (warehouse) DO NOTHING;",
+				err:    ErrNoConflictConstraint,
+			},
+			{
+				source: "INSERT INTO stock VALUES ('a', 1, 1, 'x') ON CONFLICT (sku) DO UPDATE SET qty = excluded.missing;",
+				err:    ErrColumnDoesNotExist,
+			},
+			{
+				// Conflicts on the constraint named are handled
+				source: "INSERT INTO stock VALUES ('a', 1, 0, 'x') ON CONFLICT (warehouse, code) DO UPDATE SET code = 'w' RETURNING sku, code;",
+				rows:   []string{"a|w|"},
+			},
+		}
+
+		for _, test := range tests {
+			ast, err := Parse(test.source)
+			if !assert.Nil(t, err, test.source) {
+				continue
+			}
+
+			results, err := mb.Insert(ast.Statements[0].InsertStatement)
+			assert.Equal(t, test.err, err, layout, test.source)
+			if err == nil && results != nil {
+				assert.Equal(t, test.rows, sortedRows(results), layout, test.source)
+			}
+		}
+
+		results, err := mb.Select(parseSelect(t, "SELECT sku, warehouse, qty, code FROM stock;"))
+		assert.Nil(t, err, layout)
+		assert.Equal(t, []string{"a|1|7|w|", "b|1|3|y|", "c|2|1|x|", "d|3|2|q|", "f|4|7|f|"}, sortedRows(results), layout)
+
+		// The index follows updated rows
+		results, err = mb.Select(parseSelect(t, "SELECT sku FROM stock WHERE qty = 7;"))
+		assert.Nil(t, err, layout)
+		assert.Equal(t, []string{"a|", "f|"}, sortedRows(results), layout)
+
+		results, err = mb.Select(parseSelect(t, "SELECT sku FROM stock WHERE qty = 5;"))
+		assert.Nil(t, err, layout)
+		assert.Nil(t, sortedRows(results), layout)
+	}
+
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{source: "CREATE TABLE t (a INT PRIMARY KEY, b INT PRIMARY KEY);", err: ErrMultiplePrimaryKeys},
+		{source: "CREATE TABLE t (a INT, UNIQUE (a, c));", err: ErrColumnDoesNotExist},
+		{source: "CREATE TABLE t (a INT PRIMARY KEY, b INT); INSERT INTO t (b) VALUES (1);", err: ErrNullViolation},
+		// Unknown values never conflict
+		{source: "CREATE TABLE t (a INT UNIQUE, b INT); INSERT INTO t (b) VALUES (1), (2);"},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+
+		mb := NewMemoryBackend()
+		err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+		if err == nil && len(ast.Statements) > 1 {
+			_, err = mb.Insert(ast.Statements[1].InsertStatement)
+		}
+
+		assert.Equal(t, test.err, err, test.source)
+	}
+}
+
+func TestMemoryBackend_sequences(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE SEQUENCE counter;
+CREATE SEQUENCE down START WITH 10 INCREMENT BY -5;
+CREATE TABLE orders (id SERIAL PRIMARY KEY, item TEXT);
+CREATE TABLE events (id BIGINT GENERATED ALWAYS AS IDENTITY, name TEXT);
+CREATE TABLE tags (id INT GENERATED BY DEFAULT AS IDENTITY UNIQUE, tag TEXT);
+`)
+
+	tests := []struct {
+		source string
+		rows   []string
+		err    error
+	}{
+		{
+			source: "SELECT currval('counter');",
+			err:    ErrSequenceNotCalled,
+		},
+		{
+			source: "SELECT nextval('counter'), nextval('down');",
+			rows:   []string{"1|10|"},
+		},
+		{
+			source: "SELECT nextval('counter'), nextval('down'), currval('counter');",
+			rows:   []string{"2|5|2|"},
+		},
+		{
+			source: "SELECT nextval('missing');",
+			err:    ErrSequenceDoesNotExist,
+		},
+		{
+			source: "SELECT nextval(1);",
+			err:    ErrInvalidArguments,
+		},
+		{
+			source: "CREATE SEQUENCE counter;",
+			err:    ErrSequenceExists,
+		},
+		{
+			source: "CREATE SEQUENCE still INCREMENT 0;",
+			err:    ErrInvalidIncrement,
+		},
+		{
+			source: "INSERT INTO orders (item) VALUES ('a'), ('b') RETURNING id;",
+			rows:   []string{"1|", "2|"},
+		},
+		{
+			source: "INSERT INTO orders VALUES (DEFAULT, 'c') RETURNING id, item;",
+			rows:   []string{"3|c|"},
+		},
+		{
+			// Values taken by a failing INSERT are not given back
+			source: "INSERT INTO orders (item) VALUES ('d'), (CAST(1 / 0 AS TEXT));",
+			err:    ErrDivisionByZero,
+		},
+		{
+			source: "INSERT INTO orders VALUES (2, 'e');",
+			err:    ErrUniqueViolation,
+		},
+		{
+			source: "INSERT INTO orders (item) VALUES ('f') RETURNING id;",
+			rows:   []string{"6|"},
+		},
+		{
+			source: "SELECT currval('orders_id_seq');",
+			rows:   []string{"6|"},
+		},
+		{
+			source: "INSERT INTO orders (item) SELECT item FROM orders WHERE id < 3 RETURNING id;",
+			rows:   []string{"7|", "8|"},
+		},
+		{
+			source: "INSERT INTO events (name) VALUES ('start') RETURNING id;",
+			rows:   []string{"1|"},
+		},
+		{
+			source: "INSERT INTO events VALUES (DEFAULT, 'stop') RETURNING id;",
+			rows:   []string{"2|"},
+		},
+		{
+			source: "INSERT INTO events VALUES (7, 'skip');",
+			err:    ErrGeneratedAlways,
+		},
+		{
+			source: "INSERT INTO events SELECT id, name FROM events;",
+			err:    ErrGeneratedAlways,
+		},
+		{
+			source: "INSERT INTO tags VALUES (5, 'five');",
+		},
+		{
+			source: "INSERT INTO tags (tag) VALUES ('one'), ('two') RETURNING id, tag;",
+			rows:   []string{"1|one|", "2|two|"},
+		},
+		{
+			source: "INSERT INTO events (name) VALUES (CAST(nextval('counter') AS TEXT)) RETURNING id, name;",
+			rows:   []string{"3|3|"},
+		},
+		{
+			source: "CREATE TABLE bad (id SERIAL DEFAULT 1);",
+			err:    ErrIdentityDefault,
+		},
+		{
+			source: "CREATE TABLE bad (id TEXT GENERATED ALWAYS AS IDENTITY);",
+			err:    ErrInvalidDatatype,
+		},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		if !assert.Nil(t, err, test.source) {
+			continue
+		}
+
+		var results *Results
+		stmt := ast.Statements[0]
+		switch stmt.Kind {
+		case CreateSequenceKind:
+			err = mb.CreateSequence(stmt.CreateSequenceStatement)
+		case CreateTableKind:
+			err = mb.CreateTable(stmt.CreateTableStatement)
+		case InsertKind:
+			results, err = mb.Insert(stmt.InsertStatement)
+		case SelectKind:
+			results, err = mb.Select(stmt.SelectStatement)
+		}
+
+		assert.Equal(t, test.err, err, test.source)
+		if err == nil && results != nil {
+			assert.Equal(t, test.rows, sortedRows(results), test.source)
+		}
+	}
+
+	results, err := mb.Select(parseSelect(t, "SELECT id, item FROM orders;"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1|a|", "2|b|", "3|c|", "6|f|", "7|a|", "8|b|"}, sortedRows(results))
+}
+
+func TestMemoryBackend_subqueries(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE users (id INT, name TEXT);
+CREATE TABLE orders (id INT, user_id INT, total INT);
+INSERT INTO users VALUES (1, 'ann'), (2, 'bob'), (3, 'cy');
+INSERT INTO orders VALUES (1, 1, 10), (2, 1, 30), (3, 2, 5);
+`)
+
+	tests := []struct {
+		source  string
+		columns []string
+		rows    []string
+		err     error
+	}{
+		{
+			source:  "SELECT name, (SELECT max(total) FROM orders) FROM users;",
+			columns: []string{"name", "max"},
+			rows:    []string{"ann|30|", "bob|30|", "cy|30|"},
+		},
+		{
+			source:  "SELECT name, (SELECT sum(total) FROM orders o WHERE o.user_id = u.id) AS spent FROM users u;",
+			columns: []string{"name", "spent"},
+			rows:    []string{"ann|40|", "bob|5|", "cy||"},
+		},
+		{
+			source: "SELECT name FROM users WHERE id IN (SELECT user_id FROM orders WHERE total > 8);",
+			rows:   []string{"ann|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM orders);",
+			rows:   []string{"cy|"},
+		},
+		{
+			// The inner id is the order's
+			source: "SELECT name FROM users WHERE id IN (SELECT id FROM orders WHERE total = 30);",
+			rows:   []string{"bob|"},
+		},
+		{
+			source: "SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id AND o.total < 20);",
+			rows:   []string{"ann|", "bob|"},
+		},
+		{
+			source: "SELECT name FROM users u WHERE NOT EXISTS (SELECT * FROM orders WHERE user_id = u.id);",
+			rows:   []string{"cy|"},
+		},
+		{
+			source: "SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id AND o.total > u.id * 10);",
+			rows:   []string{"ann|"},
+		},
+		{
+			source: "SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id AND EXISTS (SELECT 1 FROM orders p WHERE p.id = o.id AND p.total = 5));",
+			rows:   []string{"bob|"},
+		},
+		{
+			source: "SELECT name, id IN (SELECT user_id FROM orders WHERE total > 8) FROM users WHERE id > 1;",
+			rows:   []string{"bob|false|", "cy|false|"},
+		},
+		{
+			source:  "SELECT t.user_id, t.total FROM (SELECT user_id, total FROM orders WHERE total > 5) AS t WHERE t.total < 20;",
+			columns: []string{"user_id", "total"},
+			rows:    []string{"1|10|"},
+		},
+		{
+			source: "SELECT u.name, s.spent FROM users u JOIN (SELECT user_id, sum(total) AS spent FROM orders GROUP BY user_id) s ON s.user_id = u.id;",
+			rows:   []string{"ann|40|", "bob|5|"},
+		},
+		{
+			source: "SELECT * FROM (SELECT name FROM users WHERE id = 2) AS b;",
+			rows:   []string{"bob|"},
+		},
+		{
+			source: "SELECT (SELECT id FROM users);",
+			err:    ErrSubqueryRows,
+		},
+		{
+			source: "SELECT (SELECT id, name FROM users WHERE id = 1);",
+			err:    ErrSubqueryColumns,
+		},
+		{
+			source: "SELECT 1 IN (SELECT name FROM users);",
+			err:    ErrInvalidOperands,
+		},
+		{
+			source: "SELECT name FROM users WHERE EXISTS (SELECT 1 FROM missing);",
+			err:    ErrTableDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		if test.columns != nil {
+			var columns []string
+			for _, col := range results.Columns {
+				columns = append(columns, col.Name)
+			}
+			assert.Equal(t, test.columns, columns, test.source)
+		}
+
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+
+	// A subquery correlated by an equality runs once, its rows grouped
+	// on the inner side of it
+	slct := parseSelect(t, "SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id);")
+	_, err := mb.Select(slct)
+	assert.Nil(t, err)
+	assert.False(t, slct.where.subquery.bound.correlated)
+	assert.Len(t, slct.where.subquery.bound.groups, 2)
+
+	ast, err := Parse("INSERT INTO orders VALUES ((SELECT max(id) FROM orders) + 1, 3, 7) RETURNING id;")
+	assert.Nil(t, err)
+	results, err := mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"4|"}, sortedRows(results))
+
+	ast, err = Parse("CREATE TABLE bad (id INT DEFAULT (SELECT 1));")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrMisplacedSubquery, mb.CreateTable(ast.Statements[0].CreateTableStatement))
+}
+
+func TestMemoryBackend_commonTableExpressions(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE employees (id INT, name TEXT, manager INT);
+INSERT INTO employees VALUES (1, 'ceo', 0), (2, 'cto', 1), (3, 'dev', 2), (4, 'ops', 2), (5, 'cfo', 1);
+CREATE TABLE edges (a INT, b INT);
+INSERT INTO edges VALUES (1, 2), (2, 3), (3, 1), (3, 4);
+CREATE SEQUENCE s;
+`)
+
+	tests := []struct {
+		source  string
+		columns []string
+		rows    []string
+		err     error
+	}{
+		{
+			source:  "WITH reports AS (SELECT id, name FROM employees WHERE manager = 2) SELECT r.name FROM reports r;",
+			columns: []string{"name"},
+			rows:    []string{"dev|", "ops|"},
+		},
+		{
+			// Later expressions see earlier ones, and subqueries see
+			// them all
+			source: "WITH a AS (SELECT id FROM employees WHERE manager = 1), b AS (SELECT * FROM a WHERE id > 2) SELECT name FROM employees WHERE id IN (SELECT id FROM b);",
+			rows:   []string{"cfo|"},
+		},
+		{
+			// An expression is used in place of a table of its name
+			source: "WITH employees AS (SELECT 1 AS id) SELECT * FROM employees;",
+			rows:   []string{"1|"},
+		},
+		{
+			source:  "WITH t(x, y) AS (SELECT id, name FROM employees WHERE id = 1) SELECT * FROM t;",
+			columns: []string{"x", "y"},
+			rows:    []string{"1|ceo|"},
+		},
+		{
+			source: "WITH m AS (SELECT manager FROM employees) SELECT a.manager FROM m a JOIN m b ON a.manager = b.manager WHERE a.manager = 2;",
+			rows:   []string{"2|", "2|", "2|", "2|"},
+		},
+		{
+			source: "SELECT name FROM employees e WHERE EXISTS (WITH m AS (SELECT manager FROM employees) SELECT 1 FROM m WHERE m.manager = e.id);",
+			rows:   []string{"ceo|", "cto|"},
+		},
+		{
+			// Every scan reads the same materialized rows
+			source: "WITH v AS (SELECT nextval('s') AS n) SELECT a.n, b.n FROM v a, v b;",
+			rows:   []string{"1|1|"},
+		},
+		{
+			source:  "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 5) SELECT sum(n) FROM t;",
+			columns: []string{"sum"},
+			rows:    []string{"15|"},
+		},
+		{
+			// Everyone under the CTO, with how far under
+			source:  "WITH RECURSIVE chain(id, name, depth) AS (SELECT id, name, 0 FROM employees WHERE id = 2 UNION ALL SELECT e.id, e.name, c.depth + 1 FROM employees e JOIN chain c ON e.manager = c.id) SELECT name, depth FROM chain;",
+			columns: []string{"name", "depth"},
+			rows:    []string{"cto|0|", "dev|1|", "ops|1|"},
+		},
+		{
+			// UNION stops at the cycle in the graph
+			source: "WITH RECURSIVE reachable(node) AS (SELECT 1 UNION SELECT e.b FROM edges e JOIN reachable r ON e.a = r.node) SELECT * FROM reachable;",
+			rows:   []string{"1|", "2|", "3|", "4|"},
+		},
+		{
+			// A recursive term that doesn't refer to its expression
+			// runs once
+			source: "WITH RECURSIVE t AS (SELECT 1 AS n UNION ALL SELECT 2) SELECT * FROM t;",
+			rows:   []string{"1|", "2|"},
+		},
+		{
+			source: "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t) SELECT * FROM t;",
+			err:    ErrRecursionLimit,
+		},
+		{
+			source: "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n, n FROM t) SELECT * FROM t;",
+			err:    ErrSetColumns,
+		},
+		{
+			source: "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT true FROM t) SELECT * FROM t;",
+			err:    ErrSetTypes,
+		},
+		{
+			source: "WITH t(a, b) AS (SELECT 1) SELECT * FROM t;",
+			err:    ErrCTEColumns,
+		},
+		{
+			// Only later expressions see earlier ones
+			source: "WITH a AS (SELECT * FROM b), b AS (SELECT 1) SELECT * FROM a;",
+			err:    ErrTableDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		if test.columns != nil {
+			var columns []string
+			for _, col := range results.Columns {
+				columns = append(columns, col.Name)
+			}
+			assert.Equal(t, test.columns, columns, test.source)
+		}
+
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_setOperations(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE a (x INT, y TEXT);
+CREATE TABLE b (x BIGINT, y TEXT);
+INSERT INTO a VALUES (1, 'p'), (1, 'p'), (2, 'q'), (3, 'r');
+INSERT INTO b VALUES (1, 'p'), (3, 'r'), (3, 'r'), (4, 's');
+`)
+
+	tests := []struct {
+		source  string
+		columns []ResultColumn
+		rows    []string
+		err     error
+	}{
+		{
+			source:  "SELECT * FROM a UNION SELECT * FROM b;",
+			columns: []ResultColumn{{Type: BigIntType, Name: "x"}, {Type: TextType, Name: "y"}},
+			rows:    []string{"1|p|", "2|q|", "3|r|", "4|s|"},
+		},
+		{
+			source: "SELECT x FROM a UNION ALL SELECT x FROM b;",
+			rows:   []string{"1|", "1|", "1|", "2|", "3|", "3|", "3|", "4|"},
+		},
+		{
+			source: "SELECT * FROM a INTERSECT SELECT * FROM b;",
+			rows:   []string{"1|p|", "3|r|"},
+		},
+		{
+			source: "SELECT x FROM b INTERSECT ALL SELECT x FROM a UNION ALL SELECT 3;",
+			rows:   []string{"1|", "3|", "3|"},
+		},
+		{
+			source: "SELECT x FROM a EXCEPT SELECT x FROM b;",
+			rows:   []string{"2|"},
+		},
+		{
+			source: "SELECT x FROM a EXCEPT ALL SELECT x FROM b;",
+			rows:   []string{"1|", "2|"},
+		},
+		{
+			// INTERSECT binds more tightly than UNION and EXCEPT
+			source: "SELECT x FROM a UNION SELECT x FROM b INTERSECT SELECT 4;",
+			rows:   []string{"1|", "2|", "3|", "4|"},
+		},
+		{
+			source: "(SELECT x FROM a UNION SELECT 7) INTERSECT SELECT 7;",
+			rows:   []string{"7|"},
+		},
+		{
+			source:  "SELECT 1 AS one UNION SELECT 2.5;",
+			columns: []ResultColumn{{Type: DecimalType, Name: "one"}},
+			rows:    []string{"1|", "2.5|"},
+		},
+		{
+			// Unknown values are the same as each other but not as ''
+			source: "SELECT max(y) FROM a WHERE x > 5 UNION SELECT '' UNION SELECT max(y) FROM b WHERE x > 5;",
+			rows:   []string{"|", "|"},
+		},
+		{
+			source: "SELECT * FROM (SELECT x FROM a UNION SELECT x FROM b) u WHERE u.x > 2;",
+			rows:   []string{"3|", "4|"},
+		},
+		{
+			source: "SELECT y FROM a WHERE x IN (SELECT x FROM b EXCEPT SELECT 3);",
+			rows:   []string{"p|", "p|"},
+		},
+		{
+			source: "WITH c AS (SELECT x FROM a INTERSECT SELECT x FROM b) SELECT * FROM c;",
+			rows:   []string{"1|", "3|"},
+		},
+		{
+			source: "WITH RECURSIVE t(n) AS (SELECT 1 UNION SELECT 2 UNION SELECT n + 2 FROM t WHERE n < 5) SELECT * FROM t;",
+			rows:   []string{"1|", "2|", "3|", "4|", "5|", "6|"},
+		},
+		{
+			source: "SELECT x FROM a UNION SELECT x, y FROM b;",
+			err:    ErrSetColumns,
+		},
+		{
+			source: "SELECT x FROM a EXCEPT SELECT true;",
+			err:    ErrSetTypes,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		if test.columns != nil {
+			assert.Equal(t, test.columns, results.Columns, test.source)
+		}
+
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_distinct(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE employees (id INT, dept TEXT, pay INT);
+INSERT INTO employees VALUES (1, 'a', 10), (2, 'a', 20), (3, 'b', 10), (4, 'b', 10), (5, 'c', 5);
+`)
+
+	tests := []struct {
+		source string
+		rows   []string
+	}{
+		{
+			source: "SELECT DISTINCT dept FROM employees;",
+			rows:   []string{"a|", "b|", "c|"},
+		},
+		{
+			source: "SELECT DISTINCT pay, dept FROM employees;",
+			rows:   []string{"10|a|", "10|b|", "20|a|", "5|c|"},
+		},
+		{
+			source: "SELECT ALL dept FROM employees WHERE pay = 10;",
+			rows:   []string{"a|", "b|", "b|"},
+		},
+		{
+			// The first row of each department is kept
+			source: "SELECT DISTINCT ON (dept) id, pay FROM employees;",
+			rows:   []string{"1|10|", "3|10|", "5|5|"},
+		},
+		{
+			source: "SELECT DISTINCT ON (pay % 2, dept) dept FROM employees;",
+			rows:   []string{"a|", "b|", "c|"},
+		},
+		{
+			source: "SELECT DISTINCT count(*) FROM employees GROUP BY dept;",
+			rows:   []string{"1|", "2|"},
+		},
+		{
+			source: "SELECT DISTINCT ON (count(*)) dept FROM employees GROUP BY dept;",
+			rows:   []string{"a|", "c|"},
+		},
+		{
+			// Unknown values are the same as each other but not as ''
+			source: "SELECT DISTINCT m FROM (SELECT max(dept) AS m FROM employees WHERE pay > 50 UNION ALL SELECT '' UNION ALL SELECT max(dept) FROM employees WHERE pay > 50) AS t;",
+			rows:   []string{"|", "|"},
+		},
+		{
+			source: "SELECT id FROM employees e WHERE id IN (SELECT DISTINCT ON (dept) id FROM employees WHERE pay = e.pay);",
+			rows:   []string{"1|", "2|", "3|", "5|"},
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
+
+func TestMemoryBackend_patterns(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE users (id INT, name TEXT);
+INSERT INTO users VALUES (1, 'Alice'), (2, 'bob'), (3, 'al_x'), (4, 'Carol'), (5, '100%');
+`)
+
+	tests := []struct {
+		source string
+		rows   []string
+	}{
+		{
+			source: "SELECT id FROM users WHERE id IN (1, 3, 3 + 2);",
+			rows:   []string{"1|", "3|", "5|"},
+		},
+		{
+			source: "SELECT id FROM users WHERE id NOT IN (1, 3) AND id IN (2, 4, 5.0);",
+			rows:   []string{"2|", "4|", "5|"},
+		},
+		{
+			// x NOT IN a list holding an unknown value is never true
+			source: "SELECT id FROM users WHERE id NOT IN (1, (SELECT max(id) FROM users WHERE id > 5));",
+			rows:   nil,
+		},
+		{
+			source: "SELECT id FROM users WHERE id IN (1, (SELECT max(id) FROM users WHERE id > 5));",
+			rows:   []string{"1|"},
+		},
+		{
+			source: "SELECT id FROM users WHERE id BETWEEN 2 AND 4 AND id <> 3;",
+			rows:   []string{"2|", "4|"},
+		},
+		{
+			source: "SELECT id FROM users WHERE id NOT BETWEEN 2 AND 1 + 3;",
+			rows:   []string{"1|", "5|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE name LIKE 'a%';",
+			rows:   []string{"al_x|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE name ILIKE 'A%' OR name LIKE '_o_';",
+			rows:   []string{"Alice|", "al_x|", "bob|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE name LIKE '%\\_%' OR name LIKE '%\\%';",
+			rows:   []string{"100%|", "al_x|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE name NOT ILIKE '%o%' AND name NOT LIKE '%e';",
+			rows:   []string{"100%|", "al_x|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE name ~ '^[a-c]' OR name ~* '^CA';",
+			rows:   []string{"Carol|", "al_x|", "bob|"},
+		},
+		{
+			source: "SELECT name FROM users WHERE name !~ '[a-z]$' OR name !~* 'L';",
+			rows:   []string{"100%|", "bob|"},
+		},
+		{
+			source: "SELECT name LIKE 'b%', name ~~ 'b%', name !~~* 'B%' FROM users WHERE id = 2;",
+			rows:   []string{"true|true|false|"},
+		},
+		{
+			source: "SELECT 'abc' LIKE 'a%c%', 'ab' LIKE 'a_c', '' LIKE '%', 'a' BETWEEN 'a' AND 'b';",
+			rows:   []string{"true|false|true|true|"},
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+
+	errors := []struct {
+		source string
+		err    error
+	}{
+		{source: "SELECT id FROM users WHERE id LIKE '1%';", err: ErrInvalidOperands},
+		{source: "SELECT id FROM users WHERE name ~ 1;", err: ErrInvalidOperands},
+		{source: "SELECT id FROM users WHERE name LIKE 'a\\';", err: ErrInvalidPattern},
+		{source: "SELECT id FROM users WHERE name ~ '(';", err: ErrInvalidPattern},
+	}
+
+	for _, test := range errors {
+		_, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+	}
+}
+
+func TestMemoryBackend_conditionals(t *testing.T) {
+	mb := newTestBackend(t, `
+CREATE TABLE items (id INT, name TEXT, price DOUBLE PRECISION, stock BIGINT);
+INSERT INTO items VALUES (1, 'pen', 1.5, 10), (2, 'ink', 4, 0), (3, 'pad', 2.25, 3);
+INSERT INTO items (id, stock) VALUES (0, 7);
+CREATE SEQUENCE s;
+`)
+
+	tests := []struct {
+		source  string
+		columns []ResultColumn
+		rows    []string
+		err     error
+	}{
+		{
+			// Results not chosen aren't evaluated
+			source:  "SELECT CASE WHEN id = 0 THEN 0 ELSE 12 / id END AS ratio FROM items;",
+			columns: []ResultColumn{{Type: IntType, Name: "ratio"}},
+			rows:    []string{"0|", "12|", "4|", "6|"},
+		},
+		{
+			// Nor is anything in them run to work out their types
+			source:  "SELECT CASE WHEN id >= 0 THEN id ELSE (SELECT 1 / 0) END AS id, coalesce(stock, 1 / 0, (SELECT max(stock / 0) FROM items)) AS stock FROM items;",
+			columns: []ResultColumn{{Type: IntType, Name: "id"}, {Type: BigIntType, Name: "stock"}},
+			rows:    []string{"0|7|", "1|10|", "2|0|", "3|3|"},
+		},
+		{
+			source: "SELECT CASE WHEN id > 0 THEN 'x' ELSE (WITH w AS (SELECT 1 / 0 AS z) SELECT z FROM w) END FROM items;",
+			err:    ErrBranchTypes,
+		},
+		{
+			source:  "SELECT CASE WHEN id >= 0 THEN id ELSE (SELECT 1 / 0) END FROM items WHERE id = 1;",
+			columns: []ResultColumn{{Type: IntType, Name: "case"}},
+			rows:    []string{"1|"},
+		},
+		{
+			source: "SELECT CASE WHEN id < 0 THEN id ELSE (SELECT 1 / 0) END FROM items;",
+			err:    ErrDivisionByZero,
+		},
+		{
+			source:  "SELECT CASE id WHEN 1 THEN 'one' WHEN 2 THEN 'two' END FROM items;",
+			columns: []ResultColumn{{Type: TextType, Name: "case"}},
+			rows:    []string{"one|", "two|", "|", "|"},
+		},
+		{
+			// Integer results are converted to the type of the others
+			source:  "SELECT CASE WHEN price > 2 THEN price WHEN stock > 5 THEN stock ELSE id END FROM items;",
+			columns: []ResultColumn{{Type: DoubleType, Name: "case"}},
+			rows:    []string{"10|", "2.25|", "4|", "7|"},
+		},
+		{
+			source:  "SELECT coalesce(name, 'none'), coalesce(price, stock) FROM items;",
+			columns: []ResultColumn{{Type: TextType, Name: "coalesce"}, {Type: DoubleType, Name: "coalesce"}},
+			rows:    []string{"ink|4|", "none|7|", "pad|2.25|", "pen|1.5|"},
+		},
+		{
+			source:  "SELECT nullif(stock, 0), nullif(name, 'pen') FROM items;",
+			columns: []ResultColumn{{Type: BigIntType, Name: "nullif"}, {Type: TextType, Name: "nullif"}},
+			rows:    []string{"10||", "3|pad|", "7||", "|ink|"},
+		},
+		{
+			// Unknown arguments are left out
+			source:  "SELECT greatest(id, stock, price), least(id, stock) FROM items;",
+			columns: []ResultColumn{{Type: DoubleType, Name: "greatest"}, {Type: BigIntType, Name: "least"}},
+			rows:    []string{"10|1|", "3|3|", "4|0|", "7|0|"},
+		},
+		{
+			source: "SELECT greatest('2024-01-01', DATE '2023-06-01'), least('b', 'a', 'c'), greatest(1.5, 2);",
+			rows:   []string{"2024-01-01|a|2|"},
+		},
+		{
+			source: "SELECT id FROM items WHERE CASE WHEN name = 'pen' THEN true ELSE stock > 5 END;",
+			rows:   []string{"0|", "1|"},
+		},
+		{
+			source: "SELECT CASE WHEN stock > 0 THEN 'in stock' ELSE 'sold out' END AS status, count(*) FROM items GROUP BY CASE WHEN stock > 0 THEN 'in stock' ELSE 'sold out' END;",
+			rows:   []string{"in stock|3|", "sold out|1|"},
+		},
+		{
+			source: "SELECT sum(CASE WHEN price > 2 THEN 1 ELSE 0 END), coalesce(max(name), '') FROM items WHERE id < 3;",
+			rows:   []string{"1|pen|"},
+		},
+		{
+			source: "SELECT id, CASE WHEN id = 3 THEN (SELECT max(i.id) FROM items i WHERE i.id < items.id) ELSE 0 END FROM items WHERE id > 1;",
+			rows:   []string{"2|0|", "3|2|"},
+		},
+		{
+			// nextval only runs when its result is chosen
+			source: "SELECT coalesce(name, CAST(nextval('s') AS TEXT)) FROM items;",
+			rows:   []string{"1|", "ink|", "pad|", "pen|"},
+		},
+		{
+			source: "SELECT CASE WHEN id > 1 THEN 1 ELSE 'x' END FROM items;",
+			err:    ErrBranchTypes,
+		},
+		{
+			source: "SELECT coalesce(id, true) FROM items;",
+			err:    ErrBranchTypes,
+		},
+		{
+			source: "SELECT CASE WHEN id THEN 1 END FROM items;",
+			err:    ErrInvalidOperands,
+		},
+		{
+			source: "SELECT nullif(id) FROM items;",
+			err:    ErrInvalidArguments,
+		},
+		{
+			source: "SELECT greatest() FROM items;",
+			err:    ErrInvalidArguments,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := mb.Select(parseSelect(t, test.source))
+		assert.Equal(t, test.err, err, test.source)
+		if err != nil {
+			continue
+		}
+
+		if test.columns != nil {
+			assert.Equal(t, test.columns, results.Columns, test.source)
+		}
+
+		assert.Equal(t, test.rows, sortedRows(results), test.source)
+	}
+}
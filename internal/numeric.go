@@ -0,0 +1,540 @@
+package ashudb
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact number, Value scaled down by Scale decimal
+// digits: 3.14 is {314, 2}.
+type Decimal struct {
+	Value int64
+	Scale uint8
+}
+
+// maxDecimalPrecision is the most digits a decimal holds, as many as
+// always fit in an int64.
+const maxDecimalPrecision = 18
+
+// divisionScale is the fewest digits after the point the quotient of
+// two decimals gets, so that 1 / 3 isn't 0.
+const divisionScale = 6
+
+// maxExponent bounds the exponent of a numeric literal, beyond it no
+// decimal or double can hold the value.
+const maxExponent = 400
+
+var decimalLimit = pow10(maxDecimalPrecision)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.Value, 10)
+	sign := ""
+	if d.Value < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	scale := int(d.Scale)
+	if scale == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// Float returns the float64 closest to the decimal.
+func (d Decimal) Float() float64 {
+	f, _ := new(big.Rat).SetFrac(big.NewInt(d.Value), pow10(int(d.Scale))).Float64()
+	return f
+}
+
+// roundQuotient divides x by y, rounding halves away from zero.
+func roundQuotient(x, y *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if new(big.Int).Abs(r).Lsh(new(big.Int).Abs(r), 1).Cmp(new(big.Int).Abs(y)) >= 0 {
+		q.Add(q, big.NewInt(int64(x.Sign()*y.Sign())))
+	}
+
+	return q
+}
+
+// rescale changes the number of digits after the point of an unscaled
+// value, rounding if it loses some.
+func rescale(v *big.Int, from, to int) *big.Int {
+	if to >= from {
+		return new(big.Int).Mul(v, pow10(to-from))
+	}
+
+	return roundQuotient(v, pow10(from-to))
+}
+
+// makeDecimal returns the decimal of an unscaled value, rounding away
+// digits past the most a decimal can hold after the point.
+func makeDecimal(v *big.Int, scale int) (Decimal, error) {
+	if scale > maxDecimalPrecision {
+		v, scale = rescale(v, scale, maxDecimalPrecision), maxDecimalPrecision
+	}
+
+	if new(big.Int).Abs(v).Cmp(decimalLimit) >= 0 {
+		return Decimal{}, ErrNumericOverflow
+	}
+
+	return Decimal{Value: v.Int64(), Scale: uint8(scale)}, nil
+}
+
+// parseDecimal parses a numeric literal like 3.14, .5 or 1e-5.
+func parseDecimal(s string) (Decimal, error) {
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > maxExponent || e < -maxExponent {
+			return Decimal{}, ErrNumericOverflow
+		}
+
+		mantissa, exponent = s[:i], e
+	}
+
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i != -1 {
+		scale = len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+
+	v, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return Decimal{}, ErrInvalidDatatype
+	}
+
+	scale -= exponent
+	if scale < 0 {
+		v, scale = rescale(v, scale, 0), 0
+	}
+
+	return makeDecimal(v, scale)
+}
+
+// fitDecimal rounds a decimal to scale digits after the point, failing
+// if it then has more than precision digits.
+func fitDecimal(d Decimal, precision, scale int) (Decimal, error) {
+	v := rescale(big.NewInt(d.Value), int(d.Scale), scale)
+	if new(big.Int).Abs(v).Cmp(pow10(precision)) >= 0 {
+		return Decimal{}, ErrNumericOverflow
+	}
+
+	return makeDecimal(v, scale)
+}
+
+func compareDecimals(a, b Decimal) int {
+	scale := int(max(a.Scale, b.Scale))
+	x := rescale(big.NewInt(a.Value), int(a.Scale), scale)
+	y := rescale(big.NewInt(b.Value), int(b.Scale), scale)
+	return x.Cmp(y)
+}
+
+func decimalArithmetic(op Symbol, a, b Decimal) (Decimal, error) {
+	x, y := big.NewInt(a.Value), big.NewInt(b.Value)
+	scale := int(max(a.Scale, b.Scale))
+
+	switch op {
+	case PlusSymbol, MinusSymbol, PercentSymbol:
+		x, y = rescale(x, int(a.Scale), scale), rescale(y, int(b.Scale), scale)
+		switch op {
+		case PlusSymbol:
+			x.Add(x, y)
+		case MinusSymbol:
+			x.Sub(x, y)
+		default:
+			if y.Sign() == 0 {
+				return Decimal{}, ErrDivisionByZero
+			}
+
+			x.Rem(x, y)
+		}
+
+		return makeDecimal(x, scale)
+	case AsteriskSymbol:
+		return makeDecimal(x.Mul(x, y), int(a.Scale)+int(b.Scale))
+	}
+
+	if y.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+
+	// Scale the dividend up so the quotient has the digits wanted
+	scale = max(scale, divisionScale)
+	x.Mul(x, pow10(scale-int(a.Scale)+int(b.Scale)))
+	return makeDecimal(roundQuotient(x, y), scale)
+}
+
+func decimalToCell(d Decimal) MemoryCell {
+	cell := make(MemoryCell, 9)
+	cell[0] = d.Scale
+	binary.BigEndian.PutUint64(cell[1:], uint64(d.Value))
+	return cell
+}
+
+// floatToCell encodes a REAL in 4 bytes and a DOUBLE in 8.
+func floatToCell(f float64, typ ColumnType) MemoryCell {
+	if typ == RealType {
+		cell := make(MemoryCell, 4)
+		binary.BigEndian.PutUint32(cell, math.Float32bits(float32(f)))
+		return cell
+	}
+
+	cell := make(MemoryCell, 8)
+	binary.BigEndian.PutUint64(cell, math.Float64bits(f))
+	return cell
+}
+
+// integerBounds are the smallest and largest values of the integer
+// types.
+var integerBounds = map[ColumnType][2]int64{
+	SmallIntType: {math.MinInt16, math.MaxInt16},
+	IntType:      {math.MinInt32, math.MaxInt32},
+	BigIntType:   {math.MinInt64, math.MaxInt64},
+}
+
+func isInteger(typ ColumnType) bool {
+	_, ok := integerBounds[typ]
+	return ok
+}
+
+// integerValue returns the value of a cell of any of the integer types.
+func integerValue(cell MemoryCell, typ ColumnType) int64 {
+	switch typ {
+	case SmallIntType:
+		return int64(cell.AsSmallInt())
+	case IntType:
+		return int64(cell.AsInt())
+	}
+
+	return cell.AsBigInt()
+}
+
+// integerToCell encodes a SMALLINT in 2 bytes, an INT in 4 and a BIGINT
+// in 8, failing if the value is out of the type's range.
+func integerToCell(i int64, typ ColumnType) (MemoryCell, error) {
+	bounds := integerBounds[typ]
+	if i < bounds[0] || i > bounds[1] {
+		return nil, ErrNumericOverflow
+	}
+
+	switch typ {
+	case SmallIntType:
+		cell := make(MemoryCell, 2)
+		binary.BigEndian.PutUint16(cell, uint16(i))
+		return cell, nil
+	case IntType:
+		return intToCell(int32(i)), nil
+	}
+
+	cell := make(MemoryCell, 8)
+	binary.BigEndian.PutUint64(cell, uint64(i))
+	return cell, nil
+}
+
+// integerArithmetic applies an arithmetic operator to two integers,
+// failing if the result doesn't fit in typ.
+func integerArithmetic(op Symbol, i, j int64, typ ColumnType) (MemoryCell, error) {
+	var r int64
+	overflow := false
+	switch op {
+	case PlusSymbol:
+		r = i + j
+		overflow = (j > 0 && r < i) || (j < 0 && r > i)
+	case MinusSymbol:
+		r = i - j
+		overflow = (j < 0 && r < i) || (j > 0 && r > i)
+	case AsteriskSymbol:
+		r = i * j
+		overflow = i != 0 && (r/i != j || (i == -1 && j == math.MinInt64))
+	default:
+		if j == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		if op == SlashSymbol {
+			r = i / j
+			overflow = i == math.MinInt64 && j == -1
+		} else {
+			r = i % j
+		}
+	}
+
+	if overflow {
+		return nil, ErrNumericOverflow
+	}
+
+	return integerToCell(r, typ)
+}
+
+// numericTypes lists the numeric types by precedence. An operation on
+// numbers of different types is carried out in whichever comes later,
+// except that REAL only holds every integer or DECIMAL approximately,
+// so mixing them is done in DOUBLE.
+var numericTypes = []ColumnType{SmallIntType, IntType, BigIntType, DecimalType, RealType, DoubleType}
+
+func numericRank(typ ColumnType) int {
+	for i, numeric := range numericTypes {
+		if typ == numeric {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func isNumeric(typ ColumnType) bool {
+	return numericRank(typ) != -1
+}
+
+// commonNumericType returns the type an operation on numbers of types
+// a and b is carried out in. ok is false unless both are numeric.
+func commonNumericType(a, b ColumnType) (typ ColumnType, ok bool) {
+	if !isNumeric(a) || !isNumeric(b) {
+		return 0, false
+	}
+
+	if a == b {
+		return a, true
+	}
+
+	typ = a
+	if numericRank(b) > numericRank(a) {
+		typ = b
+	}
+
+	if typ == RealType {
+		return DoubleType, true
+	}
+
+	return typ, true
+}
+
+// numericFloat returns the value of a numeric cell as a float64.
+func numericFloat(cell MemoryCell, typ ColumnType) float64 {
+	switch {
+	case isInteger(typ):
+		return float64(integerValue(cell, typ))
+	case typ == DecimalType:
+		return cell.AsDecimal().Float()
+	}
+
+	return cell.AsFloat()
+}
+
+// checkFloat fails for a result too large for its type, one that
+// isn't already infinite because an operand was.
+func checkFloat(f float64, typ ColumnType) error {
+	if typ == RealType && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+		return ErrNumericOverflow
+	}
+
+	return nil
+}
+
+// convertNumeric converts a cell of one numeric type to another,
+// rounding to the nearest value the new type holds and failing if it
+// is out of the new type's range. Unknown values stay unknown.
+func convertNumeric(cell MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if cell == nil || from == to {
+		return cell, nil
+	}
+
+	switch {
+	case isInteger(to):
+		if isInteger(from) {
+			return integerToCell(integerValue(cell, from), to)
+		}
+
+		if from == DecimalType {
+			d := cell.AsDecimal()
+			v := rescale(big.NewInt(d.Value), int(d.Scale), 0)
+			if !v.IsInt64() {
+				return nil, ErrNumericOverflow
+			}
+
+			return integerToCell(v.Int64(), to)
+		}
+
+		// Beyond 2^63 no float converts exactly, and none is in range
+		f := math.Round(cell.AsFloat())
+		if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, ErrNumericOverflow
+		}
+
+		return integerToCell(int64(f), to)
+	case to == DecimalType:
+		if isInteger(from) {
+			d, err := makeDecimal(big.NewInt(integerValue(cell, from)), 0)
+			if err != nil {
+				return nil, err
+			}
+
+			return decimalToCell(d), nil
+		}
+
+		f := cell.AsFloat()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrNumericOverflow
+		}
+
+		bits := 64
+		if from == RealType {
+			bits = 32
+		}
+
+		d, err := parseDecimal(strconv.FormatFloat(f, 'g', -1, bits))
+		if err != nil {
+			return nil, err
+		}
+
+		return decimalToCell(d), nil
+	}
+
+	f := numericFloat(cell, from)
+	if err := checkFloat(f, to); err != nil {
+		return nil, err
+	}
+
+	return floatToCell(f, to), nil
+}
+
+// numericArithmetic applies an arithmetic operator to two cells of
+// numeric type typ.
+func numericArithmetic(op Symbol, a, b MemoryCell, typ ColumnType) (MemoryCell, error) {
+	if isInteger(typ) {
+		return integerArithmetic(op, integerValue(a, typ), integerValue(b, typ), typ)
+	}
+
+	if typ == DecimalType {
+		d, err := decimalArithmetic(op, a.AsDecimal(), b.AsDecimal())
+		if err != nil {
+			return nil, err
+		}
+
+		return decimalToCell(d), nil
+	}
+
+	x, y := a.AsFloat(), b.AsFloat()
+	var f float64
+	switch op {
+	case PlusSymbol:
+		f = x + y
+	case MinusSymbol:
+		f = x - y
+	case AsteriskSymbol:
+		f = x * y
+	default:
+		if y == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		if op == SlashSymbol {
+			f = x / y
+		} else {
+			f = math.Mod(x, y)
+		}
+	}
+
+	if math.IsInf(f, 0) && !math.IsInf(x, 0) && !math.IsInf(y, 0) {
+		return nil, ErrNumericOverflow
+	}
+
+	if err := checkFloat(f, typ); err != nil {
+		return nil, err
+	}
+
+	return floatToCell(f, typ), nil
+}
+
+// negateCell negates a number or an interval of type typ. The smallest
+// value of an integer type has no positive counterpart, so negating it
+// overflows.
+func negateCell(cell MemoryCell, typ ColumnType) (MemoryCell, error) {
+	switch {
+	case isInteger(typ):
+		return integerArithmetic(MinusSymbol, 0, integerValue(cell, typ), typ)
+	case typ == DecimalType:
+		d := cell.AsDecimal()
+		d.Value = -d.Value
+		return decimalToCell(d), nil
+	case typ == IntervalType:
+		i := cell.AsInterval()
+		if i.Months == math.MinInt32 || i.Days == math.MinInt32 || i.Micros == math.MinInt64 {
+			return nil, ErrDatetimeOverflow
+		}
+
+		return intervalToCell(Interval{Months: -i.Months, Days: -i.Days, Micros: -i.Micros}), nil
+	}
+
+	return floatToCell(-cell.AsFloat(), typ), nil
+}
+
+// evaluateUnaryCell evaluates `-x` or `+x`, where x is a number or an
+// interval. The result has the type of x.
+func evaluateUnaryCell(exp functionExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	cell, col, err := evaluateCell(exp.args[0], columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	if !isNumeric(col.Type) && col.Type != IntervalType {
+		return nil, ResultColumn{}, ErrInvalidOperands
+	}
+
+	result := ResultColumn{Type: col.Type, Name: "?column?"}
+	if cell == nil || Symbol(exp.name.Value) == PlusSymbol {
+		return cell, result, nil
+	}
+
+	cell, err = negateCell(cell, col.Type)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	return cell, result, nil
+}
+
+// canonicalCell returns the one encoding of a value that has several,
+// so that equal values can be looked up by their bytes: 1.5 and 1.50,
+// or 0 and -0, or intervals of 1 day and 24 hours.
+func canonicalCell(cell MemoryCell, typ ColumnType) MemoryCell {
+	if cell == nil {
+		return nil
+	}
+
+	switch typ {
+	case DecimalType:
+		d := cell.AsDecimal()
+		if d.Scale == 0 || d.Value%10 != 0 {
+			return cell
+		}
+
+		for d.Scale > 0 && d.Value%10 == 0 {
+			d.Value /= 10
+			d.Scale--
+		}
+
+		return decimalToCell(d)
+	case RealType, DoubleType:
+		if cell.AsFloat() == 0 {
+			return floatToCell(0, typ)
+		}
+	case IntervalType:
+		total := cell.AsInterval().total()
+		return append(MemoryCell{byte(total.Sign() + 1)}, total.Bytes()...)
+	}
+
+	return cell
+}
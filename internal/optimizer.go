@@ -0,0 +1,1207 @@
+package ashudb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// catalog is what the optimizer needs to know about the tables a plan
+// reads from.
+type catalog interface {
+	// indexFor returns the name of an index on table over exp, if
+	// there is one.
+	indexFor(table string, exp expression) (string, bool)
+	// tableColumns returns the names of a table's columns.
+	tableColumns(table string) ([]string, bool)
+	// statistics returns what ANALYZE last collected about a table,
+	// nil if it has not been analyzed.
+	statistics(table string) *tableStatistics
+}
+
+// optimize rewrites a plan into an equivalent one that is cheaper to
+// execute. Rules are applied in a fixed order: constant folding first
+// so that later rules see simplified predicates, then predicate
+// pushdown, index selection, join ordering and finally column pruning
+// so that scans only produce what the operators above them read.
+func optimize(p *plan, c catalog) *plan {
+	p = foldConstants(p)
+	p = pushDownPredicates(p, c)
+	p = useIndexes(p, c)
+	p = reorderJoins(p, c)
+	pruneColumns(p, nil, c)
+	estimateRows(p, c)
+	return p
+}
+
+func isConstant(e expression) bool {
+	return e.kind == literalKind && e.literal.Kind != IdentifierKind
+}
+
+// cellToToken turns an evaluated cell back into a literal token, if
+// the cell's type has a literal form.
+func cellToToken(cell MemoryCell, typ ColumnType) (*Token, bool) {
+	switch typ {
+	case IntType:
+		return &Token{Value: fmt.Sprintf("%d", cell.AsInt()), Kind: NumericKind}, true
+	case TextType:
+		return &Token{Value: cell.AsText(), Kind: StringKind}, true
+	case BlobType:
+		return &Token{Value: hex.EncodeToString(cell.AsBytes()), Kind: HexKind}, true
+	case BoolType:
+		if cell.AsBool() {
+			return &Token{Value: string(TrueKeyword), Kind: KeywordKind}, true
+		}
+
+		return &Token{Value: string(FalseKeyword), Kind: KeywordKind}, true
+	case BigIntType, DecimalType:
+		// Numbers that fit a narrower type would read back as one
+		t := &Token{Value: fmt.Sprintf("%d", cell.AsBigInt()), Kind: NumericKind}
+		if typ == DecimalType {
+			t.Value = cell.AsDecimal().String()
+		}
+
+		_, literalType, err := literalCell(t)
+		return t, err == nil && literalType == typ
+	}
+
+	return nil, false
+}
+
+// foldExpression evaluates every subexpression that does not depend on
+// a row.
+func foldExpression(e expression) expression {
+	if e.kind != binaryKind {
+		return e
+	}
+
+	folded := expression{
+		kind: binaryKind,
+		binary: &binaryExpression{
+			a:  foldExpression(e.binary.a),
+			b:  foldExpression(e.binary.b),
+			op: e.binary.op,
+		},
+	}
+
+	if !isConstant(folded.binary.a) || !isConstant(folded.binary.b) {
+		return folded
+	}
+
+	// Errors like division by zero are left for execution to report
+	cell, col, err := evaluateCell(folded, nil, nil)
+	if err != nil {
+		return folded
+	}
+
+	t, ok := cellToToken(cell, col.Type)
+	if !ok {
+		return folded
+	}
+
+	return expression{literal: t, kind: literalKind}
+}
+
+func foldConstants(p *plan) *plan {
+	if p == nil {
+		return nil
+	}
+
+	switch p.kind {
+	case filterPlanKind:
+		child := foldConstants(p.filter.child)
+
+		var kept []expression
+		for _, predicate := range conjuncts(foldExpression(p.filter.predicate)) {
+			// Conjuncts that are always true filter nothing
+			if isConstant(predicate) {
+				cell, col, err := evaluateCell(predicate, nil, nil)
+				if err == nil {
+					if ok, err := isTruthy(cell, col.Type); err == nil && ok {
+						continue
+					}
+				}
+			}
+
+			kept = append(kept, predicate)
+		}
+
+		return newFilterPlan(kept, child)
+	case projectPlanKind:
+		items := []*selectItem{}
+		for _, item := range p.project.items {
+			folded := *item
+			if item.exp != nil {
+				exp := foldExpression(*item.exp)
+				folded.exp = &exp
+			}
+
+			items = append(items, &folded)
+		}
+
+		return &plan{
+			kind: projectPlanKind,
+			project: &projectPlan{
+				items: items,
+				child: foldConstants(p.project.child),
+				from:  p.project.from,
+			},
+		}
+	case joinPlanKind:
+		p.join.left = foldConstants(p.join.left)
+		p.join.right = foldConstants(p.join.right)
+	case aggregatePlanKind:
+		// The aggregation's expressions are left alone, the projection
+		// above refers to them by their code
+		p.aggregate.child = foldConstants(p.aggregate.child)
+	case subqueryScanPlanKind:
+		p.subquery.child = foldConstants(p.subquery.child)
+	case setOperationPlanKind:
+		p.set.left = foldConstants(p.set.left)
+		p.set.right = foldConstants(p.set.right)
+	case distinctPlanKind:
+		p.distinct.child = foldConstants(p.distinct.child)
+	}
+
+	return p
+}
+
+// conjuncts splits a predicate on its top-level ANDs.
+func conjuncts(e expression) []expression {
+	and := tokenFromKeyword(AndKeyword)
+	if e.kind == binaryKind && e.binary.op.equals(&and) {
+		return append(conjuncts(e.binary.a), conjuncts(e.binary.b)...)
+	}
+
+	return []expression{e}
+}
+
+// conjunction ANDs predicates together, nil if there are none.
+func conjunction(predicates []expression) *expression {
+	if len(predicates) == 0 {
+		return nil
+	}
+
+	predicate := predicates[0]
+	for _, next := range predicates[1:] {
+		predicate = expression{
+			kind: binaryKind,
+			binary: &binaryExpression{
+				a:  predicate,
+				b:  next,
+				op: tokenFromKeyword(AndKeyword),
+			},
+		}
+	}
+
+	return &predicate
+}
+
+// newFilterPlan ANDs predicates back together into a filter over
+// child, or returns child alone if there is nothing to filter on.
+func newFilterPlan(predicates []expression, child *plan) *plan {
+	predicate := conjunction(predicates)
+	if predicate == nil {
+		return child
+	}
+
+	return &plan{
+		kind: filterPlanKind,
+		filter: &filterPlan{
+			predicate: *predicate,
+			child:     child,
+		},
+	}
+}
+
+// columnRef is a column, qualified by the table or alias it comes
+// from when that is known.
+type columnRef struct {
+	table string
+	name  string
+}
+
+// referencedColumns appends every column e refers to that is not
+// already in columns.
+func referencedColumns(e expression, columns []columnRef) []columnRef {
+	switch e.kind {
+	case literalKind:
+		if e.literal.Kind != IdentifierKind {
+			return columns
+		}
+
+		ref := columnRef{name: e.literal.Value}
+		if e.table != nil {
+			ref.table = e.table.Value
+		}
+
+		for _, column := range columns {
+			if column == ref {
+				return columns
+			}
+		}
+
+		return append(columns, ref)
+	case binaryKind:
+		columns = referencedColumns(e.binary.a, columns)
+		return referencedColumns(e.binary.b, columns)
+	case functionKind:
+		for _, arg := range e.function.args {
+			columns = referencedColumns(arg, columns)
+		}
+	case subqueryKind:
+		// Which columns of a subquery are the outer query's isn't known
+		// without the catalog, so all of them are taken to be
+		if e.subquery.in != nil {
+			columns = referencedColumns(*e.subquery.in, columns)
+		}
+
+		return selectReferencedColumns(e.subquery.slct, columns)
+	}
+
+	return columns
+}
+
+// selectReferencedColumns appends every column the expressions of slct
+// refer to that is not already in columns.
+func selectReferencedColumns(slct *SelectStatement, columns []columnRef) []columnRef {
+	if slct.set != nil {
+		columns = selectReferencedColumns(slct.set.left, columns)
+		columns = selectReferencedColumns(slct.set.right, columns)
+		return withReferencedColumns(slct.with, columns)
+	}
+
+	exps := []*expression{slct.where}
+	for _, item := range *slct.item {
+		exps = append(exps, item.exp)
+	}
+
+	for _, join := range slct.joins {
+		exps = append(exps, join.on)
+	}
+
+	exps = append(exps, slct.distinctOn...)
+	for _, exp := range append(exps, slct.groupBy...) {
+		if exp != nil {
+			columns = referencedColumns(*exp, columns)
+		}
+	}
+
+	for _, from := range append([]*fromItem{slct.from}, joinedItems(slct.joins)...) {
+		if from != nil && from.subquery != nil {
+			columns = selectReferencedColumns(from.subquery, columns)
+		}
+	}
+
+	return withReferencedColumns(slct.with, columns)
+}
+
+// withReferencedColumns appends every column the common table
+// expressions of with refer to that is not already in columns.
+func withReferencedColumns(with *withClause, columns []columnRef) []columnRef {
+	if with == nil {
+		return columns
+	}
+
+	for _, cte := range with.ctes {
+		columns = selectReferencedColumns(cte.slct, columns)
+		if cte.recursive != nil {
+			columns = selectReferencedColumns(cte.recursive, columns)
+		}
+	}
+
+	return columns
+}
+
+// planColumns returns the columns a plan produces, or false if they
+// can't be known from the catalog.
+func planColumns(p *plan, c catalog) ([]columnRef, bool) {
+	if p == nil {
+		return nil, true
+	}
+
+	var table Token
+	var as *Token
+	switch p.kind {
+	case scanPlanKind:
+		table, as = p.scan.table, p.scan.as
+	case indexScanPlanKind:
+		table, as = p.indexScan.table, p.indexScan.as
+	case filterPlanKind:
+		return planColumns(p.filter.child, c)
+	case distinctPlanKind:
+		return planColumns(p.distinct.child, c)
+	case joinPlanKind:
+		left, ok := planColumns(p.join.left, c)
+		if !ok {
+			return nil, false
+		}
+
+		right, ok := planColumns(p.join.right, c)
+		if !ok {
+			return nil, false
+		}
+
+		return append(append([]columnRef{}, left...), right...), true
+	case projectPlanKind:
+		columns := []columnRef{}
+		for _, item := range p.project.items {
+			if !item.asterisk {
+				columns = append(columns, columnRef{name: selectItemName(item)})
+				continue
+			}
+
+			child, ok := planColumns(p.project.child, c)
+			if !ok {
+				return nil, false
+			}
+
+			columns = append(columns, child...)
+		}
+
+		return columns, true
+	case subqueryScanPlanKind:
+		child, ok := planColumns(p.subquery.child, c)
+		if !ok {
+			return nil, false
+		}
+
+		columns := []columnRef{}
+		for _, column := range child {
+			columns = append(columns, columnRef{table: p.subquery.as.Value, name: column.name})
+		}
+
+		return columns, true
+	case setOperationPlanKind:
+		left, ok := planColumns(p.set.left, c)
+		if !ok {
+			return nil, false
+		}
+
+		// The columns are named after the left side's, but belong to
+		// no table
+		columns := []columnRef{}
+		for _, column := range left {
+			columns = append(columns, columnRef{name: column.name})
+		}
+
+		return columns, true
+	case cteScanPlanKind:
+		names, ok := p.cteScan.cte.columnNames(c)
+		if !ok {
+			return nil, false
+		}
+
+		columns := []columnRef{}
+		for _, name := range names {
+			columns = append(columns, columnRef{table: qualifier(p.cteScan.table, p.cteScan.as), name: name})
+		}
+
+		return columns, true
+	case aggregatePlanKind:
+		columns := []columnRef{}
+		for _, key := range p.aggregate.groupBy {
+			if isColumn(key) {
+				columns = referencedColumns(key, columns)
+			} else {
+				columns = append(columns, columnRef{name: key.generateCode()})
+			}
+		}
+
+		for _, aggregate := range p.aggregate.aggregates {
+			columns = append(columns, columnRef{name: aggregate.generateCode()})
+		}
+
+		return columns, true
+	}
+
+	names, ok := c.tableColumns(table.Value)
+	if !ok {
+		return nil, false
+	}
+
+	columns := []columnRef{}
+	for _, name := range names {
+		columns = append(columns, columnRef{table: qualifier(table, as), name: name})
+	}
+
+	return columns, true
+}
+
+// covers reports whether every reference resolves to one of columns.
+func covers(columns []columnRef, refs []columnRef) bool {
+outer:
+	for _, ref := range refs {
+		for _, column := range columns {
+			if column.name == ref.name && (ref.table == "" || ref.table == column.table) {
+				continue outer
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func pushDownPredicates(p *plan, c catalog) *plan {
+	if p == nil {
+		return nil
+	}
+
+	switch p.kind {
+	case filterPlanKind:
+		child := pushDownPredicates(p.filter.child, c)
+		return pushDownFilter(conjuncts(p.filter.predicate), child, c)
+	case projectPlanKind:
+		p.project.child = pushDownPredicates(p.project.child, c)
+	case joinPlanKind:
+		p.join.left = pushDownPredicates(p.join.left, c)
+		p.join.right = pushDownPredicates(p.join.right, c)
+	case aggregatePlanKind:
+		p.aggregate.child = pushDownPredicates(p.aggregate.child, c)
+	case subqueryScanPlanKind:
+		p.subquery.child = pushDownPredicates(p.subquery.child, c)
+	case setOperationPlanKind:
+		p.set.left = pushDownPredicates(p.set.left, c)
+		p.set.right = pushDownPredicates(p.set.right, c)
+	case distinctPlanKind:
+		p.distinct.child = pushDownPredicates(p.distinct.child, c)
+	}
+
+	return p
+}
+
+// pushDownFilter places each predicate as close to the scans as it can
+// go while still seeing the columns it refers to.
+func pushDownFilter(predicates []expression, child *plan, c catalog) *plan {
+	if len(predicates) == 0 || child == nil {
+		return newFilterPlan(predicates, child)
+	}
+
+	switch child.kind {
+	case filterPlanKind:
+		merged := append(conjuncts(child.filter.predicate), predicates...)
+		return pushDownFilter(merged, child.filter.child, c)
+	case projectPlanKind:
+		var pushed, kept []expression
+		for _, predicate := range predicates {
+			if rewritten, ok := unprojectExpression(predicate, child.project.items); ok {
+				pushed = append(pushed, rewritten)
+			} else {
+				kept = append(kept, predicate)
+			}
+		}
+
+		child.project.child = pushDownFilter(pushed, child.project.child, c)
+		return newFilterPlan(kept, child)
+	case subqueryScanPlanKind:
+		// Filtering the rows of a subquery that groups, removes
+		// duplicates or combines queries first would change what those
+		// see, so only plain projections are filtered through
+		project := child.subquery.child
+		if project == nil || project.kind != projectPlanKind || (project.project.child != nil && project.project.child.kind == aggregatePlanKind) {
+			break
+		}
+
+		var pushed, kept []expression
+		for _, predicate := range predicates {
+			// Columns of any other table aren't visible inside
+			unqualified := unqualify(predicate, child.subquery.as.Value)
+			qualified := false
+			for _, ref := range referencedColumns(unqualified, nil) {
+				qualified = qualified || ref.table != ""
+			}
+
+			if rewritten, ok := unprojectExpression(unqualified, project.project.items); ok && !qualified {
+				pushed = append(pushed, rewritten)
+			} else {
+				kept = append(kept, predicate)
+			}
+		}
+
+		project.project.child = pushDownFilter(pushed, project.project.child, c)
+		return newFilterPlan(kept, child)
+	case joinPlanKind:
+		left, leftOk := planColumns(child.join.left, c)
+		right, rightOk := planColumns(child.join.right, c)
+		if !leftOk || !rightOk {
+			break
+		}
+
+		var kept, toLeft, toRight, toJoin []expression
+		if child.join.cond != nil {
+			toJoin = conjuncts(*child.join.cond)
+		}
+
+		for _, predicate := range predicates {
+			refs := referencedColumns(predicate, nil)
+			inLeft, inRight := covers(left, refs), covers(right, refs)
+
+			switch {
+			case len(refs) == 0:
+				kept = append(kept, predicate)
+			case inLeft && !inRight:
+				toLeft = append(toLeft, predicate)
+			case inRight && !inLeft:
+				toRight = append(toRight, predicate)
+			default:
+				// Needs both sides, or is ambiguous and should be
+				// reported as such against the joined row
+				toJoin = append(toJoin, predicate)
+			}
+		}
+
+		child.join.left = pushDownFilter(toLeft, child.join.left, c)
+		child.join.right = pushDownFilter(toRight, child.join.right, c)
+		child.join.cond = conjunction(toJoin)
+		return newFilterPlan(kept, child)
+	}
+
+	return newFilterPlan(predicates, child)
+}
+
+// selectItemName is the column name a select item is visible as to
+// the operators above it.
+func selectItemName(item *selectItem) string {
+	if item.as != nil {
+		return item.as.Value
+	}
+
+	if item.exp != nil && item.exp.kind == literalKind && item.exp.literal.Kind == IdentifierKind {
+		return item.exp.literal.Value
+	}
+
+	// A scalar subquery is named after its column, and EXISTS after
+	// itself
+	if item.exp != nil && item.exp.kind == subqueryKind {
+		subquery := item.exp.subquery
+		items := *subquery.slct.first().item
+		switch {
+		case subquery.exists:
+			return "exists"
+		case subquery.in == nil && len(items) == 1 && !items[0].asterisk:
+			return selectItemName(items[0])
+		}
+	}
+
+	// A cast is named after what it casts, as in `price::int`, and a
+	// bare call after its function, as in `now`
+	if item.exp != nil && item.exp.kind == functionKind {
+		if item.exp.function.cast != nil {
+			return selectItemName(&selectItem{exp: &item.exp.function.args[0]})
+		}
+
+		if item.exp.function.isUnary() {
+			return "?column?"
+		}
+
+		return item.exp.function.name.Value
+	}
+
+	return "?column?"
+}
+
+// unprojectExpression rewrites an expression over the output of a
+// projection into one over the projection's input, substituting the
+// projected expression for every column it references.
+func unprojectExpression(e expression, items []*selectItem) (expression, bool) {
+	switch e.kind {
+	case literalKind:
+		if e.literal.Kind != IdentifierKind {
+			return e, true
+		}
+
+		asterisk := false
+		for _, item := range items {
+			if item.asterisk {
+				asterisk = true
+				continue
+			}
+
+			if e.table == nil && selectItemName(item) == e.literal.Value {
+				return *item.exp, true
+			}
+		}
+
+		// Columns passed through by * mean the same thing on either side
+		return e, asterisk
+	case binaryKind:
+		a, ok := unprojectExpression(e.binary.a, items)
+		if !ok {
+			return e, false
+		}
+
+		b, ok := unprojectExpression(e.binary.b, items)
+		if !ok {
+			return e, false
+		}
+
+		return expression{
+			kind: binaryKind,
+			binary: &binaryExpression{
+				a:  a,
+				b:  b,
+				op: e.binary.op,
+			},
+		}, true
+	case functionKind:
+		function := *e.function
+		function.args = nil
+		for _, arg := range e.function.args {
+			rewritten, ok := unprojectExpression(arg, items)
+			if !ok {
+				return e, false
+			}
+
+			function.args = append(function.args, rewritten)
+		}
+
+		return expression{function: &function, kind: functionKind}, true
+	}
+
+	return e, false
+}
+
+// unqualify drops the table from every column reference qualified
+// with it.
+func unqualify(e expression, table string) expression {
+	switch e.kind {
+	case literalKind:
+		if e.table != nil && e.table.Value == table {
+			e.table = nil
+		}
+	case binaryKind:
+		return expression{
+			kind: binaryKind,
+			binary: &binaryExpression{
+				a:  unqualify(e.binary.a, table),
+				b:  unqualify(e.binary.b, table),
+				op: e.binary.op,
+			},
+		}
+	case functionKind:
+		function := *e.function
+		function.args = nil
+		for _, arg := range e.function.args {
+			function.args = append(function.args, unqualify(arg, table))
+		}
+
+		return expression{function: &function, kind: functionKind}
+	}
+
+	return e
+}
+
+// useIndexes replaces a scan filtered on `indexed expression =
+// constant` with a lookup in that index.
+func useIndexes(p *plan, c catalog) *plan {
+	if p == nil {
+		return nil
+	}
+
+	switch p.kind {
+	case projectPlanKind:
+		p.project.child = useIndexes(p.project.child, c)
+	case joinPlanKind:
+		p.join.left = useIndexes(p.join.left, c)
+		p.join.right = useIndexes(p.join.right, c)
+	case aggregatePlanKind:
+		p.aggregate.child = useIndexes(p.aggregate.child, c)
+	case subqueryScanPlanKind:
+		p.subquery.child = useIndexes(p.subquery.child, c)
+	case setOperationPlanKind:
+		p.set.left = useIndexes(p.set.left, c)
+		p.set.right = useIndexes(p.set.right, c)
+	case distinctPlanKind:
+		p.distinct.child = useIndexes(p.distinct.child, c)
+	case filterPlanKind:
+		child := useIndexes(p.filter.child, c)
+		if child == nil || child.kind != scanPlanKind {
+			p.filter.child = child
+			return p
+		}
+
+		eq := tokenFromSymbol(EqSymbol)
+		predicates := conjuncts(p.filter.predicate)
+		for i, predicate := range predicates {
+			if predicate.kind != binaryKind || !predicate.binary.op.equals(&eq) {
+				continue
+			}
+
+			indexed, value := predicate.binary.a, predicate.binary.b
+			if isConstant(indexed) {
+				indexed, value = value, indexed
+			}
+
+			if !isConstant(value) {
+				continue
+			}
+
+			scan := child.scan
+			index, ok := c.indexFor(scan.table.Value, unqualify(indexed, qualifier(scan.table, scan.as)))
+			if !ok {
+				continue
+			}
+
+			rest := append(append([]expression{}, predicates[:i]...), predicates[i+1:]...)
+			return newFilterPlan(rest, &plan{
+				kind: indexScanPlanKind,
+				indexScan: &indexScanPlan{
+					table: scan.table,
+					as:    scan.as,
+					index: index,
+					value: value,
+					cond:  predicate,
+				},
+			})
+		}
+
+		p.filter.child = child
+	}
+
+	return p
+}
+
+// statisticsLookup returns a lookup of column statistics from the
+// tables scanned below p.
+func (p *plan) statisticsLookup(c catalog) statisticsLookup {
+	return func(column expression) *columnStatistics {
+		var found *columnStatistics
+		var visit func(p *plan)
+		visit = func(p *plan) {
+			if p == nil {
+				return
+			}
+
+			var table Token
+			var as *Token
+			switch p.kind {
+			case scanPlanKind:
+				table, as = p.scan.table, p.scan.as
+			case indexScanPlanKind:
+				table, as = p.indexScan.table, p.indexScan.as
+			case subqueryScanPlanKind, setOperationPlanKind:
+				// The tables in a subquery aren't visible outside it
+				return
+			default:
+				for _, child := range p.children() {
+					visit(child)
+				}
+				return
+			}
+
+			if column.table != nil && column.table.Value != qualifier(table, as) {
+				return
+			}
+
+			if stats := c.statistics(table.Value); stats != nil {
+				if cs, ok := stats.columns[column.literal.Value]; ok {
+					found = cs
+				}
+			}
+		}
+
+		visit(p)
+		return found
+	}
+}
+
+// estimateRows fills in the estimated number of rows every node of a
+// plan produces.
+func estimateRows(p *plan, c catalog) float64 {
+	if p == nil {
+		return 1
+	}
+
+	for _, child := range p.children() {
+		estimateRows(child, c)
+	}
+
+	tableRows := func(table string) float64 {
+		if stats := c.statistics(table); stats != nil {
+			return float64(stats.rows)
+		}
+
+		return defaultRows
+	}
+
+	switch p.kind {
+	case scanPlanKind:
+		p.rows = tableRows(p.scan.table.Value)
+	case indexScanPlanKind:
+		p.rows = tableRows(p.indexScan.table.Value) * selectivity(p.indexScan.cond, p.statisticsLookup(c))
+	case filterPlanKind:
+		p.rows = estimateRows(p.filter.child, c) * selectivity(p.filter.predicate, p.statisticsLookup(c))
+	case projectPlanKind:
+		p.rows = estimateRows(p.project.child, c)
+	case joinPlanKind:
+		p.rows = p.join.left.rows * p.join.right.rows
+		if p.join.cond != nil {
+			p.rows *= selectivity(*p.join.cond, p.statisticsLookup(c))
+		}
+	case aggregatePlanKind:
+		p.rows = estimateGroups(p, c)
+	case subqueryScanPlanKind:
+		p.rows = p.subquery.child.rows
+	case cteScanPlanKind:
+		p.rows = defaultRows
+	case distinctPlanKind:
+		p.rows = p.distinct.child.rows
+	case setOperationPlanKind:
+		switch p.set.op {
+		case UnionKeyword:
+			p.rows = p.set.left.rows + p.set.right.rows
+		case IntersectKeyword:
+			p.rows = math.Min(p.set.left.rows, p.set.right.rows)
+		default:
+			p.rows = p.set.left.rows
+		}
+	}
+
+	return p.rows
+}
+
+// estimateGroups estimates how many groups an aggregation produces:
+// the number of distinct combinations of its keys, at most one per
+// input row.
+func estimateGroups(p *plan, c catalog) float64 {
+	input := estimateRows(p.aggregate.child, c)
+	if len(p.aggregate.groupBy) == 0 {
+		return 1
+	}
+
+	lookup := p.statisticsLookup(c)
+	groups := 1.0
+	for _, key := range p.aggregate.groupBy {
+		var stats *columnStatistics
+		if isColumn(key) {
+			stats = lookup(key)
+		}
+
+		if stats == nil || stats.distinct == 0 {
+			// Without statistics assume every key splits the input
+			// as an equality would
+			groups /= defaultEqSelectivity
+			continue
+		}
+
+		groups *= float64(stats.distinct)
+	}
+
+	return math.Max(1, math.Min(groups, input))
+}
+
+// Relative costs used to pick a join algorithm, in units of handling
+// one row.
+const (
+	// hashBuildCost is how much more inserting a row into a hash table
+	// costs than probing it
+	hashBuildCost = 2
+	// maxHashBuildRows bounds the build side of a hash join, above it
+	// a merge join that only needs its inputs sorted is used instead
+	maxHashBuildRows = 1 << 20
+)
+
+// joinCost estimates the cost of joining left and right with an
+// algorithm, infinite if the algorithm can't be used.
+func joinCost(algorithm joinAlgorithm, left, right float64, equi bool) float64 {
+	switch algorithm {
+	case hashJoin:
+		if !equi || right > maxHashBuildRows {
+			return math.Inf(1)
+		}
+
+		return left + right*hashBuildCost
+	case mergeJoin:
+		if !equi {
+			return math.Inf(1)
+		}
+
+		sort := func(n float64) float64 {
+			return n * math.Log2(math.Max(n, 2))
+		}
+
+		return sort(left) + sort(right) + left + right
+	}
+
+	return left * right
+}
+
+// newJoinPlan joins two plans on predicates, picking the cheapest
+// join algorithm for their estimated sizes.
+func newJoinPlan(left, right *plan, predicates []expression, c catalog) *plan {
+	leftColumns, _ := planColumns(left, c)
+	rightColumns, _ := planColumns(right, c)
+
+	// Equalities between one side and the other can be used as keys
+	var leftKeys, rightKeys []expression
+	eq := tokenFromSymbol(EqSymbol)
+	for _, predicate := range predicates {
+		if predicate.kind != binaryKind || !predicate.binary.op.equals(&eq) {
+			continue
+		}
+
+		a, b := predicate.binary.a, predicate.binary.b
+		aRefs, bRefs := referencedColumns(a, nil), referencedColumns(b, nil)
+		if len(aRefs) == 0 || len(bRefs) == 0 {
+			continue
+		}
+
+		if covers(leftColumns, aRefs) && covers(rightColumns, bRefs) && !covers(rightColumns, aRefs) && !covers(leftColumns, bRefs) {
+			leftKeys, rightKeys = append(leftKeys, a), append(rightKeys, b)
+		} else if covers(leftColumns, bRefs) && covers(rightColumns, aRefs) && !covers(rightColumns, bRefs) && !covers(leftColumns, aRefs) {
+			leftKeys, rightKeys = append(leftKeys, b), append(rightKeys, a)
+		}
+	}
+
+	equi := len(leftKeys) > 0
+
+	// Build hash tables from the smaller side
+	if equi && left.rows < right.rows {
+		left, right = right, left
+		leftKeys, rightKeys = rightKeys, leftKeys
+	}
+
+	join := &joinPlan{
+		left:      left,
+		right:     right,
+		cond:      conjunction(predicates),
+		algorithm: nestedLoopJoin,
+	}
+
+	best := joinCost(nestedLoopJoin, left.rows, right.rows, equi)
+	for _, algorithm := range []joinAlgorithm{hashJoin, mergeJoin} {
+		if cost := joinCost(algorithm, left.rows, right.rows, equi); cost < best {
+			best = cost
+			join.algorithm = algorithm
+		}
+	}
+
+	if join.algorithm != nestedLoopJoin {
+		join.leftKeys, join.rightKeys = leftKeys, rightKeys
+	}
+
+	p := &plan{kind: joinPlanKind, join: join}
+	estimateRows(p, c)
+	return p
+}
+
+// flattenJoins collects the inputs of a tree of joins and the
+// predicates the joins were made on.
+func flattenJoins(p *plan) ([]*plan, []expression) {
+	if p.kind != joinPlanKind {
+		return []*plan{p}, nil
+	}
+
+	leftInputs, leftPredicates := flattenJoins(p.join.left)
+	rightInputs, rightPredicates := flattenJoins(p.join.right)
+
+	predicates := append(leftPredicates, rightPredicates...)
+	if p.join.cond != nil {
+		predicates = append(predicates, conjuncts(*p.join.cond)...)
+	}
+
+	return append(leftInputs, rightInputs...), predicates
+}
+
+// reorderJoins picks the order of every tree of joins in a plan.
+func reorderJoins(p *plan, c catalog) *plan {
+	if p == nil {
+		return nil
+	}
+
+	switch p.kind {
+	case filterPlanKind:
+		p.filter.child = reorderJoins(p.filter.child, c)
+	case projectPlanKind:
+		p.project.child = reorderJoins(p.project.child, c)
+	case aggregatePlanKind:
+		p.aggregate.child = reorderJoins(p.aggregate.child, c)
+	case subqueryScanPlanKind:
+		p.subquery.child = reorderJoins(p.subquery.child, c)
+	case setOperationPlanKind:
+		p.set.left = reorderJoins(p.set.left, c)
+		p.set.right = reorderJoins(p.set.right, c)
+	case distinctPlanKind:
+		p.distinct.child = reorderJoins(p.distinct.child, c)
+	case joinPlanKind:
+		return orderJoins(p, c)
+	}
+
+	return p
+}
+
+// orderJoins greedily joins on the input that produces the fewest
+// rows, starting from the smallest input, and picks the cheapest
+// algorithm for each join.
+func orderJoins(p *plan, c catalog) *plan {
+	inputs, predicates := flattenJoins(p)
+	for i, input := range inputs {
+		inputs[i] = reorderJoins(input, c)
+		estimateRows(inputs[i], c)
+	}
+
+	// Without knowing every input's columns predicates can't be placed
+	columns := [][]columnRef{}
+	for _, input := range inputs {
+		inputColumns, ok := planColumns(input, c)
+		if !ok {
+			return p
+		}
+
+		columns = append(columns, inputColumns)
+	}
+
+	smallest := 0
+	for i, input := range inputs {
+		if input.rows < inputs[smallest].rows {
+			smallest = i
+		}
+	}
+
+	used := make([]bool, len(predicates))
+	joined := make([]bool, len(inputs))
+	joined[smallest] = true
+	current := inputs[smallest]
+	currentColumns := columns[smallest]
+
+	for remaining := len(inputs) - 1; remaining > 0; remaining-- {
+		var best *plan
+		bestInput := -1
+		var bestPredicates []int
+		for i, input := range inputs {
+			if joined[i] {
+				continue
+			}
+
+			combined := append(append([]columnRef{}, currentColumns...), columns[i]...)
+			var applicable []int
+			var onPredicates []expression
+			for j, predicate := range predicates {
+				if !used[j] && covers(combined, referencedColumns(predicate, nil)) {
+					applicable = append(applicable, j)
+					onPredicates = append(onPredicates, predicate)
+				}
+			}
+
+			candidate := newJoinPlan(current, input, onPredicates, c)
+			if best == nil || candidate.rows < best.rows {
+				best, bestInput, bestPredicates = candidate, i, applicable
+			}
+		}
+
+		for _, j := range bestPredicates {
+			used[j] = true
+		}
+
+		joined[bestInput] = true
+		current = best
+		currentColumns = append(currentColumns, columns[bestInput]...)
+	}
+
+	var rest []expression
+	for j, predicate := range predicates {
+		if !used[j] {
+			rest = append(rest, predicate)
+		}
+	}
+
+	return newFilterPlan(rest, current)
+}
+
+// pruneColumns limits scans to the columns the operators above them
+// read. A nil columns means every column is needed.
+func pruneColumns(p *plan, columns []columnRef, c catalog) {
+	if p == nil {
+		return
+	}
+
+	scanColumns := func(table Token, as *Token) []string {
+		if columns == nil {
+			return nil
+		}
+
+		tableColumns, known := c.tableColumns(table.Value)
+		names := []string{}
+		for _, column := range columns {
+			if column.table != "" && column.table != qualifier(table, as) {
+				continue
+			}
+
+			exists := !known
+			for _, name := range tableColumns {
+				exists = exists || name == column.name
+			}
+
+			duplicate := false
+			for _, name := range names {
+				duplicate = duplicate || name == column.name
+			}
+
+			if exists && !duplicate {
+				names = append(names, column.name)
+			}
+		}
+
+		return names
+	}
+
+	switch p.kind {
+	case scanPlanKind:
+		p.scan.columns = scanColumns(p.scan.table, p.scan.as)
+	case indexScanPlanKind:
+		p.indexScan.columns = scanColumns(p.indexScan.table, p.indexScan.as)
+	case filterPlanKind:
+		if columns != nil {
+			columns = referencedColumns(p.filter.predicate, columns)
+		}
+
+		pruneColumns(p.filter.child, columns, c)
+	case joinPlanKind:
+		if columns != nil && p.join.cond != nil {
+			columns = referencedColumns(*p.join.cond, columns)
+		}
+
+		pruneColumns(p.join.left, columns, c)
+		pruneColumns(p.join.right, columns, c)
+	case projectPlanKind:
+		needed := []columnRef{}
+		for _, item := range p.project.items {
+			if item.asterisk {
+				needed = nil
+				break
+			}
+
+			needed = referencedColumns(*item.exp, needed)
+		}
+
+		pruneColumns(p.project.child, needed, c)
+	case aggregatePlanKind:
+		needed := []columnRef{}
+		for _, key := range p.aggregate.groupBy {
+			needed = referencedColumns(key, needed)
+		}
+
+		for _, aggregate := range p.aggregate.aggregates {
+			needed = referencedColumns(aggregate, needed)
+		}
+
+		pruneColumns(p.aggregate.child, needed, c)
+	case subqueryScanPlanKind:
+		// The subquery's projection decides what it reads
+		pruneColumns(p.subquery.child, nil, c)
+	case setOperationPlanKind:
+		// Every column of both sides is compared
+		pruneColumns(p.set.left, nil, c)
+		pruneColumns(p.set.right, nil, c)
+	case distinctPlanKind:
+		if columns != nil {
+			for _, exp := range p.distinct.on {
+				columns = referencedColumns(exp, columns)
+			}
+		}
+
+		pruneColumns(p.distinct.child, columns, c)
+	}
+}
@@ -0,0 +1,373 @@
+package ashudb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCatalog struct {
+	// indexes maps table.expression to an index name
+	indexes map[string]string
+	columns map[string][]string
+	stats   map[string]*tableStatistics
+}
+
+func (tc testCatalog) indexFor(table string, exp expression) (string, bool) {
+	name, ok := tc.indexes[table+"."+exp.generateCode()]
+	return name, ok
+}
+
+func (tc testCatalog) tableColumns(table string) ([]string, bool) {
+	columns, ok := tc.columns[table]
+	return columns, ok
+}
+
+func (tc testCatalog) statistics(table string) *tableStatistics {
+	return tc.stats[table]
+}
+
+func parseSelect(t testing.TB, source string) *SelectStatement {
+	ast, err := Parse(source)
+	assert.Nil(t, err, source)
+	assert.Equal(t, SelectKind, ast.Statements[0].Kind, source)
+	return ast.Statements[0].SelectStatement
+}
+
+func TestOptimize_foldConstants(t *testing.T) {
+	tests := []struct {
+		source     string
+		items      []string
+		predicates []string
+	}{
+		{
+			source: "SELECT 1 + 2 * 3;",
+			items:  []string{"7"},
+		},
+		{
+			source: "SELECT 'a' || 'b', id - (4 / 2) FROM users;",
+			items:  []string{"'ab'", "(id - 2)"},
+		},
+		{
+			source:     "SELECT id FROM users WHERE 1 = 1 AND id > 2 + 3;",
+			items:      []string{"id"},
+			predicates: []string{"(id > 5)"},
+		},
+		{
+			source:     "SELECT id FROM users WHERE 1 = 2;",
+			items:      []string{"id"},
+			predicates: []string{"FALSE"},
+		},
+		{
+			source:     "SELECT id FROM users WHERE active = TRUE AND TRUE;",
+			items:      []string{"id"},
+			predicates: []string{"(active = TRUE)"},
+		},
+		{
+			source: "SELECT 1 < 2, TRUE OR FALSE;",
+			items:  []string{"TRUE", "TRUE"},
+		},
+		{
+			// Left for execution to report
+			source: "SELECT 1 / 0;",
+			items:  []string{"(1 / 0)"},
+		},
+	}
+
+	for _, test := range tests {
+		p := optimize(newPlan(parseSelect(t, test.source)), testCatalog{})
+		assert.Equal(t, projectPlanKind, p.kind, test.source)
+
+		var items []string
+		for _, item := range p.project.items {
+			items = append(items, item.exp.generateCode())
+		}
+		assert.Equal(t, test.items, items, test.source)
+
+		var predicates []string
+		if child := p.project.child; child != nil && child.kind == filterPlanKind {
+			for _, predicate := range conjuncts(child.filter.predicate) {
+				predicates = append(predicates, predicate.generateCode())
+			}
+		}
+		assert.Equal(t, test.predicates, predicates, test.source)
+	}
+}
+
+func TestOptimize_pushDownPredicates(t *testing.T) {
+	// A filter over the output of a projection, as a derived table
+	// would produce
+	inner := newPlan(parseSelect(t, "SELECT id, name AS fullname, id + 1 AS next FROM users;"))
+	outer := parseSelect(t, "SELECT 1 WHERE fullname = 'Phil' AND next > 2;")
+	p := &plan{
+		kind: filterPlanKind,
+		filter: &filterPlan{
+			predicate: *outer.where,
+			child:     inner,
+		},
+	}
+
+	p = optimize(p, testCatalog{})
+
+	assert.Equal(t, projectPlanKind, p.kind)
+	filter := p.project.child
+	assert.Equal(t, filterPlanKind, filter.kind)
+	assert.Equal(t, "((name = 'Phil') AND ((id + 1) > 2))", filter.filter.predicate.generateCode())
+	assert.Equal(t, scanPlanKind, filter.filter.child.kind)
+
+	// Filters over a subquery in FROM
+	tests := []struct {
+		source string
+		kept   string
+		pushed string
+	}{
+		{
+			source: "SELECT * FROM (SELECT id AS z FROM users) s WHERE z > 1;",
+			pushed: "(id > 1)",
+		},
+		{
+			source: "SELECT * FROM (SELECT * FROM users) s WHERE s.id > 1 AND name = 'Phil';",
+			pushed: "((id > 1) AND (name = 'Phil'))",
+		},
+		{
+			source: "SELECT * FROM (SELECT id AS z FROM users) s WHERE z > (SELECT 1);",
+			kept:   "(z > (SELECT 1))",
+		},
+		{
+			source: "SELECT * FROM (SELECT count(*) AS n FROM users) s WHERE n > 1;",
+			kept:   "(n > 1)",
+		},
+		{
+			source: "SELECT * FROM (SELECT DISTINCT id FROM users) s WHERE id > 1;",
+			kept:   "(id > 1)",
+		},
+		{
+			source: "SELECT * FROM (SELECT id FROM users UNION SELECT id FROM admins) s WHERE id > 1;",
+			kept:   "(id > 1)",
+		},
+	}
+
+	for _, test := range tests {
+		p := optimize(newPlan(parseSelect(t, test.source)), testCatalog{})
+
+		var kept, pushed string
+		scan := p.project.child
+		if scan.kind == filterPlanKind {
+			kept = scan.filter.predicate.generateCode()
+			scan = scan.filter.child
+		}
+
+		assert.Equal(t, subqueryScanPlanKind, scan.kind, test.source)
+		if inner := scan.subquery.child; inner.kind == projectPlanKind && inner.project.child.kind == filterPlanKind {
+			pushed = inner.project.child.filter.predicate.generateCode()
+		}
+
+		assert.Equal(t, test.kept, kept, test.source)
+		assert.Equal(t, test.pushed, pushed, test.source)
+	}
+}
+
+func TestOptimize_pruneColumns(t *testing.T) {
+	tests := []struct {
+		source  string
+		columns []string
+	}{
+		{
+			source:  "SELECT name FROM users WHERE id = 2;",
+			columns: []string{"name", "id"},
+		},
+		{
+			source:  "SELECT id + id, 'x' FROM users;",
+			columns: []string{"id"},
+		},
+		{
+			source:  "SELECT 1 FROM users;",
+			columns: []string{},
+		},
+		{
+			source:  "SELECT *, id FROM users;",
+			columns: nil,
+		},
+	}
+
+	for _, test := range tests {
+		p := optimize(newPlan(parseSelect(t, test.source)), testCatalog{})
+
+		scan := p.project.child
+		for scan.kind == filterPlanKind {
+			scan = scan.filter.child
+		}
+
+		assert.Equal(t, scanPlanKind, scan.kind, test.source)
+		assert.Equal(t, test.columns, scan.scan.columns, test.source)
+	}
+}
+
+func TestOptimize_useIndexes(t *testing.T) {
+	c := testCatalog{
+		indexes: map[string]string{
+			"users.id":            "users_id",
+			"users.(name || 'x')": "users_name_x",
+		},
+	}
+
+	tests := []struct {
+		source    string
+		index     string
+		cond      string
+		remaining string
+	}{
+		{
+			source: "SELECT name FROM users WHERE id = 1 + 1;",
+			index:  "users_id",
+			cond:   "(id = 2)",
+		},
+		{
+			source:    "SELECT name FROM users WHERE name <> 'a' AND 3 = id;",
+			index:     "users_id",
+			cond:      "(3 = id)",
+			remaining: "(name <> 'a')",
+		},
+		{
+			source: "SELECT name FROM users WHERE name || 'x' = 'Philx';",
+			index:  "users_name_x",
+			cond:   "((name || 'x') = 'Philx')",
+		},
+		{
+			source:    "SELECT name FROM users WHERE id > 2;",
+			remaining: "(id > 2)",
+		},
+		{
+			source:    "SELECT name FROM users WHERE id = id;",
+			remaining: "(id = id)",
+		},
+	}
+
+	for _, test := range tests {
+		p := optimize(newPlan(parseSelect(t, test.source)), c)
+
+		child := p.project.child
+		if test.remaining != "" {
+			assert.Equal(t, filterPlanKind, child.kind, test.source)
+			assert.Equal(t, test.remaining, child.filter.predicate.generateCode(), test.source)
+			child = child.filter.child
+		}
+
+		if test.index == "" {
+			assert.Equal(t, scanPlanKind, child.kind, test.source)
+			continue
+		}
+
+		assert.Equal(t, indexScanPlanKind, child.kind, test.source)
+		assert.Equal(t, test.index, child.indexScan.index, test.source)
+		assert.Equal(t, test.cond, child.indexScan.cond.generateCode(), test.source)
+	}
+}
+
+func TestOptimize_joins(t *testing.T) {
+	columns := map[string][]string{
+		"users":  {"id", "name"},
+		"orders": {"id", "user_id", "total"},
+		"items":  {"order_id", "sku"},
+	}
+
+	// Analyzed sizes: many orders, fewer users, a single item
+	stats := map[string]*tableStatistics{
+		"users": {
+			rows: 100,
+			columns: map[string]*columnStatistics{
+				"id":   {typ: IntType, distinct: 100},
+				"name": {typ: TextType, distinct: 50},
+			},
+		},
+		"orders": {
+			rows: 10000,
+			columns: map[string]*columnStatistics{
+				"id":      {typ: IntType, distinct: 10000},
+				"user_id": {typ: IntType, distinct: 100},
+			},
+		},
+		"items": {
+			rows: 1,
+			columns: map[string]*columnStatistics{
+				"order_id": {typ: IntType, distinct: 1},
+			},
+		},
+	}
+
+	tests := []struct {
+		source string
+		stats  map[string]*tableStatistics
+		lines  []string
+	}{
+		{
+			// Single table predicates are pushed below the join, the
+			// one needing both sides becomes the join condition
+			source: "SELECT u.name FROM users u, orders o WHERE u.id = o.user_id AND o.total > 10 AND u.name = 'Phil';",
+			lines: []string{
+				"Project: u.name (rows=3333)",
+				"  -> Hash Join: (u.id = o.user_id) (rows=3333)",
+				"    -> Filter: (o.total > 10) (rows=333)",
+				"      -> Scan on orders o (user_id, total) (rows=1000)",
+				"    -> Filter: (u.name = 'Phil') (rows=100)",
+				"      -> Scan on users u (name, id) (rows=1000)",
+			},
+		},
+		{
+			// The most selective join goes first, and a single row
+			// is cheapest to loop over
+			source: "SELECT sku FROM orders JOIN users ON users.id = orders.user_id JOIN items ON items.order_id = orders.id;",
+			stats:  stats,
+			lines: []string{
+				"Project: sku (rows=1)",
+				"  -> Nested Loop: (users.id = orders.user_id) (rows=1)",
+				"    -> Scan on users (id) (rows=100)",
+				"    -> Nested Loop: (items.order_id = orders.id) (rows=1)",
+				"      -> Scan on orders (user_id, id) (rows=10000)",
+				"      -> Scan on items (sku, order_id) (rows=1)",
+			},
+		},
+		{
+			// Without a usable equality only a nested loop will do
+			source: "SELECT * FROM users, items;",
+			stats:  stats,
+			lines: []string{
+				"Project: * (rows=100)",
+				"  -> Nested Loop (rows=100)",
+				"    -> Scan on items (rows=1)",
+				"    -> Scan on users (rows=100)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		c := testCatalog{columns: columns, stats: test.stats}
+		p := optimize(newPlan(parseSelect(t, test.source)), c)
+		assert.Equal(t, test.lines, p.explain(), test.source)
+	}
+}
+
+func TestOptimize_joinAlgorithm(t *testing.T) {
+	tests := []struct {
+		left      float64
+		right     float64
+		equi      bool
+		algorithm joinAlgorithm
+	}{
+		{left: 1000, right: 1000, equi: false, algorithm: nestedLoopJoin},
+		{left: 1000, right: 1, equi: true, algorithm: nestedLoopJoin},
+		{left: 1000, right: 1000, equi: true, algorithm: hashJoin},
+		{left: 1 << 22, right: 1 << 21, equi: true, algorithm: mergeJoin},
+	}
+
+	for _, test := range tests {
+		best := nestedLoopJoin
+		for _, algorithm := range []joinAlgorithm{hashJoin, mergeJoin} {
+			if joinCost(algorithm, test.left, test.right, test.equi) < joinCost(best, test.left, test.right, test.equi) {
+				best = algorithm
+			}
+		}
+
+		assert.Equal(t, test.algorithm, best, test)
+	}
+}
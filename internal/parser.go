@@ -3,6 +3,7 @@ package ashudb
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type Ast struct {
@@ -15,17 +16,318 @@ const (
 	SelectKind AstKind = iota
 	CreateTableKind
 	InsertKind
+	CreateIndexKind
+	ExplainKind
+	AnalyzeKind
+	CreateSequenceKind
 )
 
 type expressionKind uint
 
 const (
 	literalKind expressionKind = iota
+	binaryKind
+	functionKind
+	subqueryKind
+	valueKind
 )
 
+type binaryExpression struct {
+	a  expression
+	b  expression
+	op Token
+}
+
+// functionExpression is a call like `sum(total)`. asterisk is set
+// for `count(*)`, which takes no arguments. CASE is a call too, named
+// by the CASE keyword, whose arguments are each WHEN condition
+// followed by its result, then the ELSE result if there is one.
+type functionExpression struct {
+	name     Token
+	args     []expression
+	asterisk bool
+	// cast is the type of `CAST(x AS type)` or `x::type`, whose only
+	// argument is x
+	cast *typeName
+	// sequences are those of the backend a nextval or currval call is
+	// run against, nil until it is bound to one
+	sequences map[string]*sequence
+}
+
+// subqueryExpression is a SELECT used as a value: a scalar subquery
+// like `(SELECT max(id) FROM users)`, `EXISTS (SELECT ...)` or
+// `x IN (SELECT ...)`.
+type subqueryExpression struct {
+	slct   *SelectStatement
+	exists bool
+	// in is x in `x IN (SELECT ...)`
+	in *expression
+	// not negates EXISTS or IN
+	not bool
+	// bound is the subquery as bound to the backend it runs against,
+	// nil until it is bound to one
+	bound *boundSubquery
+}
+
+// isCase reports whether the call is a CASE expression.
+func (f functionExpression) isCase() bool {
+	return f.name.Kind == KeywordKind && Keyword(f.name.Value) == CaseKeyword
+}
+
+// isUnary reports whether f is a prefix minus or plus, named after its
+// symbol, whose only argument is the operand.
+func (f functionExpression) isUnary() bool {
+	return f.name.Kind == SymbolKind
+}
+
+// typeName is a type as written, like DECIMAL(10, 2).
+type typeName struct {
+	name Token
+	// modifiers are the numbers in parentheses after the type, like
+	// the precision and scale of DECIMAL(10, 2)
+	modifiers []Token
+}
+
+func (t typeName) generateCode() string {
+	code := strings.ToUpper(t.name.Value)
+	if len(t.modifiers) > 0 {
+		var modifiers []string
+		for _, modifier := range t.modifiers {
+			modifiers = append(modifiers, modifier.Value)
+		}
+
+		code += "(" + strings.Join(modifiers, ", ") + ")"
+	}
+
+	return code
+}
+
 type expression struct {
-	literal *Token
-	kind    expressionKind
+	literal  *Token
+	binary   *binaryExpression
+	function *functionExpression
+	kind     expressionKind
+	// table qualifies an identifier literal, as in `users.id`
+	table *Token
+	// typ is the type a string literal is read as, as in
+	// `DATE '2024-01-01'`
+	typ      *Token
+	subquery *subqueryExpression
+	// value is a value substituted for a column, as for the columns of
+	// the row a correlated subquery runs for
+	value *boundValue
+}
+
+// generateCode renders the expression back into SQL, fully
+// parenthesizing binary operations so precedence is unambiguous.
+func (e expression) generateCode() string {
+	switch e.kind {
+	case literalKind:
+		switch e.literal.Kind {
+		case StringKind:
+			s := fmt.Sprintf("'%s'", strings.ReplaceAll(e.literal.Value, "'", "''"))
+			if e.typ != nil {
+				s = strings.ToUpper(e.typ.Value) + " " + s
+			}
+
+			return s
+		case HexKind:
+			return fmt.Sprintf("X'%s'", e.literal.Value)
+		case KeywordKind:
+			return strings.ToUpper(e.literal.Value)
+		}
+
+		if e.table != nil {
+			return e.table.Value + "." + e.literal.Value
+		}
+
+		return e.literal.Value
+	case binaryKind:
+		op := e.binary.op.Value
+		if e.binary.op.Kind == KeywordKind {
+			op = strings.ToUpper(op)
+		}
+
+		return fmt.Sprintf("(%s %s %s)", e.binary.a.generateCode(), op, e.binary.b.generateCode())
+	case functionKind:
+		if e.function.asterisk {
+			return e.function.name.Value + "(*)"
+		}
+
+		if e.function.cast != nil {
+			return fmt.Sprintf("CAST(%s AS %s)", e.function.args[0].generateCode(), e.function.cast.generateCode())
+		}
+
+		if e.function.isUnary() {
+			// Spaced so that negating a negative number isn't a comment
+			return fmt.Sprintf("(%s %s)", e.function.name.Value, e.function.args[0].generateCode())
+		}
+
+		if e.function.isCase() {
+			code := "CASE"
+			args := e.function.args
+			for ; len(args) >= 2; args = args[2:] {
+				code += fmt.Sprintf(" WHEN %s THEN %s", args[0].generateCode(), args[1].generateCode())
+			}
+
+			if len(args) == 1 {
+				code += " ELSE " + args[0].generateCode()
+			}
+
+			return code + " END"
+		}
+
+		var args []string
+		for _, arg := range e.function.args {
+			args = append(args, arg.generateCode())
+		}
+
+		return fmt.Sprintf("%s(%s)", e.function.name.Value, strings.Join(args, ", "))
+	case subqueryKind:
+		not := ""
+		if e.subquery.not {
+			not = "NOT "
+		}
+
+		code := fmt.Sprintf("(%s)", e.subquery.slct.generateCode())
+		if e.subquery.in != nil {
+			return fmt.Sprintf("(%s %sIN %s)", e.subquery.in.generateCode(), not, code)
+		}
+
+		if e.subquery.exists {
+			return not + "EXISTS " + code
+		}
+
+		return code
+	case valueKind:
+		return e.value.generateCode()
+	}
+
+	return ""
+}
+
+// generateCode renders the statement back into SQL, without a
+// trailing semicolon.
+func (s *SelectStatement) generateCode() string {
+	with := ""
+	if s.with != nil {
+		with = s.with.generateCode() + " "
+	}
+
+	if s.set != nil {
+		return with + s.set.generateCode()
+	}
+
+	var items []string
+	for _, item := range *s.item {
+		if item.asterisk {
+			items = append(items, "*")
+			continue
+		}
+
+		code := item.exp.generateCode()
+		if item.as != nil {
+			code += " AS " + item.as.Value
+		}
+
+		items = append(items, code)
+	}
+
+	code := with + "SELECT "
+	if s.distinct {
+		code += "DISTINCT "
+	}
+
+	if len(s.distinctOn) > 0 {
+		var on []string
+		for _, exp := range s.distinctOn {
+			on = append(on, exp.generateCode())
+		}
+
+		code += fmt.Sprintf("ON (%s) ", strings.Join(on, ", "))
+	}
+
+	code += strings.Join(items, ", ")
+
+	if s.from != nil {
+		code += " FROM " + s.from.generateCode()
+		for _, join := range s.joins {
+			if join.on == nil {
+				code += ", " + join.from.generateCode()
+				continue
+			}
+
+			code += fmt.Sprintf(" JOIN %s ON %s", join.from.generateCode(), join.on.generateCode())
+		}
+	}
+
+	if s.where != nil {
+		code += " WHERE " + s.where.generateCode()
+	}
+
+	if len(s.groupBy) > 0 {
+		var keys []string
+		for _, key := range s.groupBy {
+			keys = append(keys, key.generateCode())
+		}
+
+		code += " GROUP BY " + strings.Join(keys, ", ")
+	}
+
+	return code
+}
+
+// withClause is the common table expressions of a query. Each can
+// refer to those before it, and in a WITH RECURSIVE clause to itself.
+type withClause struct {
+	recursive bool
+	ctes      []*commonTableExpression
+}
+
+// commonTableExpression is a query named in a WITH clause, with its
+// columns renamed to columns when they're given. A recursive one is
+// slct UNION [ALL] recursive, where recursive refers to the rows found
+// so far by the expression's name.
+type commonTableExpression struct {
+	name      Token
+	columns   *[]Token
+	slct      *SelectStatement
+	recursive *SelectStatement
+	all       bool
+}
+
+func (w *withClause) generateCode() string {
+	var ctes []string
+	for _, cte := range w.ctes {
+		code := cte.name.Value
+		if cte.columns != nil {
+			var columns []string
+			for _, column := range *cte.columns {
+				columns = append(columns, column.Value)
+			}
+
+			code += fmt.Sprintf("(%s)", strings.Join(columns, ", "))
+		}
+
+		query := cte.slct.generateCode()
+		if cte.recursive != nil {
+			union := " UNION "
+			if cte.all {
+				union = " UNION ALL "
+			}
+
+			query += union + cte.recursive.generateCode()
+		}
+
+		ctes = append(ctes, fmt.Sprintf("%s AS (%s)", code, query))
+	}
+
+	code := "WITH "
+	if w.recursive {
+		code += "RECURSIVE "
+	}
+
+	return code + strings.Join(ctes, ", ")
 }
 
 type selectItem struct {
@@ -34,35 +336,199 @@ type selectItem struct {
 	as       *Token
 }
 
+// fromItem is a table, or a subquery like `(SELECT ...) AS alias`,
+// in the FROM clause. A table named like a common table expression in
+// scope is that expression instead, which cte is bound to when the
+// query runs.
 type fromItem struct {
-	table *Token
+	table    *Token
+	subquery *SelectStatement
+	as       *Token
+	cte      *boundCTE
+}
+
+// qualifier is the name the item's columns are qualified with.
+func (f *fromItem) qualifier() string {
+	if f.as != nil {
+		return f.as.Value
+	}
+
+	return f.table.Value
+}
+
+func (f *fromItem) generateCode() string {
+	code := ""
+	if f.subquery != nil {
+		code = fmt.Sprintf("(%s)", f.subquery.generateCode())
+	} else {
+		code = f.table.Value
+	}
+
+	if f.as != nil {
+		code += " AS " + f.as.Value
+	}
+
+	return code
+}
+
+// joinItem is an inner join of another table onto the FROM clause.
+// on is nil for a comma-separated cross join.
+type joinItem struct {
+	from *fromItem
+	on   *expression
 }
 
 type columnDefinition struct {
 	name     Token
 	datatype Token
+	// modifiers are the numbers in parentheses after the type, like
+	// the precision and scale of DECIMAL(10, 2)
+	modifiers []Token
+	// defaultValue is inserted when no value is given, nil for NULL
+	defaultValue *expression
+	// identity is set for a GENERATED ... AS IDENTITY column, and
+	// always for GENERATED ALWAYS, which can't be given a value
+	identity bool
+	always   bool
+}
+
+// tableConstraint is a PRIMARY KEY or UNIQUE constraint on one or more
+// columns.
+type tableConstraint struct {
+	primaryKey bool
+	columns    []Token
 }
 
 type Statement struct {
 	SelectStatement      *SelectStatement
 	CreateTableStatement *CreateTableStatement
 	InsertStatement      *InsertStatement
-	Kind                 AstKind
+	CreateIndexStatement *CreateIndexStatement
+	ExplainStatement     *ExplainStatement
+	AnalyzeStatement     *AnalyzeStatement
+	// CreateSequenceStatement is set for CreateSequenceKind
+	CreateSequenceStatement *CreateSequenceStatement
+	Kind                    AstKind
 }
 
 type InsertStatement struct {
-	table  Token
-	values *[]*expression
+	table Token
+	// columns are the columns given values, nil for every column in
+	// order
+	columns *[]Token
+	// values holds a tuple of values per row, nil if the rows come from
+	// query
+	values []*[]*expression
+	query  *SelectStatement
+	// onConflict is nil if rows conflicting with others on a unique
+	// constraint are an error
+	onConflict *onConflict
+	// returning lists the values computed from each inserted row, nil
+	// without a RETURNING clause
+	returning *[]*selectItem
+}
+
+// onConflict is what an INSERT does with a row conflicting with
+// another on a PRIMARY KEY or UNIQUE constraint: nothing, or update the
+// other row.
+type onConflict struct {
+	// columns are the columns of the constraint, nil for any
+	// constraint
+	columns *[]Token
+	// set assigns the columns of the conflicting row, nil to do nothing
+	set []*assignment
+}
+
+// assignment is a `column = value` of an update.
+type assignment struct {
+	column Token
+	value  expression
 }
 
 type CreateTableStatement struct {
 	name Token
 	cols *[]*columnDefinition
+	// constraints are the PRIMARY KEY and UNIQUE constraints, of a
+	// column or of the table
+	constraints []*tableConstraint
+	// using names the storage layout, nil for the default
+	using *Token
+}
+
+type CreateSequenceStatement struct {
+	name Token
+	// start and increment are nil for their defaults, both 1
+	start     *Token
+	increment *Token
+}
+
+type CreateIndexStatement struct {
+	name  Token
+	table Token
+	exp   expression
+}
+
+type ExplainStatement struct {
+	analyze bool
+	slct    *SelectStatement
+}
+
+type AnalyzeStatement struct {
+	table *Token
 }
 
+// SelectStatement is a query. It is either a SELECT, or when set is
+// non-nil, a set operation on two queries, and nothing else but with.
+//
+// distinct removes duplicate rows from the result, or with distinctOn
+// all but the first of the rows with the same values of distinctOn.
 type SelectStatement struct {
-	item *[]*selectItem
-	from *fromItem
+	with       *withClause
+	set        *setOperation
+	distinct   bool
+	distinctOn []*expression
+	item       *[]*selectItem
+	from       *fromItem
+	joins      []*joinItem
+	where      *expression
+	groupBy    []*expression
+}
+
+// setOperation combines the rows of two queries: UNION keeps those of
+// either, INTERSECT those of both and EXCEPT those of the left but not
+// the right. Duplicate rows are removed unless all is set.
+type setOperation struct {
+	op    Token
+	all   bool
+	left  *SelectStatement
+	right *SelectStatement
+}
+
+func (o *setOperation) generateCode() string {
+	operand := func(s *SelectStatement) string {
+		if s.set != nil || s.with != nil {
+			return fmt.Sprintf("(%s)", s.generateCode())
+		}
+
+		return s.generateCode()
+	}
+
+	op := strings.ToUpper(o.op.Value)
+	if o.all {
+		op += " ALL"
+	}
+
+	return fmt.Sprintf("%s %s %s", operand(o.left), op, operand(o.right))
+}
+
+// first returns the first SELECT of a query, the one a set operation
+// takes the names of its columns from.
+func (s *SelectStatement) first() *SelectStatement {
+	for s.set != nil {
+		s = s.set.left
+	}
+
+	return s
 }
 
 func tokenFromKeyword(k Keyword) Token {
@@ -143,6 +609,24 @@ func parseStatement(tokens []*Token, initialCursor uint, delimiter Token) (*Stat
 		}, newCursor, true
 	}
 
+	// Look for an EXPLAIN statement
+	explain, newCursor, ok := parseExplainStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:             ExplainKind,
+			ExplainStatement: explain,
+		}, newCursor, true
+	}
+
+	// Look for an ANALYZE statement
+	analyze, newCursor, ok := parseAnalyzeStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:             AnalyzeKind,
+			AnalyzeStatement: analyze,
+		}, newCursor, true
+	}
+
 	// Look for a INSERT statement
 	inst, newCursor, ok := parseInsertStatement(tokens, cursor, delimiter)
 	if ok {
@@ -161,6 +645,24 @@ func parseStatement(tokens []*Token, initialCursor uint, delimiter Token) (*Stat
 		}, newCursor, true
 	}
 
+	// Look for a CREATE SEQUENCE statement
+	crtSeq, newCursor, ok := parseCreateSequenceStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:                    CreateSequenceKind,
+			CreateSequenceStatement: crtSeq,
+		}, newCursor, true
+	}
+
+	// Look for a CREATE INDEX statement
+	crtIdx, newCursor, ok := parseCreateIndexStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:                 CreateIndexKind,
+			CreateIndexStatement: crtIdx,
+		}, newCursor, true
+	}
+
 	return nil, initialCursor, false
 }
 
@@ -193,7 +695,7 @@ outer:
 		}
 
 		// Look for expression
-		exp, newCursor, ok := parseExpression(tokens, cursor, tokenFromSymbol(CommaSymbol))
+		exp, newCursor, ok := parseExpression(tokens, cursor, 0)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected expression")
 			return nil, initialCursor, false
@@ -206,199 +708,1563 @@ outer:
 	return &exps, cursor, true
 }
 
-func parseExpression(tokens []*Token, initialCursor uint, _ Token) (*expression, uint, bool) {
-	cursor := initialCursor
-
-	kinds := []TokenKind{IdentifierKind, NumericKind, StringKind}
-	for _, kind := range kinds {
-		t, newCursor, ok := parseToken(tokens, cursor, kind)
-		if ok {
-			return &expression{
-				literal: t,
-				kind:    literalKind,
-			}, newCursor, true
+// bindingPower returns how tightly a binary operator binds its
+// operands, zero if the token is not a binary operator.
+func (t Token) bindingPower() uint {
+	switch t.Kind {
+	case KeywordKind:
+		switch Keyword(t.Value) {
+		case OrKeyword:
+			return 1
+		case AndKeyword:
+			return 2
+		case InKeyword, NotKeyword, BetweenKeyword, LikeKeyword, IlikeKeyword:
+			// x [NOT] IN (...), BETWEEN and LIKE bind like comparisons
+			return 3
+		}
+	case SymbolKind:
+		switch Symbol(t.Value) {
+		case EqSymbol, NeqSymbol, BangEqSymbol, LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+			return 3
+		case LikeSymbol, NotLikeSymbol, IlikeSymbol, NotIlikeSymbol, RegexSymbol, NotRegexSymbol, IregexSymbol, NotIregexSymbol:
+			return 3
+		case PlusSymbol, MinusSymbol, ConcatSymbol:
+			return 4
+		case AsteriskSymbol, SlashSymbol, PercentSymbol:
+			return 5
+		case ArrowSymbol, ArrowTextSymbol:
+			return 6
+		case CastSymbol:
+			return 7
 		}
 	}
 
-	return nil, initialCursor, false
+	return 0
 }
 
-func parseSelectItem(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*selectItem, uint, bool) {
+// parseExpression parses a literal, a parenthesized expression or a
+// chain of binary operations. Only operators binding at least as
+// tightly as minBp are consumed so that callers can implement operator
+// precedence.
+func parseExpression(tokens []*Token, initialCursor uint, minBp uint) (*expression, uint, bool) {
 	cursor := initialCursor
 
-	s := []*selectItem{}
-outer:
-	for {
-		if cursor >= uint(len(tokens)) {
+	var exp *expression
+	if subquery, newCursor, ok := parseSubqueryExpression(tokens, cursor); ok {
+		cursor = newCursor
+		exp = subquery
+	} else if expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		cursor++
+
+		inner, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression after opening paren")
 			return nil, initialCursor, false
 		}
+		cursor = newCursor
 
-		current := tokens[cursor]
-		for _, delimiter := range delimiters {
-			if delimiter.equals(current) {
-				break outer
-			}
+		if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+			helpMessage(tokens, cursor, "Expected closing paren")
+			return nil, initialCursor, false
 		}
+		cursor++
 
-		if len(s) > 0 {
-			if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
-				helpMessage(tokens, cursor, "Expected comma")
+		exp = inner
+	} else if cast, newCursor, ok := parseCastExpression(tokens, cursor); ok {
+		cursor = newCursor
+		exp = cast
+	} else if caseExp, newCursor, ok := parseCaseExpression(tokens, cursor); ok {
+		cursor = newCursor
+		exp = caseExp
+	} else if function, newCursor, ok := parseFunctionExpression(tokens, cursor); ok {
+		cursor = newCursor
+		exp = function
+	} else if unary, newCursor, ok := parseUnaryExpression(tokens, cursor); ok {
+		cursor = newCursor
+		exp = unary
+	} else if not, newCursor, ok := parseNotExpression(tokens, cursor); ok {
+		cursor = newCursor
+		exp = not
+	} else {
+		literal, newCursor, ok := parseLiteralExpression(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		exp = literal
+	}
+
+	for cursor < uint(len(tokens)) {
+		op := tokens[cursor]
+		bp := op.bindingPower()
+		if bp == 0 || bp < minBp {
+			break
+		}
+		cursor++
+
+		// x::type is CAST(x AS type)
+		if Symbol(op.Value) == CastSymbol {
+			typ, newCursor, ok := parseTypeName(tokens, cursor)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected type")
 				return nil, initialCursor, false
 			}
+			cursor = newCursor
 
-			cursor++
+			exp = &expression{
+				function: &functionExpression{
+					name: Token{Value: string(CastKeyword), Kind: KeywordKind, Loc: op.Loc},
+					args: []expression{*exp},
+					cast: typ,
+				},
+				kind: functionKind,
+			}
+			continue
 		}
 
-		var si selectItem
-		if expectToken(tokens, cursor, tokenFromSymbol(AsteriskSymbol)) {
-			si = selectItem{asterisk: true}
-			cursor++
-		} else {
-			exp, newCursor, ok := parseExpression(tokens, cursor, tokenFromSymbol(CommaSymbol))
+		// x [NOT] IN (...), x [NOT] BETWEEN a AND b and x [NOT] LIKE y
+		if op.Kind == KeywordKind && op.bindingPower() == 3 {
+			predicate, newCursor, ok := parsePredicate(tokens, cursor-1, *exp)
 			if !ok {
-				helpMessage(tokens, cursor, "Expected expression")
 				return nil, initialCursor, false
 			}
-
 			cursor = newCursor
-			si.exp = exp
-
-			if expectToken(tokens, cursor, tokenFromKeyword(AsKeyword)) {
-				cursor++
 
-				id, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
-				if !ok {
-					helpMessage(tokens, cursor, "Expected identifier after AS")
-					return nil, initialCursor, false
-				}
+			exp = predicate
+			continue
+		}
 
-				cursor = newCursor
-				si.as = id
-			}
+		// Binding one higher on the right keeps operators left-associative
+		b, newCursor, ok := parseExpression(tokens, cursor, bp+1)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected right operand")
+			return nil, initialCursor, false
 		}
+		cursor = newCursor
 
-		s = append(s, &si)
+		exp = &expression{
+			binary: &binaryExpression{
+				a:  *exp,
+				b:  *b,
+				op: *op,
+			},
+			kind: binaryKind,
+		}
 	}
 
-	return &s, cursor, true
+	return exp, cursor, true
 }
 
-func parseFromItem(tokens []*Token, initialCursor uint, _ Token) (*fromItem, uint, bool) {
-	ident, newCursor, ok := parseToken(tokens, initialCursor, IdentifierKind)
-	if !ok {
+// parsePredicate parses the rest of x [NOT] IN (SELECT ...),
+// x [NOT] IN (a, b, ...), x [NOT] BETWEEN low AND high and
+// x [NOT] LIKE or ILIKE pattern from the keyword after x. All but the
+// first are rewritten into the comparisons and operators they stand
+// for.
+func parsePredicate(tokens []*Token, initialCursor uint, x expression) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	not := expectToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+	if not {
+		cursor++
+	}
+
+	if cursor >= uint(len(tokens)) || tokens[cursor].Kind != KeywordKind {
+		helpMessage(tokens, cursor, "Expected IN, BETWEEN, LIKE or ILIKE")
 		return nil, initialCursor, false
 	}
+	keyword := tokens[cursor]
+	cursor++
 
-	return &fromItem{table: ident}, newCursor, true
+	operation := func(a, b expression, op Token) expression {
+		op.Loc = keyword.Loc
+		return expression{
+			binary: &binaryExpression{a: a, b: b, op: op},
+			kind:   binaryKind,
+		}
+	}
+
+	switch Keyword(keyword.Value) {
+	case InKeyword:
+		if slct, newCursor, ok := parseSubquery(tokens, cursor); ok {
+			return &expression{
+				subquery: &subqueryExpression{slct: slct, in: &x, not: not},
+				kind:     subqueryKind,
+			}, newCursor, true
+		}
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) || expectToken(tokens, cursor+1, tokenFromSymbol(RightParenSymbol)) {
+			helpMessage(tokens, cursor, "Expected subquery or list of values")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		values, newCursor, ok := parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor + 1
+
+		// x IN (a, b) is x = a OR x = b, and x NOT IN (a, b) is
+		// x <> a AND x <> b
+		cmp, join := tokenFromSymbol(EqSymbol), tokenFromKeyword(OrKeyword)
+		if not {
+			cmp, join = tokenFromSymbol(NeqSymbol), tokenFromKeyword(AndKeyword)
+		}
+
+		var exp expression
+		for i, value := range *values {
+			term := operation(x, *value, cmp)
+			if i == 0 {
+				exp = term
+			} else {
+				exp = operation(exp, term, join)
+			}
+		}
+
+		return &exp, cursor, true
+	case BetweenKeyword:
+		// The bounds bind tighter than AND, so the AND between them
+		// isn't taken for a conjunction
+		low, newCursor, ok := parseExpression(tokens, cursor, 4)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected lower bound")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromKeyword(AndKeyword)) {
+			helpMessage(tokens, cursor, "Expected AND")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		high, newCursor, ok := parseExpression(tokens, cursor, 4)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected upper bound")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		// x BETWEEN a AND b is x >= a AND x <= b, and x NOT BETWEEN
+		// a AND b is x < a OR x > b
+		if not {
+			exp := operation(operation(x, *low, tokenFromSymbol(LtSymbol)), operation(x, *high, tokenFromSymbol(GtSymbol)), tokenFromKeyword(OrKeyword))
+			return &exp, cursor, true
+		}
+
+		exp := operation(operation(x, *low, tokenFromSymbol(GteSymbol)), operation(x, *high, tokenFromSymbol(LteSymbol)), tokenFromKeyword(AndKeyword))
+		return &exp, cursor, true
+	case LikeKeyword, IlikeKeyword:
+		pattern, newCursor, ok := parseExpression(tokens, cursor, 4)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected pattern")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		op := LikeSymbol
+		if Keyword(keyword.Value) == IlikeKeyword {
+			op = IlikeSymbol
+		}
+
+		// NOT LIKE is !~~ and NOT ILIKE is !~~*
+		if not {
+			op = "!" + op
+		}
+
+		exp := operation(x, *pattern, tokenFromSymbol(op))
+		return &exp, cursor, true
+	}
+
+	helpMessage(tokens, cursor-1, "Expected IN, BETWEEN, LIKE or ILIKE")
+	return nil, initialCursor, false
+}
+
+// parseSubquery parses a parenthesized SELECT.
+func parseSubquery(tokens []*Token, initialCursor uint) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		return nil, initialCursor, false
+	}
+
+	if !expectToken(tokens, cursor+1, tokenFromKeyword(SelectKeyword)) && !expectToken(tokens, cursor+1, tokenFromKeyword(WithKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	slct, newCursor, ok := parseSelectStatement(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return slct, cursor, true
+}
+
+// parseSubqueryExpression parses a scalar subquery or [NOT] EXISTS
+// followed by a subquery.
+func parseSubqueryExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	subquery := subqueryExpression{}
+	if expectToken(tokens, cursor, tokenFromKeyword(NotKeyword)) && expectToken(tokens, cursor+1, tokenFromKeyword(ExistsKeyword)) {
+		subquery.not = true
+		cursor++
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(ExistsKeyword)) {
+		subquery.exists = true
+		cursor++
+	}
+
+	slct, newCursor, ok := parseSubquery(tokens, cursor)
+	if !ok {
+		if subquery.exists {
+			helpMessage(tokens, cursor, "Expected subquery after EXISTS")
+		}
+
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	subquery.slct = slct
+	return &expression{
+		subquery: &subquery,
+		kind:     subqueryKind,
+	}, cursor, true
+}
+
+// parseCastExpression parses CAST(x AS type).
+func parseCastExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(CastKeyword)) || !expectToken(tokens, cursor+1, tokenFromSymbol(LeftParenSymbol)) {
+		return nil, initialCursor, false
+	}
+	name := tokens[cursor]
+	cursor += 2
+
+	arg, newCursor, ok := parseExpression(tokens, cursor, 0)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected expression to cast")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(AsKeyword)) {
+		helpMessage(tokens, cursor, "Expected AS")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	typ, newCursor, ok := parseTypeName(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected type")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &expression{
+		function: &functionExpression{
+			name: *name,
+			args: []expression{*arg},
+			cast: typ,
+		},
+		kind: functionKind,
+	}, cursor, true
+}
+
+// parseCaseExpression parses CASE WHEN condition THEN result ...
+// [ELSE result] END, and the simple form CASE x WHEN value THEN result
+// ..., whose conditions are x = value.
+func parseCaseExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(CaseKeyword)) {
+		return nil, initialCursor, false
+	}
+	function := functionExpression{name: *tokens[cursor]}
+	cursor++
+
+	var operand *expression
+	if !expectToken(tokens, cursor, tokenFromKeyword(WhenKeyword)) {
+		exp, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression or WHEN")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		operand = exp
+	}
+
+	for expectToken(tokens, cursor, tokenFromKeyword(WhenKeyword)) {
+		when := tokens[cursor]
+		cursor++
+
+		condition, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected condition")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if operand != nil {
+			eq := tokenFromSymbol(EqSymbol)
+			eq.Loc = when.Loc
+			condition = &expression{
+				binary: &binaryExpression{a: *operand, b: *condition, op: eq},
+				kind:   binaryKind,
+			}
+		}
+
+		if !expectToken(tokens, cursor, tokenFromKeyword(ThenKeyword)) {
+			helpMessage(tokens, cursor, "Expected THEN")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		result, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected result")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		function.args = append(function.args, *condition, *result)
+	}
+
+	if len(function.args) == 0 {
+		helpMessage(tokens, cursor, "Expected WHEN")
+		return nil, initialCursor, false
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(ElseKeyword)) {
+		cursor++
+
+		result, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected result")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		function.args = append(function.args, *result)
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(EndKeyword)) {
+		helpMessage(tokens, cursor, "Expected END")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &expression{
+		function: &function,
+		kind:     functionKind,
+	}, cursor, true
+}
+
+// parseFunctionExpression parses a function call: a name followed by
+// a parenthesized list of arguments, or by (*).
+func parseFunctionExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	name, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+	if !ok || !expectToken(tokens, newCursor, tokenFromSymbol(LeftParenSymbol)) {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor + 1
+
+	function := functionExpression{name: *name}
+
+	// EXTRACT(field FROM source) is extract('field', source)
+	if field, newCursor, ok := parseToken(tokens, cursor, IdentifierKind); ok && name.Value == "extract" && expectToken(tokens, newCursor, tokenFromKeyword(FromKeyword)) {
+		cursor = newCursor + 1
+
+		source, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression to extract from")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		field := *field
+		field.Kind = StringKind
+		function.args = []expression{{literal: &field, kind: literalKind}, *source}
+	} else if expectToken(tokens, cursor, tokenFromSymbol(AsteriskSymbol)) {
+		function.asterisk = true
+		cursor++
+	} else if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		args, newCursor, ok := parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		for _, arg := range *args {
+			function.args = append(function.args, *arg)
+		}
+	}
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &expression{
+		function: &function,
+		kind:     functionKind,
+	}, cursor, true
+}
+
+// parseUnaryExpression parses a prefix minus or plus and its operand,
+// which takes in only the operators binding tighter than * and /. A
+// minus in front of a number is part of it, so that -32768::SMALLINT is
+// the smallest SMALLINT rather than 32768 negated.
+func parseUnaryExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(MinusSymbol)) && !expectToken(tokens, cursor, tokenFromSymbol(PlusSymbol)) {
+		return nil, initialCursor, false
+	}
+	sign := tokens[cursor]
+
+	if number, newCursor, ok := parseSignedInteger(tokens, cursor); ok && Symbol(sign.Value) == MinusSymbol {
+		return &expression{literal: number, kind: literalKind}, newCursor, true
+	}
+	cursor++
+
+	operand, newCursor, ok := parseExpression(tokens, cursor, 6)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected operand")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	return &expression{
+		function: &functionExpression{
+			name: *sign,
+			args: []expression{*operand},
+		},
+		kind: functionKind,
+	}, cursor, true
+}
+
+// parseNotExpression parses a prefix NOT and its operand, which takes
+// in comparisons but not AND or OR, so that NOT a = b AND c is
+// (NOT (a = b)) AND c. NOT x is rewritten into x = FALSE, which is
+// unknown when x is, as NOT x is.
+func parseNotExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(NotKeyword)) {
+		return nil, initialCursor, false
+	}
+	not := tokens[cursor]
+	cursor++
+
+	operand, newCursor, ok := parseExpression(tokens, cursor, 3)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected operand")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	op := tokenFromSymbol(EqSymbol)
+	op.Loc = not.Loc
+	return &expression{
+		binary: &binaryExpression{
+			a: *operand,
+			b: expression{
+				literal: &Token{Value: string(FalseKeyword), Kind: KeywordKind, Loc: not.Loc},
+				kind:    literalKind,
+			},
+			op: op,
+		},
+		kind: binaryKind,
+	}, cursor, true
+}
+
+func parseLiteralExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	// Look for a qualified column like `users.id`
+	if table, newCursor, ok := parseToken(tokens, cursor, IdentifierKind); ok && expectToken(tokens, newCursor, tokenFromSymbol(DotSymbol)) {
+		column, newCursor, ok := parseToken(tokens, newCursor+1, IdentifierKind)
+		if !ok {
+			helpMessage(tokens, newCursor+1, "Expected column name")
+			return nil, initialCursor, false
+		}
+
+		return &expression{
+			literal: column,
+			kind:    literalKind,
+			table:   table,
+		}, newCursor, true
+	}
+
+	kinds := []TokenKind{IdentifierKind, NumericKind, StringKind, HexKind}
+	for _, kind := range kinds {
+		t, newCursor, ok := parseToken(tokens, cursor, kind)
+		if ok {
+			return &expression{
+				literal: t,
+				kind:    literalKind,
+			}, newCursor, true
+		}
+	}
+
+	// Strings read as a date or time, like DATE '2024-01-01'
+	for _, keyword := range []Keyword{DateKeyword, TimeKeyword, TimestampKeyword, IntervalKeyword} {
+		if expectToken(tokens, cursor, tokenFromKeyword(keyword)) {
+			if t, newCursor, ok := parseToken(tokens, cursor+1, StringKind); ok {
+				return &expression{
+					literal: t,
+					kind:    literalKind,
+					typ:     tokens[cursor],
+				}, newCursor, true
+			}
+		}
+	}
+
+	// TRUE and FALSE are the only keywords that are values, besides
+	// DEFAULT standing for a column's default in VALUES
+	for _, keyword := range []Keyword{TrueKeyword, FalseKeyword, DefaultKeyword} {
+		if expectToken(tokens, cursor, tokenFromKeyword(keyword)) {
+			return &expression{
+				literal: tokens[cursor],
+				kind:    literalKind,
+			}, cursor + 1, true
+		}
+	}
+
+	return nil, initialCursor, false
+}
+
+func parseSelectItem(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*selectItem, uint, bool) {
+	cursor := initialCursor
+
+	s := []*selectItem{}
+outer:
+	for {
+		if cursor >= uint(len(tokens)) {
+			return nil, initialCursor, false
+		}
+
+		current := tokens[cursor]
+		for _, delimiter := range delimiters {
+			if delimiter.equals(current) {
+				break outer
+			}
+		}
+
+		if len(s) > 0 {
+			if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+				helpMessage(tokens, cursor, "Expected comma")
+				return nil, initialCursor, false
+			}
+
+			cursor++
+		}
+
+		var si selectItem
+		if expectToken(tokens, cursor, tokenFromSymbol(AsteriskSymbol)) {
+			si = selectItem{asterisk: true}
+			cursor++
+		} else {
+			exp, newCursor, ok := parseExpression(tokens, cursor, 0)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected expression")
+				return nil, initialCursor, false
+			}
+
+			cursor = newCursor
+			si.exp = exp
+
+			if expectToken(tokens, cursor, tokenFromKeyword(AsKeyword)) {
+				cursor++
+
+				id, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+				if !ok {
+					helpMessage(tokens, cursor, "Expected identifier after AS")
+					return nil, initialCursor, false
+				}
+
+				cursor = newCursor
+				si.as = id
+			}
+		}
+
+		s = append(s, &si)
+	}
+
+	return &s, cursor, true
+}
+
+func parseFromItem(tokens []*Token, initialCursor uint, _ Token) (*fromItem, uint, bool) {
+	cursor := initialCursor
+
+	item := fromItem{}
+	if slct, newCursor, ok := parseSubquery(tokens, cursor); ok {
+		item.subquery = slct
+		cursor = newCursor
+	} else {
+		ident, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		item.table = ident
+		cursor = newCursor
+	}
+
+	// Look for an alias, with or without AS
+	if expectToken(tokens, cursor, tokenFromKeyword(AsKeyword)) {
+		cursor++
+
+		as, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected identifier after AS")
+			return nil, initialCursor, false
+		}
+
+		item.as = as
+		cursor = newCursor
+	} else if as, newCursor, ok := parseToken(tokens, cursor, IdentifierKind); ok {
+		item.as = as
+		cursor = newCursor
+	}
+
+	// A subquery is only known by its alias
+	if item.subquery != nil && item.as == nil {
+		helpMessage(tokens, cursor, "Expected alias for subquery")
+		return nil, initialCursor, false
+	}
+
+	return &item, cursor, true
+}
+
+func parseJoinItems(tokens []*Token, initialCursor uint, delimiter Token) ([]*joinItem, uint, bool) {
+	cursor := initialCursor
+
+	var joins []*joinItem
+	for {
+		// Look for a cross join
+		if expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+			cursor++
+
+			from, newCursor, ok := parseFromItem(tokens, cursor, delimiter)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected table name")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			joins = append(joins, &joinItem{from: from})
+			continue
+		}
+
+		// Look for [INNER] JOIN ... ON
+		if expectToken(tokens, cursor, tokenFromKeyword(InnerKeyword)) {
+			cursor++
+
+			if !expectToken(tokens, cursor, tokenFromKeyword(JoinKeyword)) {
+				helpMessage(tokens, cursor, "Expected JOIN")
+				return nil, initialCursor, false
+			}
+		}
+
+		if !expectToken(tokens, cursor, tokenFromKeyword(JoinKeyword)) {
+			break
+		}
+		cursor++
+
+		from, newCursor, ok := parseFromItem(tokens, cursor, delimiter)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected table name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+			helpMessage(tokens, cursor, "Expected ON")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		on, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected join condition")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		joins = append(joins, &joinItem{from: from, on: on})
+	}
+
+	return joins, cursor, true
+}
+
+// parseSelectStatement parses a query: SELECTs combined by set
+// operators, optionally preceded by a WITH clause.
+func parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+
+	var with *withClause
+	if w, newCursor, ok := parseWithClause(tokens, cursor); ok {
+		with = w
+		cursor = newCursor
+	} else if expectToken(tokens, cursor, tokenFromKeyword(WithKeyword)) {
+		return nil, initialCursor, false
+	}
+
+	slct, newCursor, ok := parseSetOperation(tokens, cursor, delimiter, 0)
+	if !ok {
+		if with != nil {
+			helpMessage(tokens, cursor, "Expected SELECT")
+		}
+
+		return nil, initialCursor, false
+	}
+
+	if with != nil {
+		if slct.with != nil {
+			helpMessage(tokens, cursor, "Expected a single WITH clause")
+			return nil, initialCursor, false
+		}
+
+		slct.with = with
+	}
+
+	return slct, newCursor, true
+}
+
+// setOperationPower returns how tightly a set operator binds, 0 if t
+// isn't one. INTERSECT binds more tightly than UNION and EXCEPT.
+func setOperationPower(t *Token) uint {
+	if t.Kind != KeywordKind {
+		return 0
+	}
+
+	switch Keyword(t.Value) {
+	case UnionKeyword, ExceptKeyword:
+		return 1
+	case IntersectKeyword:
+		return 2
+	}
+
+	return 0
+}
+
+// parseSetOperation parses a SELECT, or a parenthesized query, and the
+// set operations on it binding at least as tightly as minBp.
+func parseSetOperation(tokens []*Token, initialCursor uint, delimiter Token, minBp uint) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+
+	slct, newCursor, ok := parseSubquery(tokens, cursor)
+	if !ok {
+		slct, newCursor, ok = parseSimpleSelect(tokens, cursor, delimiter)
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+	cursor = newCursor
+
+	for cursor < uint(len(tokens)) {
+		op := tokens[cursor]
+		bp := setOperationPower(op)
+		if bp == 0 || bp < minBp {
+			break
+		}
+		cursor++
+
+		set := setOperation{op: *op, left: slct}
+		if expectToken(tokens, cursor, tokenFromKeyword(AllKeyword)) {
+			set.all = true
+			cursor++
+		}
+
+		// Binding one higher on the right keeps operators left-associative
+		right, newCursor, ok := parseSetOperation(tokens, cursor, delimiter, bp+1)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected SELECT")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		set.right = right
+		slct = &SelectStatement{set: &set}
+	}
+
+	return slct, cursor, true
+}
+
+func parseSimpleSelect(tokens []*Token, initialCursor uint, delimiter Token) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromKeyword(SelectKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	slct := SelectStatement{}
+	if expectToken(tokens, cursor, tokenFromKeyword(DistinctKeyword)) {
+		slct.distinct = true
+		cursor++
+
+		if expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+			cursor++
+
+			on, newCursor, ok := parseDistinctOn(tokens, cursor)
+			if !ok {
+				return nil, initialCursor, false
+			}
+
+			slct.distinctOn = on
+			cursor = newCursor
+		}
+	} else if expectToken(tokens, cursor, tokenFromKeyword(AllKeyword)) {
+		cursor++
+	}
+
+	// A SELECT in an INSERT also ends where the INSERT's ON CONFLICT or
+	// RETURNING clause starts, and one in a set operation where its
+	// operator does
+	ends := []Token{
+		delimiter,
+		tokenFromKeyword(OnKeyword),
+		tokenFromKeyword(ReturningKeyword),
+		tokenFromKeyword(UnionKeyword),
+		tokenFromKeyword(IntersectKeyword),
+		tokenFromKeyword(ExceptKeyword),
+	}
+
+	exps, newCursor, ok := parseSelectItem(tokens, cursor, append([]Token{tokenFromKeyword(FromKeyword), tokenFromKeyword(WhereKeyword), tokenFromKeyword(GroupKeyword)}, ends...))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	slct.item = exps
+	cursor = newCursor
+
+	if expectToken(tokens, cursor, tokenFromKeyword(FromKeyword)) {
+		cursor++
+
+		from, newCursor, ok := parseFromItem(tokens, cursor, delimiter)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected FROM token")
+			return nil, initialCursor, false
+		}
+
+		slct.from = from
+		cursor = newCursor
+
+		joins, newCursor, ok := parseJoinItems(tokens, cursor, delimiter)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		slct.joins = joins
+		cursor = newCursor
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(WhereKeyword)) {
+		cursor++
+
+		where, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected WHERE conditionals")
+			return nil, initialCursor, false
+		}
+
+		slct.where = where
+		cursor = newCursor
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(GroupKeyword)) {
+		cursor++
+
+		if !expectToken(tokens, cursor, tokenFromKeyword(ByKeyword)) {
+			helpMessage(tokens, cursor, "Expected BY")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		groupBy, newCursor, ok := parseExpressions(tokens, cursor, ends)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected GROUP BY expressions")
+			return nil, initialCursor, false
+		}
+
+		slct.groupBy = *groupBy
+		cursor = newCursor
+	}
+
+	return &slct, cursor, true
+}
+
+// parseDistinctOn parses the parenthesized expressions following
+// DISTINCT ON.
+func parseDistinctOn(tokens []*Token, initialCursor uint) ([]*expression, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected left paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	exps, newCursor, ok := parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
+	if !ok || len(*exps) == 0 {
+		helpMessage(tokens, cursor, "Expected DISTINCT ON expressions")
+		return nil, initialCursor, false
+	}
+	// Past the right paren the expressions stopped at
+	cursor = newCursor + 1
+
+	return *exps, cursor, true
+}
+
+// parseWithClause parses WITH [RECURSIVE] followed by common table
+// expressions separated by commas.
+func parseWithClause(tokens []*Token, initialCursor uint) (*withClause, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(WithKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	with := withClause{}
+	if expectToken(tokens, cursor, tokenFromKeyword(RecursiveKeyword)) {
+		with.recursive = true
+		cursor++
+	}
+
+	for {
+		cte, newCursor, ok := parseCommonTableExpression(tokens, cursor, with.recursive)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		with.ctes = append(with.ctes, cte)
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+			break
+		}
+		cursor++
+	}
+
+	return &with, cursor, true
+}
+
+// parseCommonTableExpression parses `name [(columns)] AS (query)`.
+func parseCommonTableExpression(tokens []*Token, initialCursor uint, recursive bool) (*commonTableExpression, uint, bool) {
+	cursor := initialCursor
+
+	name, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected common table expression name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	cte := commonTableExpression{name: *name}
+	if columns, newCursor, ok := parseColumnList(tokens, cursor); ok {
+		cte.columns = columns
+		cursor = newCursor
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(AsKeyword)) {
+		helpMessage(tokens, cursor, "Expected AS")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected left paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	slct, newCursor, ok := parseSelectStatement(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		helpMessage(tokens, cursor, "Expected SELECT statement")
+		return nil, initialCursor, false
+	}
+	cte.slct = slct
+	cursor = newCursor
+
+	// The last UNION of a recursive expression's query separates its
+	// recursive term from the rest
+	union := tokenFromKeyword(UnionKeyword)
+	if recursive && slct.with == nil && slct.set != nil && slct.set.op.equals(&union) {
+		cte.slct, cte.recursive, cte.all = slct.set.left, slct.set.right, slct.set.all
+	}
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &cte, cursor, true
+}
+
+func parseExplainStatement(tokens []*Token, initialCursor uint, delimiter Token) (*ExplainStatement, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromKeyword(ExplainKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	explain := ExplainStatement{}
+	if expectToken(tokens, cursor, tokenFromKeyword(AnalyzeKeyword)) {
+		explain.analyze = true
+		cursor++
+	}
+
+	slct, newCursor, ok := parseSelectStatement(tokens, cursor, delimiter)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected SELECT statement")
+		return nil, initialCursor, false
+	}
+
+	explain.slct = slct
+	return &explain, newCursor, true
+}
+
+func parseAnalyzeStatement(tokens []*Token, initialCursor uint, _ Token) (*AnalyzeStatement, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromKeyword(AnalyzeKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	analyze := AnalyzeStatement{}
+
+	// Without a table name every table is analyzed
+	if table, newCursor, ok := parseToken(tokens, cursor, IdentifierKind); ok {
+		analyze.table = table
+		cursor = newCursor
+	}
+
+	return &analyze, cursor, true
+}
+
+func parseToken(tokens []*Token, initialCursor uint, kind TokenKind) (*Token, uint, bool) {
+	cursor := initialCursor
+
+	if cursor >= uint(len(tokens)) {
+		return nil, initialCursor, false
+	}
+
+	current := tokens[cursor]
+	if current.Kind == kind {
+		return current, cursor + 1, true
+	}
+
+	return nil, initialCursor, false
+}
+
+func parseInsertStatement(tokens []*Token, initialCursor uint, delimiter Token) (*InsertStatement, uint, bool) {
+	cursor := initialCursor
+
+	// Look for INSERT
+	if !expectToken(tokens, cursor, tokenFromKeyword(InsertKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	// Look for INTO
+	if !expectToken(tokens, cursor, tokenFromKeyword(IntoKeyword)) {
+		helpMessage(tokens, cursor, "Expected into")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	// Look for table name
+	table, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	// Look for column list
+	var columns *[]Token
+	if expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		columns, newCursor, ok = parseColumnList(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+	}
+
+	inst := InsertStatement{table: *table, columns: columns}
+
+	// Look for SELECT or VALUES
+	if query, newCursor, ok := parseSelectStatement(tokens, cursor, delimiter); ok {
+		inst.query = query
+		cursor = newCursor
+	} else if expectToken(tokens, cursor, tokenFromKeyword(ValuesKeyword)) {
+		values, newCursor, ok := parseValues(tokens, cursor+1)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		inst.values = values
+		cursor = newCursor
+	} else {
+		helpMessage(tokens, cursor, "Expected VALUES or SELECT")
+		return nil, initialCursor, false
+	}
+
+	// Look for ON CONFLICT
+	if expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+		conflict, newCursor, ok := parseOnConflict(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		inst.onConflict = conflict
+		cursor = newCursor
+	}
+
+	// Look for RETURNING
+	if expectToken(tokens, cursor, tokenFromKeyword(ReturningKeyword)) {
+		cursor++
+
+		returning, newCursor, ok := parseSelectItem(tokens, cursor, []Token{delimiter})
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		inst.returning = returning
+		cursor = newCursor
+	}
+
+	return &inst, cursor, true
 }
 
-func parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token) (*SelectStatement, uint, bool) {
-	cursor := initialCursor
-	if !expectToken(tokens, cursor, tokenFromKeyword(SelectKeyword)) {
-		return nil, initialCursor, false
-	}
-	cursor++
+// parseOnConflict parses ON CONFLICT [(columns)] DO NOTHING, or
+// ON CONFLICT (columns) DO UPDATE SET column = value, ...
+func parseOnConflict(tokens []*Token, initialCursor uint) (*onConflict, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(ConflictKeyword)) {
+		helpMessage(tokens, cursor, "Expected CONFLICT")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	conflict := onConflict{}
+	if expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		columns, newCursor, ok := parseColumnList(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		conflict.columns = columns
+		cursor = newCursor
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(DoKeyword)) {
+		helpMessage(tokens, cursor, "Expected DO")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if expectToken(tokens, cursor, tokenFromKeyword(NothingKeyword)) {
+		cursor++
+		return &conflict, cursor, true
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(UpdateKeyword)) {
+		helpMessage(tokens, cursor, "Expected NOTHING or UPDATE")
+		return nil, initialCursor, false
+	}
+
+	// Which rows conflict has to be spelled out to update them
+	if conflict.columns == nil {
+		helpMessage(tokens, cursor, "Expected conflict columns before DO UPDATE")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(SetKeyword)) {
+		helpMessage(tokens, cursor, "Expected SET")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	for {
+		column, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(EqSymbol)) {
+			helpMessage(tokens, cursor, "Expected =")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		value, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected value")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
 
-	slct := SelectStatement{}
+		conflict.set = append(conflict.set, &assignment{column: *column, value: *value})
 
-	exps, newCursor, ok := parseSelectItem(tokens, cursor, []Token{tokenFromKeyword(FromKeyword), delimiter})
-	if !ok {
-		return nil, initialCursor, false
+		if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+			break
+		}
+		cursor++
 	}
 
-	slct.item = exps
-	cursor = newCursor
+	return &conflict, cursor, true
+}
 
-	if expectToken(tokens, cursor, tokenFromKeyword(FromKeyword)) {
+// parseValues parses the comma-separated tuples after VALUES.
+func parseValues(tokens []*Token, initialCursor uint) ([]*[]*expression, uint, bool) {
+	cursor := initialCursor
+
+	values := []*[]*expression{}
+	for {
+		// Look for left paren
+		if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+			helpMessage(tokens, cursor, "Expected left paren")
+			return nil, initialCursor, false
+		}
 		cursor++
 
-		from, newCursor, ok := parseFromItem(tokens, cursor, delimiter)
+		// Look for expression list
+		tuple, newCursor, ok := parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
 		if !ok {
-			helpMessage(tokens, cursor, "Expected FROM token")
 			return nil, initialCursor, false
 		}
-
-		slct.from = from
 		cursor = newCursor
+
+		// Look for right paren
+		if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+			helpMessage(tokens, cursor, "Expected right paren")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		values = append(values, tuple)
+
+		// Look for another tuple
+		if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+			break
+		}
+		cursor++
 	}
 
-	return &slct, cursor, true
+	return values, cursor, true
 }
 
-func parseToken(tokens []*Token, initialCursor uint, kind TokenKind) (*Token, uint, bool) {
+// parseColumnList parses a parenthesized list of column names, like
+// (id, name).
+func parseColumnList(tokens []*Token, initialCursor uint) (*[]Token, uint, bool) {
 	cursor := initialCursor
 
-	if cursor >= uint(len(tokens)) {
+	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
 		return nil, initialCursor, false
 	}
+	cursor++
 
-	current := tokens[cursor]
-	if current.Kind == kind {
-		return current, cursor + 1, true
+	columns := []Token{}
+	for {
+		column, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		columns = append(columns, *column)
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+			break
+		}
+		cursor++
 	}
 
-	return nil, initialCursor, false
+	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected right paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &columns, cursor, true
 }
 
-func parseInsertStatement(tokens []*Token, initialCursor uint, delimiter Token) (*InsertStatement, uint, bool) {
+func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter Token) (*CreateTableStatement, uint, bool) {
 	cursor := initialCursor
 
-	// Look for INSERT
-	if !expectToken(tokens, cursor, tokenFromKeyword(InsertKeyword)) {
+	if !expectToken(tokens, cursor, tokenFromKeyword(CreateKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	// Look for INTO
-	if !expectToken(tokens, cursor, tokenFromKeyword(IntoKeyword)) {
-		helpMessage(tokens, cursor, "Expected into")
+	if !expectToken(tokens, cursor, tokenFromKeyword(TableKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	// Look for table name
-	table, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+	name, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
 	if !ok {
 		helpMessage(tokens, cursor, "Expected table name")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	// Look for VALUES
-	if !expectToken(tokens, cursor, tokenFromKeyword(ValuesKeyword)) {
-		helpMessage(tokens, cursor, "Expected VALUES")
+	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected left parenthesis")
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	// Look for left paren
-	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
-		helpMessage(tokens, cursor, "Expected left paren")
+	cols, constraints, newCursor, ok := parseColumnDefinitions(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected right parenthesis")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	crt := CreateTableStatement{
+		name:        *name,
+		cols:        cols,
+		constraints: constraints,
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(UsingKeyword)) {
+		cursor++
+
+		using, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected storage layout after USING")
+			return nil, initialCursor, false
+		}
+
+		crt.using = using
+		cursor = newCursor
+	}
+
+	return &crt, cursor, true
+}
+
+func parseCreateSequenceStatement(tokens []*Token, initialCursor uint, _ Token) (*CreateSequenceStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(CreateKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(SequenceKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	// Look for expression list
-	values, newCursor, ok := parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
+	name, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
 	if !ok {
+		helpMessage(tokens, cursor, "Expected sequence name")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	// Look for right paren
-	if !expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
-		helpMessage(tokens, cursor, "Expected right paren")
+	crt := CreateSequenceStatement{name: *name}
+
+	// Look for START [WITH] and INCREMENT [BY], in any order
+	for {
+		var option **Token
+		switch {
+		case expectToken(tokens, cursor, tokenFromKeyword(StartKeyword)):
+			option = &crt.start
+			cursor++
+			if expectToken(tokens, cursor, tokenFromKeyword(WithKeyword)) {
+				cursor++
+			}
+		case expectToken(tokens, cursor, tokenFromKeyword(IncrementKeyword)):
+			option = &crt.increment
+			cursor++
+			if expectToken(tokens, cursor, tokenFromKeyword(ByKeyword)) {
+				cursor++
+			}
+		default:
+			return &crt, cursor, true
+		}
+
+		value, newCursor, ok := parseSignedInteger(tokens, cursor)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected number")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		*option = value
+	}
+}
+
+// parseSignedInteger parses a number, or a minus sign and a number into
+// a single token.
+func parseSignedInteger(tokens []*Token, initialCursor uint) (*Token, uint, bool) {
+	cursor := initialCursor
+
+	negative := expectToken(tokens, cursor, tokenFromSymbol(MinusSymbol))
+	if negative {
+		cursor++
+	}
+
+	number, newCursor, ok := parseToken(tokens, cursor, NumericKind)
+	if !ok {
 		return nil, initialCursor, false
 	}
-	cursor++
+	cursor = newCursor
 
-	return &InsertStatement{
-		table:  *table,
-		values: values,
-	}, cursor, true
+	if negative {
+		signed := *number
+		signed.Value = "-" + signed.Value
+		signed.Loc = tokens[initialCursor].Loc
+		return &signed, cursor, true
+	}
+
+	return number, cursor, true
 }
 
-func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter Token) (*CreateTableStatement, uint, bool) {
+func parseCreateIndexStatement(tokens []*Token, initialCursor uint, delimiter Token) (*CreateIndexStatement, uint, bool) {
 	cursor := initialCursor
 
 	if !expectToken(tokens, cursor, tokenFromKeyword(CreateKeyword)) {
@@ -406,12 +2272,25 @@ func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter To
 	}
 	cursor++
 
-	if !expectToken(tokens, cursor, tokenFromKeyword(TableKeyword)) {
+	if !expectToken(tokens, cursor, tokenFromKeyword(IndexKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
 	name, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected index name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+		helpMessage(tokens, cursor, "Expected ON keyword")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
 	if !ok {
 		helpMessage(tokens, cursor, "Expected table name")
 		return nil, initialCursor, false
@@ -424,8 +2303,9 @@ func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter To
 	}
 	cursor++
 
-	cols, newCursor, ok := parseColumnDefinitions(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	exp, newCursor, ok := parseExpression(tokens, cursor, 0)
 	if !ok {
+		helpMessage(tokens, cursor, "Expected index expression")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
@@ -436,19 +2316,23 @@ func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter To
 	}
 	cursor++
 
-	return &CreateTableStatement{
-		name: *name,
-		cols: cols,
+	return &CreateIndexStatement{
+		name:  *name,
+		table: *table,
+		exp:   *exp,
 	}, cursor, true
 }
 
-func parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token) (*[]*columnDefinition, uint, bool) {
+// parseColumnDefinitions parses the column definitions of a table and
+// its constraints, both of single columns and of several.
+func parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token) (*[]*columnDefinition, []*tableConstraint, uint, bool) {
 	cursor := initialCursor
 
 	cds := []*columnDefinition{}
+	var constraints []*tableConstraint
 	for {
 		if cursor >= uint(len(tokens)) {
-			return nil, initialCursor, false
+			return nil, nil, initialCursor, false
 		}
 
 		// Look for a delimiter
@@ -458,36 +2342,204 @@ func parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token
 		}
 
 		// Look for a comma
-		if len(cds) > 0 {
+		if cursor > initialCursor {
 			if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
 				helpMessage(tokens, cursor, "Expected comma")
-				return nil, initialCursor, false
+				return nil, nil, initialCursor, false
 			}
 
 			cursor++
 		}
 
+		// Look for a table constraint
+		if constraint, newCursor, ok := parseConstraintKind(tokens, cursor); ok {
+			cursor = newCursor
+
+			columns, newCursor, ok := parseColumnList(tokens, cursor)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected constraint columns")
+				return nil, nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			constraint.columns = *columns
+			constraints = append(constraints, constraint)
+			continue
+		}
+
 		// Look for a column name
 		id, newCursor, ok := parseToken(tokens, cursor, IdentifierKind)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected column name")
-			return nil, initialCursor, false
+			return nil, nil, initialCursor, false
 		}
 		cursor = newCursor
 
 		// Look for a column type
-		ty, newCursor, ok := parseToken(tokens, cursor, KeywordKind)
+		ty, newCursor, ok := parseTypeName(tokens, cursor)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected column type")
+			return nil, nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		cd := columnDefinition{
+			name:      *id,
+			datatype:  ty.name,
+			modifiers: ty.modifiers,
+		}
+
+		// Look for a default, identity and constraints, in any order
+		for {
+			if expectToken(tokens, cursor, tokenFromKeyword(DefaultKeyword)) {
+				cursor++
+
+				cd.defaultValue, newCursor, ok = parseExpression(tokens, cursor, 0)
+				if !ok {
+					helpMessage(tokens, cursor, "Expected default value")
+					return nil, nil, initialCursor, false
+				}
+				cursor = newCursor
+				continue
+			}
+
+			if expectToken(tokens, cursor, tokenFromKeyword(GeneratedKeyword)) {
+				newCursor, ok = parseIdentity(tokens, cursor, &cd)
+				if !ok {
+					return nil, nil, initialCursor, false
+				}
+				cursor = newCursor
+				continue
+			}
+
+			constraint, newCursor, ok := parseConstraintKind(tokens, cursor)
+			if !ok {
+				break
+			}
+			cursor = newCursor
+
+			constraint.columns = []Token{*id}
+			constraints = append(constraints, constraint)
+		}
+
+		cds = append(cds, &cd)
+	}
+
+	return &cds, constraints, cursor, true
+}
+
+// parseIdentity parses GENERATED ALWAYS AS IDENTITY or GENERATED BY
+// DEFAULT AS IDENTITY into a column definition.
+func parseIdentity(tokens []*Token, initialCursor uint, cd *columnDefinition) (uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(GeneratedKeyword)) {
+		return initialCursor, false
+	}
+	cursor++
+
+	always := expectToken(tokens, cursor, tokenFromKeyword(AlwaysKeyword))
+	if always {
+		cursor++
+	} else if expectToken(tokens, cursor, tokenFromKeyword(ByKeyword)) && expectToken(tokens, cursor+1, tokenFromKeyword(DefaultKeyword)) {
+		cursor += 2
+	} else {
+		helpMessage(tokens, cursor, "Expected ALWAYS or BY DEFAULT")
+		return initialCursor, false
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(AsKeyword)) || !expectToken(tokens, cursor+1, tokenFromKeyword(IdentityKeyword)) {
+		helpMessage(tokens, cursor, "Expected AS IDENTITY")
+		return initialCursor, false
+	}
+	cursor += 2
+
+	cd.identity = true
+	cd.always = always
+	return cursor, true
+}
+
+// parseConstraintKind parses PRIMARY KEY or UNIQUE, returning the
+// constraint without its columns.
+func parseConstraintKind(tokens []*Token, initialCursor uint) (*tableConstraint, uint, bool) {
+	cursor := initialCursor
+
+	if expectToken(tokens, cursor, tokenFromKeyword(UniqueKeyword)) {
+		cursor++
+		return &tableConstraint{}, cursor, true
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(PrimaryKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(KeyKeyword)) {
+		helpMessage(tokens, cursor, "Expected KEY")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &tableConstraint{primaryKey: true}, cursor, true
+}
+
+// parseTypeName parses a type and its modifiers.
+func parseTypeName(tokens []*Token, initialCursor uint) (*typeName, uint, bool) {
+	cursor := initialCursor
+
+	name, newCursor, ok := parseToken(tokens, cursor, KeywordKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	// DOUBLE PRECISION is just DOUBLE
+	if name.Value == string(DoubleKeyword) && expectToken(tokens, cursor, tokenFromKeyword(PrecisionKeyword)) {
+		cursor++
+	}
+
+	modifiers, newCursor, ok := parseTypeModifiers(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	return &typeName{name: *name, modifiers: modifiers}, cursor, true
+}
+
+// parseTypeModifiers parses the optional parenthesized list of numbers
+// following a column type.
+func parseTypeModifiers(tokens []*Token, initialCursor uint) ([]Token, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)) {
+		return nil, initialCursor, true
+	}
+	cursor++
+
+	modifiers := []Token{}
+	for {
+		if len(modifiers) > 0 {
+			if expectToken(tokens, cursor, tokenFromSymbol(RightParenSymbol)) {
+				cursor++
+				break
+			}
+
+			if !expectToken(tokens, cursor, tokenFromSymbol(CommaSymbol)) {
+				helpMessage(tokens, cursor, "Expected comma")
+				return nil, initialCursor, false
+			}
+			cursor++
+		}
+
+		modifier, newCursor, ok := parseToken(tokens, cursor, NumericKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected type modifier")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
 
-		cds = append(cds, &columnDefinition{
-			name:     *id,
-			datatype: *ty,
-		})
+		modifiers = append(modifiers, *modifier)
 	}
 
-	return &cds, cursor, true
+	return modifiers, cursor, true
 }
@@ -23,22 +23,144 @@ func TestParse(t *testing.T) {
 								Kind:  IdentifierKind,
 								Value: "users",
 							},
-							values: &[]*expression{
+							values: []*[]*expression{
 								{
-									literal: &Token{
-										Loc:   Location{Col: 26, Line: 0},
-										Kind:  NumericKind,
-										Value: "105",
+									{
+										literal: &Token{
+											Loc:   Location{Col: 26, Line: 0},
+											Kind:  NumericKind,
+											Value: "105",
+										},
+										kind: literalKind,
 									},
-									kind: literalKind,
+									{
+										literal: &Token{
+											Loc:   Location{Col: 32, Line: 0},
+											Kind:  NumericKind,
+											Value: "233",
+										},
+										kind: literalKind,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "INSERT INTO users (id, name) VALUES (1, 2), (3, DEFAULT);",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: InsertKind,
+						InsertStatement: &InsertStatement{
+							table: Token{
+								Loc:   Location{Col: 12, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "users",
+							},
+							columns: &[]Token{
+								{
+									Loc:   Location{Col: 19, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "id",
 								},
 								{
-									literal: &Token{
-										Loc:   Location{Col: 32, Line: 0},
-										Kind:  NumericKind,
-										Value: "233",
+									Loc:   Location{Col: 23, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "name",
+								},
+							},
+							values: []*[]*expression{
+								{
+									{
+										literal: &Token{
+											Loc:   Location{Col: 37, Line: 0},
+											Kind:  NumericKind,
+											Value: "1",
+										},
+										kind: literalKind,
 									},
-									kind: literalKind,
+									{
+										literal: &Token{
+											Loc:   Location{Col: 41, Line: 0},
+											Kind:  NumericKind,
+											Value: "2",
+										},
+										kind: literalKind,
+									},
+								},
+								{
+									{
+										literal: &Token{
+											Loc:   Location{Col: 47, Line: 0},
+											Kind:  NumericKind,
+											Value: "3",
+										},
+										kind: literalKind,
+									},
+									{
+										literal: &Token{
+											Loc:   Location{Col: 51, Line: 0},
+											Kind:  KeywordKind,
+											Value: "default",
+										},
+										kind: literalKind,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "INSERT INTO a SELECT id FROM b RETURNING id, *;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: InsertKind,
+						InsertStatement: &InsertStatement{
+							table: Token{
+								Loc:   Location{Col: 12, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "a",
+							},
+							query: &SelectStatement{
+								item: &[]*selectItem{
+									{
+										exp: &expression{
+											kind: literalKind,
+											literal: &Token{
+												Loc:   Location{Col: 21, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "id",
+											},
+										},
+									},
+								},
+								from: &fromItem{
+									table: &Token{
+										Loc:   Location{Col: 29, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "b",
+									},
+								},
+							},
+							returning: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 41, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "id",
+										},
+									},
+								},
+								{
+									asterisk: true,
 								},
 							},
 						},
@@ -89,6 +211,158 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			source: "CREATE TABLE t (id INT) USING columnar;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateTableKind,
+						CreateTableStatement: &CreateTableStatement{
+							name: Token{
+								Loc:   Location{Col: 13, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							cols: &[]*columnDefinition{
+								{
+									name: Token{
+										Loc:   Location{Col: 16, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "id",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 19, Line: 0},
+										Kind:  KeywordKind,
+										Value: "int",
+									},
+								},
+							},
+							using: &Token{
+								Loc:   Location{Col: 30, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "columnar",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "CREATE TABLE t (p DECIMAL(10, 2), r DOUBLE PRECISION);",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateTableKind,
+						CreateTableStatement: &CreateTableStatement{
+							name: Token{
+								Loc:   Location{Col: 13, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							cols: &[]*columnDefinition{
+								{
+									name: Token{
+										Loc:   Location{Col: 16, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "p",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 18, Line: 0},
+										Kind:  KeywordKind,
+										Value: "decimal",
+									},
+									modifiers: []Token{
+										{
+											Loc:   Location{Col: 26, Line: 0},
+											Kind:  NumericKind,
+											Value: "10",
+										},
+										{
+											Loc:   Location{Col: 31, Line: 0},
+											Kind:  NumericKind,
+											Value: "2",
+										},
+									},
+								},
+								{
+									name: Token{
+										Loc:   Location{Col: 36, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "r",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 38, Line: 0},
+										Kind:  KeywordKind,
+										Value: "double",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "CREATE TABLE t (id UUID DEFAULT gen_random_uuid(), n INT DEFAULT 1);",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateTableKind,
+						CreateTableStatement: &CreateTableStatement{
+							name: Token{
+								Loc:   Location{Col: 13, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							cols: &[]*columnDefinition{
+								{
+									name: Token{
+										Loc:   Location{Col: 16, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "id",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 19, Line: 0},
+										Kind:  KeywordKind,
+										Value: "uuid",
+									},
+									defaultValue: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 32, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "gen_random_uuid",
+											},
+										},
+									},
+								},
+								{
+									name: Token{
+										Loc:   Location{Col: 51, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "n",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 53, Line: 0},
+										Kind:  KeywordKind,
+										Value: "int",
+									},
+									defaultValue: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 65, Line: 0},
+											Kind:  NumericKind,
+											Value: "1",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			source: "SELECT *, exclusive;",
 			ast: &Ast{
@@ -162,6 +436,1489 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			source: "SELECT id FROM users WHERE id = 1 + 2 * 3 OR id > 4;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 7, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "id",
+										},
+									},
+								},
+							},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 15, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "users",
+								},
+							},
+							where: &expression{
+								kind: binaryKind,
+								binary: &binaryExpression{
+									a: expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 27, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "id",
+												},
+											},
+											b: expression{
+												kind: binaryKind,
+												binary: &binaryExpression{
+													a: expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 32, Line: 0},
+															Kind:  NumericKind,
+															Value: "1",
+														},
+													},
+													b: expression{
+														kind: binaryKind,
+														binary: &binaryExpression{
+															a: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 37, Line: 0},
+																	Kind:  NumericKind,
+																	Value: "2",
+																},
+															},
+															b: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 42, Line: 0},
+																	Kind:  NumericKind,
+																	Value: "3",
+																},
+															},
+															op: Token{
+																Loc:   Location{Col: 40, Line: 0},
+																Kind:  SymbolKind,
+																Value: "*",
+															},
+														},
+													},
+													op: Token{
+														Loc:   Location{Col: 35, Line: 0},
+														Kind:  SymbolKind,
+														Value: "+",
+													},
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 30, Line: 0},
+												Kind:  SymbolKind,
+												Value: "=",
+											},
+										},
+									},
+									b: expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 48, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "id",
+												},
+											},
+											b: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 53, Line: 0},
+													Kind:  NumericKind,
+													Value: "4",
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 51, Line: 0},
+												Kind:  SymbolKind,
+												Value: ">",
+											},
+										},
+									},
+									op: Token{
+										Loc:   Location{Col: 45, Line: 0},
+										Kind:  KeywordKind,
+										Value: "or",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "CREATE INDEX users_id ON users (id);",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateIndexKind,
+						CreateIndexStatement: &CreateIndexStatement{
+							name: Token{
+								Loc:   Location{Col: 13, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "users_id",
+							},
+							table: Token{
+								Loc:   Location{Col: 25, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "users",
+							},
+							exp: expression{
+								kind: literalKind,
+								literal: &Token{
+									Loc:   Location{Col: 32, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "id",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "EXPLAIN ANALYZE SELECT id FROM users;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: ExplainKind,
+						ExplainStatement: &ExplainStatement{
+							analyze: true,
+							slct: &SelectStatement{
+								item: &[]*selectItem{
+									{
+										exp: &expression{
+											kind: literalKind,
+											literal: &Token{
+												Loc:   Location{Col: 23, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "id",
+											},
+										},
+									},
+								},
+								from: &fromItem{
+									table: &Token{
+										Loc:   Location{Col: 31, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "users",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT * FROM a x JOIN b ON x.id = b.id;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{{asterisk: true}},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 14, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "a",
+								},
+								as: &Token{
+									Loc:   Location{Col: 16, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "x",
+								},
+							},
+							joins: []*joinItem{
+								{
+									from: &fromItem{
+										table: &Token{
+											Loc:   Location{Col: 23, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "b",
+										},
+									},
+									on: &expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: literalKind,
+												table: &Token{
+													Loc:   Location{Col: 28, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "x",
+												},
+												literal: &Token{
+													Loc:   Location{Col: 30, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "id",
+												},
+											},
+											b: expression{
+												kind: literalKind,
+												table: &Token{
+													Loc:   Location{Col: 35, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "b",
+												},
+												literal: &Token{
+													Loc:   Location{Col: 37, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "id",
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 33, Line: 0},
+												Kind:  SymbolKind,
+												Value: "=",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT * FROM (SELECT a FROM t) s WHERE a NOT IN (SELECT b FROM u); SELECT EXISTS (SELECT 1);",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{{asterisk: true}},
+							from: &fromItem{
+								subquery: &SelectStatement{
+									item: &[]*selectItem{
+										{
+											exp: &expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 22, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "a",
+												},
+											},
+										},
+									},
+									from: &fromItem{
+										table: &Token{
+											Loc:   Location{Col: 29, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "t",
+										},
+									},
+								},
+								as: &Token{
+									Loc:   Location{Col: 32, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "s",
+								},
+							},
+							where: &expression{
+								kind: subqueryKind,
+								subquery: &subqueryExpression{
+									slct: &SelectStatement{
+										item: &[]*selectItem{
+											{
+												exp: &expression{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 57, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "b",
+													},
+												},
+											},
+										},
+										from: &fromItem{
+											table: &Token{
+												Loc:   Location{Col: 64, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "u",
+											},
+										},
+									},
+									in: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 40, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "a",
+										},
+									},
+									not: true,
+								},
+							},
+						},
+					},
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: subqueryKind,
+										subquery: &subqueryExpression{
+											slct: &SelectStatement{
+												item: &[]*selectItem{
+													{
+														exp: &expression{
+															kind: literalKind,
+															literal: &Token{
+																Loc:   Location{Col: 90, Line: 0},
+																Kind:  NumericKind,
+																Value: "1",
+															},
+														},
+													},
+												},
+											},
+											exists: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n FROM t) SELECT n FROM t;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							with: &withClause{
+								recursive: true,
+								ctes: []*commonTableExpression{
+									{
+										name: Token{
+											Loc:   Location{Col: 15, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "t",
+										},
+										columns: &[]Token{
+											{
+												Loc:   Location{Col: 17, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "n",
+											},
+										},
+										slct: &SelectStatement{
+											item: &[]*selectItem{
+												{
+													exp: &expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 31, Line: 0},
+															Kind:  NumericKind,
+															Value: "1",
+														},
+													},
+												},
+											},
+										},
+										recursive: &SelectStatement{
+											item: &[]*selectItem{
+												{
+													exp: &expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 51, Line: 0},
+															Kind:  IdentifierKind,
+															Value: "n",
+														},
+													},
+												},
+											},
+											from: &fromItem{
+												table: &Token{
+													Loc:   Location{Col: 58, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "t",
+												},
+											},
+										},
+										all: true,
+									},
+								},
+							},
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 68, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "n",
+										},
+									},
+								},
+							},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 75, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "t",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT 1 EXCEPT SELECT 2 INTERSECT ALL SELECT 3;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							set: &setOperation{
+								op: Token{
+									Loc:   Location{Col: 10, Line: 0},
+									Kind:  KeywordKind,
+									Value: "except",
+								},
+								left: &SelectStatement{
+									item: &[]*selectItem{
+										{
+											exp: &expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 7, Line: 0},
+													Kind:  NumericKind,
+													Value: "1",
+												},
+											},
+										},
+									},
+								},
+								right: &SelectStatement{
+									set: &setOperation{
+										op: Token{
+											Loc:   Location{Col: 27, Line: 0},
+											Kind:  KeywordKind,
+											Value: "intersect",
+										},
+										all: true,
+										left: &SelectStatement{
+											item: &[]*selectItem{
+												{
+													exp: &expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 24, Line: 0},
+															Kind:  NumericKind,
+															Value: "2",
+														},
+													},
+												},
+											},
+										},
+										right: &SelectStatement{
+											item: &[]*selectItem{
+												{
+													exp: &expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 48, Line: 0},
+															Kind:  NumericKind,
+															Value: "3",
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT DISTINCT ON (a) b FROM t;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							distinct: true,
+							distinctOn: []*expression{
+								{
+									kind: literalKind,
+									literal: &Token{
+										Loc:   Location{Col: 20, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "a",
+									},
+								},
+							},
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 23, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "b",
+										},
+									},
+								},
+							},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 30, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "t",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// NOT binds looser than comparisons and tighter than AND
+			source: "SELECT a FROM t WHERE NOT b = 1 AND c;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 7, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "a",
+										},
+									},
+								},
+							},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 14, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "t",
+								},
+							},
+							where: &expression{
+								kind: binaryKind,
+								binary: &binaryExpression{
+									a: expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: binaryKind,
+												binary: &binaryExpression{
+													a: expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 26, Line: 0},
+															Kind:  IdentifierKind,
+															Value: "b",
+														},
+													},
+													b: expression{
+														kind: literalKind,
+														literal: &Token{
+															Loc:   Location{Col: 30, Line: 0},
+															Kind:  NumericKind,
+															Value: "1",
+														},
+													},
+													op: Token{
+														Loc:   Location{Col: 28, Line: 0},
+														Kind:  SymbolKind,
+														Value: "=",
+													},
+												},
+											},
+											b: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 22, Line: 0},
+													Kind:  KeywordKind,
+													Value: "false",
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 22, Line: 0},
+												Kind:  SymbolKind,
+												Value: "=",
+											},
+										},
+									},
+									b: expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 37, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "c",
+										},
+									},
+									op: Token{
+										Loc:   Location{Col: 33, Line: 0},
+										Kind:  KeywordKind,
+										Value: "and",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT a FROM t WHERE a NOT IN (1, b) OR a BETWEEN 2 AND 3 OR a ILIKE 'x';",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 7, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "a",
+										},
+									},
+								},
+							},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 14, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "t",
+								},
+							},
+							where: &expression{
+								kind: binaryKind,
+								binary: &binaryExpression{
+									a: expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: binaryKind,
+												binary: &binaryExpression{
+													a: expression{
+														kind: binaryKind,
+														binary: &binaryExpression{
+															a: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 22, Line: 0},
+																	Kind:  IdentifierKind,
+																	Value: "a",
+																},
+															},
+															b: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 32, Line: 0},
+																	Kind:  NumericKind,
+																	Value: "1",
+																},
+															},
+															op: Token{
+																Loc:   Location{Col: 28, Line: 0},
+																Kind:  SymbolKind,
+																Value: "<>",
+															},
+														},
+													},
+													b: expression{
+														kind: binaryKind,
+														binary: &binaryExpression{
+															a: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 22, Line: 0},
+																	Kind:  IdentifierKind,
+																	Value: "a",
+																},
+															},
+															b: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 36, Line: 0},
+																	Kind:  IdentifierKind,
+																	Value: "b",
+																},
+															},
+															op: Token{
+																Loc:   Location{Col: 28, Line: 0},
+																Kind:  SymbolKind,
+																Value: "<>",
+															},
+														},
+													},
+													op: Token{
+														Loc:   Location{Col: 28, Line: 0},
+														Kind:  KeywordKind,
+														Value: "and",
+													},
+												},
+											},
+											b: expression{
+												kind: binaryKind,
+												binary: &binaryExpression{
+													a: expression{
+														kind: binaryKind,
+														binary: &binaryExpression{
+															a: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 42, Line: 0},
+																	Kind:  IdentifierKind,
+																	Value: "a",
+																},
+															},
+															b: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 52, Line: 0},
+																	Kind:  NumericKind,
+																	Value: "2",
+																},
+															},
+															op: Token{
+																Loc:   Location{Col: 44, Line: 0},
+																Kind:  SymbolKind,
+																Value: ">=",
+															},
+														},
+													},
+													b: expression{
+														kind: binaryKind,
+														binary: &binaryExpression{
+															a: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 42, Line: 0},
+																	Kind:  IdentifierKind,
+																	Value: "a",
+																},
+															},
+															b: expression{
+																kind: literalKind,
+																literal: &Token{
+																	Loc:   Location{Col: 59, Line: 0},
+																	Kind:  NumericKind,
+																	Value: "3",
+																},
+															},
+															op: Token{
+																Loc:   Location{Col: 44, Line: 0},
+																Kind:  SymbolKind,
+																Value: "<=",
+															},
+														},
+													},
+													op: Token{
+														Loc:   Location{Col: 44, Line: 0},
+														Kind:  KeywordKind,
+														Value: "and",
+													},
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 39, Line: 0},
+												Kind:  KeywordKind,
+												Value: "or",
+											},
+										},
+									},
+									b: expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 65, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "a",
+												},
+											},
+											b: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 73, Line: 0},
+													Kind:  StringKind,
+													Value: "x",
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 67, Line: 0},
+												Kind:  SymbolKind,
+												Value: "~~*",
+											},
+										},
+									},
+									op: Token{
+										Loc:   Location{Col: 62, Line: 0},
+										Kind:  KeywordKind,
+										Value: "or",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT CASE a WHEN 1 THEN b ELSE c END;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 7, Line: 0},
+												Kind:  KeywordKind,
+												Value: "case",
+											},
+											args: []expression{
+												{
+													kind: binaryKind,
+													binary: &binaryExpression{
+														a: expression{
+															kind: literalKind,
+															literal: &Token{
+																Loc:   Location{Col: 12, Line: 0},
+																Kind:  IdentifierKind,
+																Value: "a",
+															},
+														},
+														b: expression{
+															kind: literalKind,
+															literal: &Token{
+																Loc:   Location{Col: 19, Line: 0},
+																Kind:  NumericKind,
+																Value: "1",
+															},
+														},
+														op: Token{
+															Loc:   Location{Col: 14, Line: 0},
+															Kind:  SymbolKind,
+															Value: "=",
+														},
+													},
+												},
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 27, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "b",
+													},
+												},
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 34, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "c",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT count(*), sum(a) FROM t GROUP BY b;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 7, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "count",
+											},
+											asterisk: true,
+										},
+									},
+								},
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 17, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "sum",
+											},
+											args: []expression{
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 21, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "a",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+							from: &fromItem{
+								table: &Token{
+									Loc:   Location{Col: 29, Line: 0},
+									Kind:  IdentifierKind,
+									Value: "t",
+								},
+							},
+							groupBy: []*expression{
+								{
+									kind: literalKind,
+									literal: &Token{
+										Loc:   Location{Col: 40, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "b",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT EXTRACT(year FROM d), DATE '2024-01-01';",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 7, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "extract",
+											},
+											args: []expression{
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 15, Line: 0},
+														Kind:  StringKind,
+														Value: "year",
+													},
+												},
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 25, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "d",
+													},
+												},
+											},
+										},
+									},
+								},
+								{
+									exp: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 34, Line: 0},
+											Kind:  StringKind,
+											Value: "2024-01-01",
+										},
+										typ: &Token{
+											Loc:   Location{Col: 29, Line: 0},
+											Kind:  KeywordKind,
+											Value: "date",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT CAST(a AS DECIMAL(5, 2)), b::text;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 7, Line: 0},
+												Kind:  KeywordKind,
+												Value: "cast",
+											},
+											args: []expression{
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 12, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "a",
+													},
+												},
+											},
+											cast: &typeName{
+												name: Token{
+													Loc:   Location{Col: 17, Line: 0},
+													Kind:  KeywordKind,
+													Value: "decimal",
+												},
+												modifiers: []Token{
+													{
+														Loc:   Location{Col: 25, Line: 0},
+														Kind:  NumericKind,
+														Value: "5",
+													},
+													{
+														Loc:   Location{Col: 29, Line: 0},
+														Kind:  NumericKind,
+														Value: "2",
+													},
+												},
+											},
+										},
+									},
+								},
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 36, Line: 0},
+												Kind:  KeywordKind,
+												Value: "cast",
+											},
+											args: []expression{
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 35, Line: 0},
+														Kind:  IdentifierKind,
+														Value: "b",
+													},
+												},
+											},
+											cast: &typeName{
+												name: Token{
+													Loc:   Location{Col: 38, Line: 0},
+													Kind:  KeywordKind,
+													Value: "text",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT -2::int, -a * b;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							item: &[]*selectItem{
+								{
+									exp: &expression{
+										kind: functionKind,
+										function: &functionExpression{
+											name: Token{
+												Loc:   Location{Col: 10, Line: 0},
+												Kind:  KeywordKind,
+												Value: "cast",
+											},
+											args: []expression{
+												{
+													kind: literalKind,
+													literal: &Token{
+														Loc:   Location{Col: 7, Line: 0},
+														Kind:  NumericKind,
+														Value: "-2",
+													},
+												},
+											},
+											cast: &typeName{
+												name: Token{
+													Loc:   Location{Col: 12, Line: 0},
+													Kind:  KeywordKind,
+													Value: "int",
+												},
+											},
+										},
+									},
+								},
+								{
+									exp: &expression{
+										kind: binaryKind,
+										binary: &binaryExpression{
+											a: expression{
+												kind: functionKind,
+												function: &functionExpression{
+													name: Token{
+														Loc:   Location{Col: 17, Line: 0},
+														Kind:  SymbolKind,
+														Value: "-",
+													},
+													args: []expression{
+														{
+															kind: literalKind,
+															literal: &Token{
+																Loc:   Location{Col: 18, Line: 0},
+																Kind:  IdentifierKind,
+																Value: "a",
+															},
+														},
+													},
+												},
+											},
+											b: expression{
+												kind: literalKind,
+												literal: &Token{
+													Loc:   Location{Col: 22, Line: 0},
+													Kind:  IdentifierKind,
+													Value: "b",
+												},
+											},
+											op: Token{
+												Loc:   Location{Col: 20, Line: 0},
+												Kind:  SymbolKind,
+												Value: "*",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "CREATE TABLE t (a INT PRIMARY KEY, b TEXT DEFAULT 1 UNIQUE, UNIQUE (a, b));",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateTableKind,
+						CreateTableStatement: &CreateTableStatement{
+							name: Token{
+								Loc:   Location{Col: 13, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							cols: &[]*columnDefinition{
+								{
+									name: Token{
+										Loc:   Location{Col: 16, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "a",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 18, Line: 0},
+										Kind:  KeywordKind,
+										Value: "int",
+									},
+								},
+								{
+									name: Token{
+										Loc:   Location{Col: 35, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "b",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 37, Line: 0},
+										Kind:  KeywordKind,
+										Value: "text",
+									},
+									defaultValue: &expression{
+										kind: literalKind,
+										literal: &Token{
+											Loc:   Location{Col: 50, Line: 0},
+											Kind:  NumericKind,
+											Value: "1",
+										},
+									},
+								},
+							},
+							constraints: []*tableConstraint{
+								{
+									primaryKey: true,
+									columns: []Token{
+										{
+											Loc:   Location{Col: 16, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "a",
+										},
+									},
+								},
+								{
+									columns: []Token{
+										{
+											Loc:   Location{Col: 35, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "b",
+										},
+									},
+								},
+								{
+									columns: []Token{
+										{
+											Loc:   Location{Col: 69, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "a",
+										},
+										{
+											Loc:   Location{Col: 72, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "b",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "CREATE SEQUENCE s START WITH 5 INCREMENT BY -2;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateSequenceKind,
+						CreateSequenceStatement: &CreateSequenceStatement{
+							name: Token{
+								Loc:   Location{Col: 16, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "s",
+							},
+							start: &Token{
+								Loc:   Location{Col: 29, Line: 0},
+								Kind:  NumericKind,
+								Value: "5",
+							},
+							increment: &Token{
+								Loc:   Location{Col: 45, Line: 0},
+								Kind:  NumericKind,
+								Value: "-2",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "CREATE TABLE t (a SERIAL, b INT GENERATED BY DEFAULT AS IDENTITY);",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateTableKind,
+						CreateTableStatement: &CreateTableStatement{
+							name: Token{
+								Loc:   Location{Col: 13, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							cols: &[]*columnDefinition{
+								{
+									name: Token{
+										Loc:   Location{Col: 16, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "a",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 18, Line: 0},
+										Kind:  KeywordKind,
+										Value: "serial",
+									},
+								},
+								{
+									name: Token{
+										Loc:   Location{Col: 26, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "b",
+									},
+									datatype: Token{
+										Loc:   Location{Col: 28, Line: 0},
+										Kind:  KeywordKind,
+										Value: "int",
+									},
+									identity: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "INSERT INTO t VALUES (1, 2) ON CONFLICT (a) DO UPDATE SET b = excluded.b, a = 3;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: InsertKind,
+						InsertStatement: &InsertStatement{
+							table: Token{
+								Loc:   Location{Col: 12, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							values: []*[]*expression{
+								{
+									{
+										literal: &Token{
+											Loc:   Location{Col: 22, Line: 0},
+											Kind:  NumericKind,
+											Value: "1",
+										},
+										kind: literalKind,
+									},
+									{
+										literal: &Token{
+											Loc:   Location{Col: 26, Line: 0},
+											Kind:  NumericKind,
+											Value: "2",
+										},
+										kind: literalKind,
+									},
+								},
+							},
+							onConflict: &onConflict{
+								columns: &[]Token{
+									{
+										Loc:   Location{Col: 43, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "a",
+									},
+								},
+								set: []*assignment{
+									{
+										column: Token{
+											Loc:   Location{Col: 60, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "b",
+										},
+										value: expression{
+											kind: literalKind,
+											literal: &Token{
+												Loc:   Location{Col: 73, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "b",
+											},
+											table: &Token{
+												Loc:   Location{Col: 64, Line: 0},
+												Kind:  IdentifierKind,
+												Value: "excluded",
+											},
+										},
+									},
+									{
+										column: Token{
+											Loc:   Location{Col: 76, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "a",
+										},
+										value: expression{
+											kind: literalKind,
+											literal: &Token{
+												Loc:   Location{Col: 80, Line: 0},
+												Kind:  NumericKind,
+												Value: "3",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "ANALYZE users;",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: AnalyzeKind,
+						AnalyzeStatement: &AnalyzeStatement{
+							table: &Token{
+								Loc:   Location{Col: 8, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "users",
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,103 @@
+package ashudb
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LIKE and ILIKE are parsed into the ~~ and ~~* operators, and NOT
+// LIKE and NOT ILIKE into !~~ and !~~*, alongside the regular
+// expression operators ~, ~*, !~ and !~*. Regular expressions are Go's.
+
+// matchPattern reports whether the pattern operator op holds for s
+// and pattern.
+func matchPattern(op Symbol, s, pattern string) (bool, error) {
+	var matched bool
+	switch op {
+	case LikeSymbol, NotLikeSymbol, IlikeSymbol, NotIlikeSymbol:
+		if op == IlikeSymbol || op == NotIlikeSymbol {
+			s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+		}
+
+		var err error
+		matched, err = matchLike(s, pattern)
+		if err != nil {
+			return false, err
+		}
+	default:
+		if op == IregexSymbol || op == NotIregexSymbol {
+			pattern = "(?i)" + pattern
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, ErrInvalidPattern
+		}
+
+		matched = re.MatchString(s)
+	}
+
+	negated := strings.HasPrefix(string(op), "!")
+	return matched != negated, nil
+}
+
+// likeElement is a character of a LIKE pattern, wildcard if it is an
+// unescaped % or _.
+type likeElement struct {
+	r        rune
+	wildcard bool
+}
+
+// matchLike reports whether all of s matches a LIKE pattern, where %
+// matches any run of characters, _ any one character and \ escapes
+// the character after it.
+func matchLike(s, pattern string) (bool, error) {
+	var elements []likeElement
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			elements = append(elements, likeElement{r: r})
+			escaped = false
+		case r == '\\':
+			escaped = true
+		default:
+			elements = append(elements, likeElement{r: r, wildcard: r == '%' || r == '_'})
+		}
+	}
+
+	if escaped {
+		return false, ErrInvalidPattern
+	}
+
+	many := func(j int) bool {
+		return j < len(elements) && elements[j].wildcard && elements[j].r == '%'
+	}
+
+	// On a mismatch the last % seen is made to match one more
+	// character, and matching starts again after it
+	text := []rune(s)
+	i, j := 0, 0
+	star, mark := -1, 0
+	for i < len(text) {
+		switch {
+		case many(j):
+			star, mark = j, i
+			j++
+		case j < len(elements) && (elements[j].wildcard || elements[j].r == text[i]):
+			i++
+			j++
+		case star >= 0:
+			mark++
+			i, j = mark, star+1
+		default:
+			return false, nil
+		}
+	}
+
+	for many(j) {
+		j++
+	}
+
+	return j == len(elements), nil
+}
@@ -0,0 +1,549 @@
+package ashudb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type planKind uint
+
+const (
+	scanPlanKind planKind = iota
+	indexScanPlanKind
+	filterPlanKind
+	projectPlanKind
+	joinPlanKind
+	aggregatePlanKind
+	subqueryScanPlanKind
+	cteScanPlanKind
+	setOperationPlanKind
+	distinctPlanKind
+)
+
+// scanPlan reads every row of a table. When columns is non-nil only
+// those columns are produced, in that order.
+type scanPlan struct {
+	table   Token
+	as      *Token
+	columns []string
+}
+
+// indexScanPlan reads the rows of a table whose indexed expression
+// equals value. cond is the original equality and is rechecked on
+// every row the index returns.
+type indexScanPlan struct {
+	table   Token
+	as      *Token
+	index   string
+	value   expression
+	cond    expression
+	columns []string
+}
+
+// subqueryScanPlan reads the rows of a subquery in FROM, with its
+// columns qualified by the subquery's alias.
+type subqueryScanPlan struct {
+	child *plan
+	as    Token
+}
+
+// cteScanPlan reads the rows of a common table expression.
+type cteScanPlan struct {
+	cte   *boundCTE
+	table Token
+	as    *Token
+}
+
+// setOperationPlan combines the rows of two plans as op, one of UNION,
+// INTERSECT and EXCEPT, does.
+type setOperationPlan struct {
+	op    Keyword
+	all   bool
+	left  *plan
+	right *plan
+}
+
+// distinctPlan keeps the first of the rows of its child with the same
+// values of on, or when on is nil, one of every distinct row.
+type distinctPlan struct {
+	on    []expression
+	child *plan
+}
+
+type filterPlan struct {
+	predicate expression
+	child     *plan
+}
+
+type projectPlan struct {
+	items []*selectItem
+	child *plan
+	// from lists the tables in FROM clause order, so that * expands
+	// in that order however the joins below were reordered
+	from []string
+}
+
+type joinAlgorithm uint
+
+const (
+	nestedLoopJoin joinAlgorithm = iota
+	hashJoin
+	mergeJoin
+)
+
+func (ja joinAlgorithm) String() string {
+	switch ja {
+	case hashJoin:
+		return "Hash Join"
+	case mergeJoin:
+		return "Merge Join"
+	}
+
+	return "Nested Loop"
+}
+
+// joinPlan is an inner join. Hash and merge joins match rows on
+// leftKeys = rightKeys, building the hash table from the right side.
+// cond, when set, is checked on every joined row.
+type joinPlan struct {
+	left      *plan
+	right     *plan
+	cond      *expression
+	algorithm joinAlgorithm
+	leftKeys  []expression
+	rightKeys []expression
+}
+
+// aggregatePlan groups the rows of its child on groupBy and computes
+// the aggregates over every group. It produces the group keys followed
+// by the aggregates. Keys that are plain columns keep their name,
+// everything else is named after its generated code, which is how the
+// projection above refers to it.
+type aggregatePlan struct {
+	groupBy    []expression
+	aggregates []expression
+	child      *plan
+}
+
+// planAnalysis records what happened when a plan node was executed.
+// duration includes the time spent in the node's children.
+type planAnalysis struct {
+	rows     int
+	duration time.Duration
+}
+
+// plan is a tree of relational operators. A nil child produces a
+// single row with no columns, which is what a SELECT without FROM
+// evaluates against.
+type plan struct {
+	scan      *scanPlan
+	indexScan *indexScanPlan
+	filter    *filterPlan
+	project   *projectPlan
+	join      *joinPlan
+	aggregate *aggregatePlan
+	subquery  *subqueryScanPlan
+	cteScan   *cteScanPlan
+	set       *setOperationPlan
+	distinct  *distinctPlan
+	kind      planKind
+
+	// rows is the optimizer's estimate of how many rows the node
+	// produces
+	rows float64
+
+	// analysis is filled in by execution when it is non-nil, for
+	// EXPLAIN ANALYZE
+	analysis *planAnalysis
+}
+
+func qualifier(table Token, as *Token) string {
+	if as != nil {
+		return as.Value
+	}
+
+	return table.Value
+}
+
+func newScanPlan(from *fromItem) *plan {
+	if from.subquery != nil {
+		return &plan{
+			kind:     subqueryScanPlanKind,
+			subquery: &subqueryScanPlan{child: newPlan(from.subquery), as: *from.as},
+		}
+	}
+
+	if from.cte != nil {
+		return &plan{
+			kind:    cteScanPlanKind,
+			cteScan: &cteScanPlan{cte: from.cte, table: *from.table, as: from.as},
+		}
+	}
+
+	return &plan{
+		kind: scanPlanKind,
+		scan: &scanPlan{table: *from.table, as: from.as},
+	}
+}
+
+func newPlan(slct *SelectStatement) *plan {
+	if slct.set != nil {
+		return &plan{
+			kind: setOperationPlanKind,
+			set: &setOperationPlan{
+				op:    Keyword(slct.set.op.Value),
+				all:   slct.set.all,
+				left:  newPlan(slct.set.left),
+				right: newPlan(slct.set.right),
+			},
+		}
+	}
+
+	var p *plan
+	var from []string
+	var predicates []expression
+	if slct.from != nil {
+		p = newScanPlan(slct.from)
+
+		// Inner joins are planned as cross joins filtered by their ON
+		// conditions, leaving it to the optimizer to push those back
+		// down into the joins
+		for _, join := range slct.joins {
+			p = &plan{
+				kind: joinPlanKind,
+				join: &joinPlan{
+					left:  p,
+					right: newScanPlan(join.from),
+				},
+			}
+
+			if join.on != nil {
+				predicates = append(predicates, *join.on)
+			}
+		}
+
+		if len(slct.joins) > 0 {
+			from = append(from, slct.from.qualifier())
+			for _, join := range slct.joins {
+				from = append(from, join.from.qualifier())
+			}
+		}
+	}
+
+	if slct.where != nil {
+		predicates = append(predicates, *slct.where)
+	}
+	p = newFilterPlan(predicates, p)
+
+	items := *slct.item
+	aggregated := len(slct.groupBy) > 0 || hasAggregates(items)
+	if aggregated {
+		p, items = newAggregatePlan(slct.groupBy, items, p)
+	}
+
+	// DISTINCT ON is over the rows the items are computed from
+	if len(slct.distinctOn) > 0 {
+		distinct := &distinctPlan{child: p}
+		for _, exp := range slct.distinctOn {
+			if !aggregated {
+				distinct.on = append(distinct.on, *exp)
+				continue
+			}
+
+			p.aggregate.aggregates = collectAggregates(*exp, p.aggregate.aggregates)
+			distinct.on = append(distinct.on, replaceAggregated(*exp, p.aggregate.groupBy))
+		}
+
+		p = &plan{kind: distinctPlanKind, distinct: distinct}
+	}
+
+	p = &plan{
+		kind: projectPlanKind,
+		project: &projectPlan{
+			items: items,
+			child: p,
+			from:  from,
+		},
+	}
+
+	if slct.distinct && len(slct.distinctOn) == 0 {
+		p = &plan{kind: distinctPlanKind, distinct: &distinctPlan{child: p}}
+	}
+
+	return p
+}
+
+func isColumn(e expression) bool {
+	return e.kind == literalKind && e.literal.Kind == IdentifierKind
+}
+
+func isAggregate(e expression) bool {
+	if e.kind != functionKind {
+		return false
+	}
+
+	_, ok := aggregateFunctions[e.function.name.Value]
+	return ok
+}
+
+func hasAggregates(items []*selectItem) bool {
+	for _, item := range items {
+		if item.exp != nil && len(collectAggregates(*item.exp, nil)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectAggregates appends every aggregate call in e that is not
+// already in aggregates.
+func collectAggregates(e expression, aggregates []expression) []expression {
+	switch e.kind {
+	case binaryKind:
+		aggregates = collectAggregates(e.binary.a, aggregates)
+		return collectAggregates(e.binary.b, aggregates)
+	case functionKind:
+		if !isAggregate(e) {
+			for _, arg := range e.function.args {
+				aggregates = collectAggregates(arg, aggregates)
+			}
+
+			return aggregates
+		}
+
+		for _, aggregate := range aggregates {
+			if aggregate.generateCode() == e.generateCode() {
+				return aggregates
+			}
+		}
+
+		return append(aggregates, e)
+	}
+
+	return aggregates
+}
+
+// replaceAggregated rewrites an expression over the input of an
+// aggregation into one over its output, referring to the group keys
+// and aggregates by name.
+func replaceAggregated(e expression, groupBy []expression) expression {
+	if isAggregate(e) {
+		return expression{
+			literal: &Token{Value: e.generateCode(), Kind: IdentifierKind},
+			kind:    literalKind,
+		}
+	}
+
+	for _, key := range groupBy {
+		if !isColumn(key) && key.generateCode() == e.generateCode() {
+			return expression{
+				literal: &Token{Value: e.generateCode(), Kind: IdentifierKind},
+				kind:    literalKind,
+			}
+		}
+	}
+
+	switch e.kind {
+	case binaryKind:
+		return expression{
+			kind: binaryKind,
+			binary: &binaryExpression{
+				a:  replaceAggregated(e.binary.a, groupBy),
+				b:  replaceAggregated(e.binary.b, groupBy),
+				op: e.binary.op,
+			},
+		}
+	case functionKind:
+		function := *e.function
+		function.args = nil
+		for _, arg := range e.function.args {
+			function.args = append(function.args, replaceAggregated(arg, groupBy))
+		}
+
+		return expression{function: &function, kind: functionKind}
+	}
+
+	return e
+}
+
+// newAggregatePlan groups child on groupBy and computes the aggregates
+// items call for, returning the items rewritten to read the result.
+func newAggregatePlan(groupBy []*expression, items []*selectItem, child *plan) (*plan, []*selectItem) {
+	aggregate := &aggregatePlan{child: child}
+	for _, key := range groupBy {
+		aggregate.groupBy = append(aggregate.groupBy, *key)
+	}
+
+	rewritten := []*selectItem{}
+	for _, item := range items {
+		if item.asterisk {
+			rewritten = append(rewritten, item)
+			continue
+		}
+
+		aggregate.aggregates = collectAggregates(*item.exp, aggregate.aggregates)
+
+		exp := replaceAggregated(*item.exp, aggregate.groupBy)
+		replaced := selectItem{exp: &exp, as: item.as}
+
+		// A bare call is named after its function, as in `count`, and
+		// a cast after what it casts
+		if item.as == nil && item.exp.kind == functionKind {
+			name := item.exp.function.name
+			name.Value = selectItemName(item)
+			replaced.as = &name
+		}
+
+		rewritten = append(rewritten, &replaced)
+	}
+
+	return &plan{kind: aggregatePlanKind, aggregate: aggregate}, rewritten
+}
+
+// children returns the inputs of a plan node.
+func (p *plan) children() []*plan {
+	switch p.kind {
+	case filterPlanKind:
+		return []*plan{p.filter.child}
+	case projectPlanKind:
+		return []*plan{p.project.child}
+	case joinPlanKind:
+		return []*plan{p.join.left, p.join.right}
+	case aggregatePlanKind:
+		return []*plan{p.aggregate.child}
+	case subqueryScanPlanKind:
+		return []*plan{p.subquery.child}
+	case setOperationPlanKind:
+		return []*plan{p.set.left, p.set.right}
+	case distinctPlanKind:
+		return []*plan{p.distinct.child}
+	}
+
+	return nil
+}
+
+// analyze prepares every node of the plan to record its execution.
+func (p *plan) analyze() {
+	if p == nil {
+		return
+	}
+
+	p.analysis = &planAnalysis{}
+	for _, child := range p.children() {
+		child.analyze()
+	}
+}
+
+func explainColumns(columns []string) string {
+	if columns == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%s)", strings.Join(columns, ", "))
+}
+
+func explainTable(table Token, as *Token) string {
+	if as != nil {
+		return table.Value + " " + as.Value
+	}
+
+	return table.Value
+}
+
+// explain renders the plan as an indented tree, one line per node.
+func (p *plan) explain() []string {
+	if p == nil {
+		return nil
+	}
+
+	var line string
+	switch p.kind {
+	case scanPlanKind:
+		line = fmt.Sprintf("Scan on %s%s", explainTable(p.scan.table, p.scan.as), explainColumns(p.scan.columns))
+	case subqueryScanPlanKind:
+		line = "Subquery Scan on " + p.subquery.as.Value
+	case cteScanPlanKind:
+		line = "CTE Scan on " + explainTable(p.cteScan.table, p.cteScan.as)
+	case distinctPlanKind:
+		line = "Distinct"
+		if p.distinct.on != nil {
+			var on []string
+			for _, exp := range p.distinct.on {
+				on = append(on, exp.generateCode())
+			}
+
+			line += " On: " + strings.Join(on, ", ")
+		}
+	case setOperationPlanKind:
+		line = strings.ToUpper(string(p.set.op[:1])) + string(p.set.op[1:])
+		if p.set.all {
+			line += " All"
+		}
+	case indexScanPlanKind:
+		line = fmt.Sprintf("Index Scan using %s on %s%s: %s", p.indexScan.index, explainTable(p.indexScan.table, p.indexScan.as), explainColumns(p.indexScan.columns), p.indexScan.cond.generateCode())
+	case filterPlanKind:
+		line = fmt.Sprintf("Filter: %s", p.filter.predicate.generateCode())
+	case projectPlanKind:
+		var items []string
+		for _, item := range p.project.items {
+			if item.asterisk {
+				items = append(items, "*")
+				continue
+			}
+
+			code := item.exp.generateCode()
+			if item.as != nil {
+				code += " AS " + item.as.Value
+			}
+
+			items = append(items, code)
+		}
+
+		line = fmt.Sprintf("Project: %s", strings.Join(items, ", "))
+	case joinPlanKind:
+		line = p.join.algorithm.String()
+		if p.join.cond != nil {
+			line += ": " + p.join.cond.generateCode()
+		}
+	case aggregatePlanKind:
+		var aggregates, keys []string
+		for _, aggregate := range p.aggregate.aggregates {
+			aggregates = append(aggregates, aggregate.generateCode())
+		}
+
+		for _, key := range p.aggregate.groupBy {
+			keys = append(keys, key.generateCode())
+		}
+
+		line = "Aggregate"
+		if len(aggregates) > 0 {
+			line += ": " + strings.Join(aggregates, ", ")
+		}
+
+		if len(keys) > 0 {
+			line += " by " + strings.Join(keys, ", ")
+		}
+	}
+
+	line += fmt.Sprintf(" (rows=%.0f)", p.rows)
+
+	if p.analysis != nil {
+		ms := float64(p.analysis.duration.Microseconds()) / 1000
+		line += fmt.Sprintf(" (actual rows=%d time=%.3fms)", p.analysis.rows, ms)
+	}
+
+	lines := []string{line}
+	for _, child := range p.children() {
+		for _, childLine := range child.explain() {
+			if !strings.HasPrefix(childLine, " ") {
+				childLine = "-> " + childLine
+			}
+
+			lines = append(lines, "  "+childLine)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,68 @@
+package ashudb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan_explain(t *testing.T) {
+	c := testCatalog{indexes: map[string]string{"users.id": "users_id"}}
+
+	tests := []struct {
+		source string
+		lines  []string
+	}{
+		{
+			source: "SELECT 1 + 1;",
+			lines: []string{
+				"Project: 2 (rows=1)",
+			},
+		},
+		{
+			source: "SELECT * FROM users;",
+			lines: []string{
+				"Project: * (rows=1000)",
+				"  -> Scan on users (rows=1000)",
+			},
+		},
+		{
+			source: "SELECT name AS fullname FROM users WHERE id = 2 AND name <> 'Phil';",
+			lines: []string{
+				"Project: name AS fullname (rows=90)",
+				"  -> Filter: (name <> 'Phil') (rows=90)",
+				"    -> Index Scan using users_id on users (name): (id = 2) (rows=100)",
+			},
+		},
+		{
+			source: "SELECT id FROM users WHERE id > 2 OR name = 'Phil';",
+			lines: []string{
+				"Project: id (rows=400)",
+				"  -> Filter: ((id > 2) OR (name = 'Phil')) (rows=400)",
+				"    -> Scan on users (id, name) (rows=1000)",
+			},
+		},
+		{
+			source: "SELECT name, count(*) + 1, max(id) AS last FROM users WHERE id > 2 GROUP BY name;",
+			lines: []string{
+				"Project: name, (count(*) + 1), max(id) AS last (rows=10)",
+				"  -> Aggregate: count(*), max(id) by name (rows=10)",
+				"    -> Filter: (id > 2) (rows=333)",
+				"      -> Scan on users (name, id) (rows=1000)",
+			},
+		},
+		{
+			source: "SELECT count(*) FROM users;",
+			lines: []string{
+				"Project: count(*) AS count (rows=1)",
+				"  -> Aggregate: count(*) (rows=1)",
+				"    -> Scan on users () (rows=1000)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		p := optimize(newPlan(parseSelect(t, test.source)), c)
+		assert.Equal(t, test.lines, p.explain(), test.source)
+	}
+}
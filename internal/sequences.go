@@ -0,0 +1,162 @@
+package ashudb
+
+import (
+	"math"
+	"strconv"
+	"sync"
+)
+
+// sequence hands out a series of BIGINT values for nextval. A value
+// once handed out is never given back, even if the statement taking it
+// fails, so a series can have gaps but never repeats.
+type sequence struct {
+	mu        sync.Mutex
+	start     int64
+	increment int64
+	// last is the value last handed out, if called
+	last   int64
+	called bool
+}
+
+// next advances the sequence and returns its new value.
+func (s *sequence) next() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.called {
+		s.last, s.called = s.start, true
+		return s.last, nil
+	}
+
+	if (s.increment > 0 && s.last > math.MaxInt64-s.increment) ||
+		(s.increment < 0 && s.last < math.MinInt64-s.increment) {
+		return 0, ErrNumericOverflow
+	}
+
+	s.last += s.increment
+	return s.last, nil
+}
+
+// current returns the value last handed out.
+func (s *sequence) current() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.called {
+		return 0, ErrSequenceNotCalled
+	}
+
+	return s.last, nil
+}
+
+func (mb *MemoryBackend) CreateSequence(crt *CreateSequenceStatement) error {
+	s, err := newSequence(crt.start, crt.increment)
+	if err != nil {
+		return err
+	}
+
+	return mb.addSequence(crt.name.Value, s)
+}
+
+// newSequence makes a sequence starting at start and going up by
+// increment, 1 for either if nil.
+func newSequence(start, increment *Token) (*sequence, error) {
+	s := &sequence{start: 1, increment: 1}
+	for _, option := range []struct {
+		t     *Token
+		value *int64
+	}{{start, &s.start}, {increment, &s.increment}} {
+		if option.t == nil {
+			continue
+		}
+
+		n, err := strconv.ParseInt(option.t.Value, 10, 64)
+		if err != nil {
+			return nil, ErrNumericOverflow
+		}
+
+		*option.value = n
+	}
+
+	if s.increment == 0 {
+		return nil, ErrInvalidIncrement
+	}
+
+	return s, nil
+}
+
+func (mb *MemoryBackend) addSequence(name string, s *sequence) error {
+	if _, ok := mb.sequences[name]; ok {
+		return ErrSequenceExists
+	}
+
+	mb.sequences[name] = s
+	return nil
+}
+
+// serialTypes are the column types that are shorthand for an integer
+// column defaulting to the next value of a sequence.
+var serialTypes = map[string]ColumnType{
+	"serial":    IntType,
+	"bigserial": BigIntType,
+}
+
+// nextvalExpression is a call to nextval for the named sequence.
+func nextvalExpression(name string) *expression {
+	return &expression{
+		kind: functionKind,
+		function: &functionExpression{
+			name: Token{Value: "nextval", Kind: IdentifierKind},
+			args: []expression{{
+				kind:    literalKind,
+				literal: &Token{Value: name, Kind: StringKind},
+			}},
+		},
+	}
+}
+
+// isSequenceFunction reports whether name is nextval or currval, which
+// read the sequences of the backend they are bound to.
+func isSequenceFunction(name string) bool {
+	return name == "nextval" || name == "currval"
+}
+
+// evaluateSequenceCell evaluates nextval or currval, whose argument
+// names a sequence. Unbound, it only works out the type of the result.
+func evaluateSequenceCell(exp functionExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	if exp.asterisk || len(exp.args) != 1 {
+		return nil, ResultColumn{}, ErrInvalidArguments
+	}
+
+	name, col, err := evaluateCell(exp.args[0], columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	if col.Type != TextType {
+		return nil, ResultColumn{}, ErrInvalidArguments
+	}
+
+	result := ResultColumn{Type: BigIntType, Name: "?column?"}
+	if exp.sequences == nil || name == nil {
+		return nil, result, nil
+	}
+
+	s, ok := exp.sequences[name.AsText()]
+	if !ok {
+		return nil, ResultColumn{}, ErrSequenceDoesNotExist
+	}
+
+	var value int64
+	if exp.name.Value == "nextval" {
+		value, err = s.next()
+	} else {
+		value, err = s.current()
+	}
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	cell, err := integerToCell(value, BigIntType)
+	return cell, result, err
+}
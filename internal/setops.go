@@ -0,0 +1,176 @@
+package ashudb
+
+// setColumns returns the columns of a set operation on queries
+// returning left and right: named after the left side's, and of the
+// type the values of both sides are compared as.
+func setColumns(left, right []ResultColumn) ([]ResultColumn, error) {
+	if len(left) != len(right) {
+		return nil, ErrSetColumns
+	}
+
+	columns := []ResultColumn{}
+	for i := range left {
+		typ, ok := commonType(left[i].Type, right[i].Type)
+		if !ok {
+			return nil, ErrSetTypes
+		}
+
+		columns = append(columns, ResultColumn{Type: typ, Name: left[i].Name})
+	}
+
+	return columns, nil
+}
+
+// convertRows converts the rows of rel to types.
+func convertRows(rel *relation, types []ColumnType) ([][]MemoryCell, error) {
+	rows := [][]MemoryCell{}
+	for _, row := range rel.rows {
+		converted := make([]MemoryCell, len(row))
+		for i, cell := range row {
+			var err error
+			converted[i], err = convertCell(cell, rel.columns[i].Type, types[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		rows = append(rows, converted)
+	}
+
+	return rows, nil
+}
+
+// distinctKey encodes a row like hashKey, but telling unknown values
+// apart from empty ones, so that two rows encode the same if each of
+// their values is equal or unknown in both.
+func distinctKey(row []MemoryCell, types []ColumnType) string {
+	unknown := make([]byte, len(row))
+	for i, cell := range row {
+		if cell == nil {
+			unknown[i] = 1
+		}
+	}
+
+	return string(unknown) + hashKey(row, types)
+}
+
+// distinctRows returns the rows that aren't duplicates of one before
+// them or of one already in seen, adding them to seen.
+func distinctRows(rows [][]MemoryCell, types []ColumnType, seen map[string]bool) [][]MemoryCell {
+	kept := [][]MemoryCell{}
+	for _, row := range rows {
+		key := distinctKey(row, types)
+		if !seen[key] {
+			seen[key] = true
+			kept = append(kept, row)
+		}
+	}
+
+	return kept
+}
+
+// executeSetOperation combines the rows of both sides. With ALL a row
+// the left side has m times and the right side n times is kept m + n
+// times by UNION, min(m, n) times by INTERSECT and max(m - n, 0) times
+// by EXCEPT. Without it every row is kept at most once.
+func (mb *MemoryBackend) executeSetOperation(s *setOperationPlan) (*relation, error) {
+	left, err := mb.execute(s.left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := mb.execute(s.right)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := setColumns(left.columns, right.columns)
+	if err != nil {
+		return nil, err
+	}
+
+	types := []ColumnType{}
+	for _, col := range columns {
+		types = append(types, col.Type)
+	}
+
+	leftRows, err := convertRows(left, types)
+	if err != nil {
+		return nil, err
+	}
+
+	rightRows, err := convertRows(right, types)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.op == UnionKeyword {
+		rows := append(leftRows, rightRows...)
+		if !s.all {
+			rows = distinctRows(rows, types, map[string]bool{})
+		}
+
+		return &relation{columns: columns, rows: rows}, nil
+	}
+
+	counts := map[string]int{}
+	for _, row := range rightRows {
+		counts[distinctKey(row, types)]++
+	}
+
+	rows := [][]MemoryCell{}
+	seen := map[string]bool{}
+	for _, row := range leftRows {
+		key := distinctKey(row, types)
+		inRight := counts[key] > 0
+		if s.all {
+			if inRight {
+				counts[key]--
+			}
+		} else {
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+		}
+
+		if inRight == (s.op == IntersectKeyword) {
+			rows = append(rows, row)
+		}
+	}
+
+	return &relation{columns: columns, rows: rows}, nil
+}
+
+func (mb *MemoryBackend) executeDistinct(d *distinctPlan) (*relation, error) {
+	child, err := mb.execute(d.child)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := child.rows
+	types := []ColumnType{}
+	for _, col := range child.columns {
+		types = append(types, col.Type)
+	}
+
+	if d.on != nil {
+		keys, types, err = evaluateKeys(child, d.on)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows := [][]MemoryCell{}
+	seen := map[string]bool{}
+	for i, key := range keys {
+		encoded := distinctKey(key, types)
+		if !seen[encoded] {
+			seen[encoded] = true
+			rows = append(rows, child.rows[i])
+		}
+	}
+
+	return &relation{columns: child.columns, rows: rows}, nil
+}
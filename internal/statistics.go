@@ -0,0 +1,195 @@
+package ashudb
+
+import "sort"
+
+// histogramBuckets is how many equi-depth buckets ANALYZE splits each
+// column into.
+const histogramBuckets = 10
+
+type columnStatistics struct {
+	typ ColumnType
+	// distinct, min, max and histogram are those of the values that
+	// aren't unknown, nullFraction being the fraction that are
+	distinct     int
+	nullFraction float64
+	min          MemoryCell
+	max          MemoryCell
+	// histogram holds the bounds of equi-depth buckets: about the same
+	// number of rows fall between each pair of adjacent bounds.
+	histogram []MemoryCell
+}
+
+type tableStatistics struct {
+	rows    int
+	columns map[string]*columnStatistics
+}
+
+// analyzeColumn computes the statistics of one column's values.
+func analyzeColumn(cells []MemoryCell, typ ColumnType) *columnStatistics {
+	stats := columnStatistics{typ: typ}
+
+	sorted := []MemoryCell{}
+	for _, cell := range cells {
+		if cell != nil {
+			sorted = append(sorted, cell)
+		}
+	}
+
+	if len(cells) > 0 {
+		stats.nullFraction = float64(len(cells)-len(sorted)) / float64(len(cells))
+	}
+
+	if len(sorted) == 0 {
+		return &stats
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return compareCells(sorted[i], sorted[j], typ) < 0
+	})
+
+	stats.min = sorted[0]
+	stats.max = sorted[len(sorted)-1]
+
+	distinct := map[string]bool{}
+	for _, cell := range sorted {
+		distinct[string(cell)] = true
+	}
+	stats.distinct = len(distinct)
+
+	buckets := histogramBuckets
+	if len(sorted) < buckets {
+		buckets = len(sorted)
+	}
+
+	for i := 0; i <= buckets; i++ {
+		position := i * (len(sorted) - 1) / buckets
+		stats.histogram = append(stats.histogram, sorted[position])
+	}
+
+	return &stats
+}
+
+// Estimates used when there are no statistics to go on, in the spirit
+// of other databases' planner defaults.
+const (
+	defaultRows             = 1000
+	defaultEqSelectivity    = 0.1
+	defaultRangeSelectivity = 1.0 / 3
+	defaultSelectivity      = 0.5
+)
+
+// fractionBelow estimates the fraction of a column's values that are
+// less than cell, from its histogram. Unknown values aren't counted.
+func (cs *columnStatistics) fractionBelow(cell MemoryCell) float64 {
+	if len(cs.histogram) < 2 {
+		return defaultRangeSelectivity
+	}
+
+	below := 0
+	for _, bound := range cs.histogram {
+		if compareCells(bound, cell, cs.typ) < 0 {
+			below++
+		}
+	}
+
+	return float64(below) / float64(len(cs.histogram)) * (1 - cs.nullFraction)
+}
+
+func (cs *columnStatistics) eqSelectivity() float64 {
+	if cs.distinct == 0 {
+		return defaultEqSelectivity * (1 - cs.nullFraction)
+	}
+
+	return (1 - cs.nullFraction) / float64(cs.distinct)
+}
+
+// statisticsLookup finds the statistics of a column referenced by an
+// expression, nil if there are none.
+type statisticsLookup func(column expression) *columnStatistics
+
+// selectivity estimates the fraction of rows a predicate keeps.
+func selectivity(predicate expression, lookup statisticsLookup) float64 {
+	if predicate.kind != binaryKind {
+		return defaultSelectivity
+	}
+
+	a, b, op := predicate.binary.a, predicate.binary.b, predicate.binary.op
+	if op.Kind == KeywordKind {
+		switch Keyword(op.Value) {
+		case AndKeyword:
+			return selectivity(a, lookup) * selectivity(b, lookup)
+		case OrKeyword:
+			sa, sb := selectivity(a, lookup), selectivity(b, lookup)
+			return sa + sb - sa*sb
+		}
+
+		return defaultSelectivity
+	}
+
+	// Put the column on the left, flipping the comparison to match
+	flipped := Symbol(op.Value)
+	if !isColumn(a) && isColumn(b) {
+		a, b = b, a
+		switch flipped {
+		case LtSymbol:
+			flipped = GtSymbol
+		case LteSymbol:
+			flipped = GteSymbol
+		case GtSymbol:
+			flipped = LtSymbol
+		case GteSymbol:
+			flipped = LteSymbol
+		}
+	}
+
+	if !isColumn(a) {
+		return defaultSelectivity
+	}
+
+	stats := lookup(a)
+
+	switch flipped {
+	case EqSymbol, NeqSymbol, BangEqSymbol:
+		eq := defaultEqSelectivity
+		if stats != nil {
+			eq = stats.eqSelectivity()
+		}
+
+		// Joining two columns matches each value against the column
+		// with more distinct values
+		if isColumn(b) {
+			if other := lookup(b); other != nil && (stats == nil || other.distinct > stats.distinct) {
+				eq = other.eqSelectivity()
+			}
+		}
+
+		if flipped == EqSymbol {
+			return eq
+		}
+
+		// Unknown values are neither equal nor unequal to anything
+		if stats != nil {
+			return 1 - stats.nullFraction - eq
+		}
+
+		return 1 - eq
+	case LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+		if stats == nil || !isConstant(b) {
+			return defaultRangeSelectivity
+		}
+
+		cell, col, err := evaluateCell(b, nil, nil)
+		if err != nil || col.Type != stats.typ {
+			return defaultRangeSelectivity
+		}
+
+		below := stats.fractionBelow(cell)
+		if flipped == LtSymbol || flipped == LteSymbol {
+			return below
+		}
+
+		return 1 - stats.nullFraction - below
+	}
+
+	return defaultSelectivity
+}
@@ -0,0 +1,608 @@
+package ashudb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// boundValue is a value substituted into an expression for a column,
+// like the columns of the row a correlated subquery runs for.
+type boundValue struct {
+	cell MemoryCell
+	typ  ColumnType
+}
+
+func (v *boundValue) generateCode() string {
+	if v.cell == nil {
+		return "NULL"
+	}
+
+	if t, ok := cellToToken(v.cell, v.typ); ok {
+		return expression{literal: t, kind: literalKind}.generateCode()
+	}
+
+	value := strings.ReplaceAll(formatCell(v.cell, v.typ), "'", "''")
+	return fmt.Sprintf("'%s'::%s", value, v.typ)
+}
+
+// boundSubquery is a subquery bound to the backend it runs against. How
+// it depends on the rows it runs for is worked out the first time it
+// runs:
+//
+//   - an uncorrelated subquery runs once, and its result is kept
+//   - a subquery only correlated by equalities in its WHERE clause,
+//     like `o.user_id = u.id`, is decorrelated: it runs once without
+//     them, and its rows are grouped on the inner side of each
+//   - any other correlated subquery runs again for every row, with the
+//     values of the row in place of its columns
+type boundSubquery struct {
+	mb *MemoryBackend
+	// ctes is the scope of common table expressions the subquery runs
+	// in
+	ctes *cteScope
+	once sync.Once
+	err  error
+
+	correlated bool
+	// result is the result of an uncorrelated subquery, and columns
+	// describes what the subquery returns
+	result  *relation
+	columns []ResultColumn
+	// outerKeys are the expressions over the outer row whose values
+	// pick the group of a decorrelated subquery's rows to use, and
+	// keyTypes the types both sides are compared as
+	outerKeys []expression
+	keyTypes  []ColumnType
+	groups    map[string][][]MemoryCell
+
+	// described is what the subquery returns as worked out by a dry
+	// run, for typing it without running it
+	describeOnce sync.Once
+	described    []ResultColumn
+	describeErr  error
+}
+
+// correlation rewrites a subquery for the row it runs for, replacing
+// the columns of the outer query it refers to with their values.
+type correlation struct {
+	mb      *MemoryBackend
+	columns []ResultColumn
+	row     []MemoryCell
+	// correlated is set once a column of the outer query is replaced
+	correlated bool
+}
+
+// scope returns the columns of the tables in the FROM clause of slct.
+// Tables that don't exist are left for running the query to report.
+func (mb *MemoryBackend) scope(slct *SelectStatement) []columnRef {
+	if slct.from == nil {
+		return nil
+	}
+
+	columns := []columnRef{}
+	for _, from := range append([]*fromItem{slct.from}, joinedItems(slct.joins)...) {
+		if itemColumns, ok := planColumns(newScanPlan(from), mb); ok {
+			columns = append(columns, itemColumns...)
+		}
+	}
+
+	return columns
+}
+
+func joinedItems(joins []*joinItem) []*fromItem {
+	items := []*fromItem{}
+	for _, join := range joins {
+		items = append(items, join.from)
+	}
+
+	return items
+}
+
+// selectStatement rewrites slct, whose columns are visible to it along
+// with those of the enclosing scopes.
+func (c *correlation) selectStatement(slct *SelectStatement, scopes [][]columnRef) *SelectStatement {
+	rewritten := *slct
+
+	if slct.with != nil {
+		with := *slct.with
+		with.ctes = nil
+		for _, cte := range slct.with.ctes {
+			copied := *cte
+			copied.slct = c.selectStatement(cte.slct, scopes)
+			if cte.recursive != nil {
+				copied.recursive = c.selectStatement(cte.recursive, scopes)
+			}
+
+			with.ctes = append(with.ctes, &copied)
+		}
+
+		rewritten.with = &with
+	}
+
+	if slct.set != nil {
+		set := *slct.set
+		set.left = c.selectStatement(slct.set.left, scopes)
+		set.right = c.selectStatement(slct.set.right, scopes)
+		rewritten.set = &set
+		return &rewritten
+	}
+
+	// Subqueries in FROM don't see the tables next to them
+	rewritten.from = c.fromItem(slct.from, scopes)
+	scopes = append(scopes[:len(scopes):len(scopes)], c.mb.scope(slct))
+
+	items := []*selectItem{}
+	for _, item := range *slct.item {
+		if item.exp != nil {
+			exp := c.expression(*item.exp, scopes)
+			item = &selectItem{exp: &exp, as: item.as}
+		}
+
+		items = append(items, item)
+	}
+	rewritten.item = &items
+
+	rewritten.joins = nil
+	for _, join := range slct.joins {
+		joined := &joinItem{from: c.fromItem(join.from, scopes[:len(scopes)-1])}
+		if join.on != nil {
+			on := c.expression(*join.on, scopes)
+			joined.on = &on
+		}
+
+		rewritten.joins = append(rewritten.joins, joined)
+	}
+
+	if slct.where != nil {
+		where := c.expression(*slct.where, scopes)
+		rewritten.where = &where
+	}
+
+	rewritten.groupBy = nil
+	for _, key := range slct.groupBy {
+		exp := c.expression(*key, scopes)
+		rewritten.groupBy = append(rewritten.groupBy, &exp)
+	}
+
+	rewritten.distinctOn = nil
+	for _, on := range slct.distinctOn {
+		exp := c.expression(*on, scopes)
+		rewritten.distinctOn = append(rewritten.distinctOn, &exp)
+	}
+
+	return &rewritten
+}
+
+func (c *correlation) fromItem(from *fromItem, scopes [][]columnRef) *fromItem {
+	if from == nil {
+		return nil
+	}
+
+	// Tables are copied too, as running the rewritten query binds them
+	copied := *from
+	if from.subquery != nil {
+		copied.subquery = c.selectStatement(from.subquery, scopes)
+	}
+
+	return &copied
+}
+
+func (c *correlation) expression(e expression, scopes [][]columnRef) expression {
+	switch e.kind {
+	case literalKind:
+		if e.literal.Kind != IdentifierKind {
+			return e
+		}
+
+		// The innermost scope with the column is the one it refers to
+		refs := referencedColumns(e, nil)
+		for _, scope := range scopes {
+			if covers(scope, refs) {
+				return e
+			}
+		}
+
+		// Columns that are nowhere are left for the subquery to report
+		i, err := resolveColumn(e, c.columns)
+		if err != nil {
+			return e
+		}
+
+		c.correlated = true
+		return expression{
+			kind:  valueKind,
+			value: &boundValue{cell: c.row[i], typ: c.columns[i].Type},
+		}
+	case binaryKind:
+		return expression{
+			kind: binaryKind,
+			binary: &binaryExpression{
+				a:  c.expression(e.binary.a, scopes),
+				b:  c.expression(e.binary.b, scopes),
+				op: e.binary.op,
+			},
+		}
+	case functionKind:
+		function := *e.function
+		function.args = nil
+		for _, arg := range e.function.args {
+			function.args = append(function.args, c.expression(arg, scopes))
+		}
+
+		return expression{function: &function, kind: functionKind}
+	case subqueryKind:
+		subquery := *e.subquery
+		subquery.slct = c.selectStatement(e.subquery.slct, scopes)
+		subquery.bound = nil
+		if e.subquery.in != nil {
+			in := c.expression(*e.subquery.in, scopes)
+			subquery.in = &in
+		}
+
+		return expression{subquery: &subquery, kind: subqueryKind}
+	}
+
+	return e
+}
+
+// correlate rewrites slct for the row of columns it runs for, and
+// reports whether it refers to any of them.
+func (mb *MemoryBackend) correlate(slct *SelectStatement, columns []ResultColumn, row []MemoryCell) (*SelectStatement, bool) {
+	c := &correlation{mb: mb, columns: columns, row: row}
+	rewritten := c.selectStatement(slct, nil)
+	return rewritten, c.correlated
+}
+
+// prepare works out how slct depends on the rows of columns it runs
+// for, running it now if it can be run once for all of them.
+func (b *boundSubquery) prepare(slct *SelectStatement, columns []ResultColumn) error {
+	unknown := make([]MemoryCell, len(columns))
+	if _, correlated := b.mb.correlate(slct, columns, unknown); !correlated {
+		result, err := b.mb.run(slct, b.ctes)
+		if err != nil {
+			return err
+		}
+
+		b.result, b.columns = result, result.columns
+		return nil
+	}
+
+	ok, err := b.decorrelate(slct, columns)
+	if err != nil {
+		return err
+	}
+
+	b.correlated = !ok
+	return nil
+}
+
+// decorrelate runs a subquery only correlated by equalities between
+// its own columns and the outer row's in its WHERE clause, without
+// them, and groups its rows on the values of its side of each. ok is
+// false if the subquery isn't of that kind.
+func (b *boundSubquery) decorrelate(slct *SelectStatement, columns []ResultColumn) (ok bool, err error) {
+	// DISTINCT ON would pick from the rows of every group at once
+	if slct.where == nil || len(slct.groupBy) > 0 || len(slct.distinctOn) > 0 || hasAggregates(*slct.item) {
+		return false, nil
+	}
+
+	unknown := make([]MemoryCell, len(columns))
+	scope := b.mb.scope(slct)
+	correlated := func(e expression) bool {
+		c := &correlation{mb: b.mb, columns: columns, row: unknown}
+		c.expression(e, [][]columnRef{scope})
+		return c.correlated
+	}
+
+	// outer reports whether e only refers to columns of the outer row
+	outer := func(e expression) bool {
+		refs := referencedColumns(e, nil)
+		for _, ref := range refs {
+			if covers(scope, []columnRef{ref}) || !covers(columnRefs(columns), []columnRef{ref}) {
+				return false
+			}
+		}
+
+		return len(refs) > 0
+	}
+
+	var innerKeys, rest []expression
+	eq := tokenFromSymbol(EqSymbol)
+	for _, predicate := range conjuncts(*slct.where) {
+		if !correlated(predicate) {
+			rest = append(rest, predicate)
+			continue
+		}
+
+		if predicate.kind != binaryKind || !predicate.binary.op.equals(&eq) {
+			return false, nil
+		}
+
+		inner, outerKey := predicate.binary.a, predicate.binary.b
+		if outer(inner) {
+			inner, outerKey = outerKey, inner
+		}
+
+		if !outer(outerKey) || correlated(inner) {
+			return false, nil
+		}
+
+		innerKeys = append(innerKeys, inner)
+		b.outerKeys = append(b.outerKeys, outerKey)
+	}
+
+	// The rest of the subquery, with the inner keys as extra columns,
+	// must not depend on the outer row
+	items := append([]*selectItem{}, *slct.item...)
+	for i := range innerKeys {
+		items = append(items, &selectItem{exp: &innerKeys[i]})
+	}
+
+	decorrelated := *slct
+	decorrelated.item = &items
+	decorrelated.where = conjunction(rest)
+	if _, correlated := b.mb.correlate(&decorrelated, columns, unknown); correlated {
+		b.outerKeys = nil
+		return false, nil
+	}
+
+	result, err := b.mb.run(&decorrelated, b.ctes)
+	if err != nil {
+		return false, err
+	}
+
+	width := len(result.columns) - len(innerKeys)
+	for i, outerKey := range b.outerKeys {
+		col, err := describe(outerKey, columns)
+		if err != nil {
+			return false, err
+		}
+
+		typ, ok := commonType(result.columns[width+i].Type, col.Type)
+		if !ok {
+			return false, ErrInvalidOperands
+		}
+
+		b.keyTypes = append(b.keyTypes, typ)
+	}
+
+	b.groups = map[string][][]MemoryCell{}
+	for _, row := range result.rows {
+		key, ok, err := b.groupKey(row[width:], result.columns[width:])
+		if err != nil {
+			return false, err
+		}
+
+		// Rows with an unknown key equal nothing
+		if ok {
+			b.groups[key] = append(b.groups[key], row[:width])
+		}
+	}
+
+	b.columns = result.columns[:width]
+	return true, nil
+}
+
+// columnRefs describes columns as column references.
+func columnRefs(columns []ResultColumn) []columnRef {
+	refs := []columnRef{}
+	for _, col := range columns {
+		refs = append(refs, columnRef{table: col.table, name: col.Name})
+	}
+
+	return refs
+}
+
+// groupKey converts cells of columns to the key types and encodes them,
+// false if any is unknown.
+func (b *boundSubquery) groupKey(cells []MemoryCell, columns []ResultColumn) (string, bool, error) {
+	converted := []MemoryCell{}
+	for i, cell := range cells {
+		if cell == nil {
+			return "", false, nil
+		}
+
+		cell, err := convertCell(cell, columns[i].Type, b.keyTypes[i])
+		if err != nil {
+			return "", false, err
+		}
+
+		converted = append(converted, cell)
+	}
+
+	return hashKey(converted, b.keyTypes), true, nil
+}
+
+// rows returns the rows the subquery produces for row of columns.
+func (b *boundSubquery) rows(slct *SelectStatement, columns []ResultColumn, row []MemoryCell) ([]ResultColumn, [][]MemoryCell, error) {
+	b.once.Do(func() {
+		b.err = b.prepare(slct, columns)
+	})
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+
+	switch {
+	case b.correlated:
+		correlated, _ := b.mb.correlate(slct, columns, row)
+		result, err := b.mb.run(correlated, b.ctes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return result.columns, result.rows, nil
+	case b.groups != nil:
+		cells := []MemoryCell{}
+		keyColumns := []ResultColumn{}
+		for _, outerKey := range b.outerKeys {
+			cell, col, err := evaluateCell(outerKey, columns, row)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			cells = append(cells, cell)
+			keyColumns = append(keyColumns, col)
+		}
+
+		key, ok, err := b.groupKey(cells, keyColumns)
+		if err != nil || !ok {
+			return b.columns, nil, err
+		}
+
+		return b.columns, b.groups[key], nil
+	}
+
+	return b.columns, b.result.rows, nil
+}
+
+// dryRun runs slct without reading or computing any rows, for the
+// columns it returns.
+func (mb *MemoryBackend) dryRun(slct *SelectStatement, ctes *cteScope) ([]ResultColumn, error) {
+	dry := *mb
+	dry.dry = true
+
+	// Running a statement binds it, so a copy is run rather than slct
+	copied, _ := mb.correlate(slct, nil, nil)
+	rel, err := dry.run(copied, ctes)
+	if err != nil {
+		return nil, err
+	}
+
+	return rel.columns, nil
+}
+
+// describe works out the columns the subquery returns for rows of
+// columns, with a dry run in which its outer columns are unknown.
+func (b *boundSubquery) describe(slct *SelectStatement, columns []ResultColumn) ([]ResultColumn, error) {
+	b.describeOnce.Do(func() {
+		rewritten, _ := b.mb.correlate(slct, columns, make([]MemoryCell, len(columns)))
+		b.described, b.describeErr = b.mb.dryRun(rewritten, b.ctes)
+	})
+
+	return b.described, b.describeErr
+}
+
+// describeSubquery works out what a subquery expression evaluates to
+// against rows of columns without running it.
+func describeSubquery(exp *subqueryExpression, columns []ResultColumn) (ResultColumn, error) {
+	if exp.bound == nil {
+		return ResultColumn{}, ErrMisplacedSubquery
+	}
+
+	subqueryColumns, err := exp.bound.describe(exp.slct, columns)
+	if err != nil {
+		return ResultColumn{}, err
+	}
+
+	if exp.exists {
+		return ResultColumn{Type: BoolType, Name: "exists"}, nil
+	}
+
+	if len(subqueryColumns) != 1 {
+		return ResultColumn{}, ErrSubqueryColumns
+	}
+
+	if exp.in == nil {
+		return ResultColumn{Type: subqueryColumns[0].Type, Name: subqueryColumns[0].Name}, nil
+	}
+
+	x, err := describe(*exp.in, columns)
+	if err != nil {
+		return ResultColumn{}, err
+	}
+
+	if _, ok := commonType(x.Type, subqueryColumns[0].Type); !ok {
+		return ResultColumn{}, ErrInvalidOperands
+	}
+
+	return ResultColumn{Type: BoolType, Name: "?column?"}, nil
+}
+
+// evaluateSubqueryCell evaluates a scalar subquery, which must return
+// at most one row of one column, EXISTS, or IN, which is unknown
+// rather than false if x or any value x is compared to is unknown.
+func evaluateSubqueryCell(exp *subqueryExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	if exp.bound == nil {
+		return nil, ResultColumn{}, ErrMisplacedSubquery
+	}
+
+	subqueryColumns, rows, err := exp.bound.rows(exp.slct, columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	if exp.exists {
+		return boolToCell((len(rows) > 0) != exp.not), ResultColumn{Type: BoolType, Name: "exists"}, nil
+	}
+
+	if len(subqueryColumns) != 1 {
+		return nil, ResultColumn{}, ErrSubqueryColumns
+	}
+
+	if exp.in == nil {
+		if len(rows) > 1 {
+			return nil, ResultColumn{}, ErrSubqueryRows
+		}
+
+		result := ResultColumn{Type: subqueryColumns[0].Type, Name: subqueryColumns[0].Name}
+		if len(rows) == 0 {
+			return nil, result, nil
+		}
+
+		return rows[0][0], result, nil
+	}
+
+	x, xCol, err := evaluateCell(*exp.in, columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	result := ResultColumn{Type: BoolType, Name: "?column?"}
+	typ, ok := commonType(xCol.Type, subqueryColumns[0].Type)
+	if !ok {
+		return nil, ResultColumn{}, ErrInvalidOperands
+	}
+
+	x, err = convertCell(x, xCol.Type, typ)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	unknown := x == nil
+	for _, r := range rows {
+		value, err := convertCell(r[0], subqueryColumns[0].Type, typ)
+		if err != nil {
+			return nil, ResultColumn{}, err
+		}
+
+		if value == nil || x == nil {
+			unknown = true
+			continue
+		}
+
+		if compareCells(x, value, typ) == 0 {
+			return boolToCell(!exp.not), result, nil
+		}
+	}
+
+	if unknown {
+		return nil, result, nil
+	}
+
+	return boolToCell(exp.not), result, nil
+}
+
+func (mb *MemoryBackend) executeSubqueryScan(s *subqueryScanPlan) (*relation, error) {
+	child, err := mb.execute(s.child)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []ResultColumn{}
+	for _, col := range child.columns {
+		columns = append(columns, ResultColumn{Type: col.Type, Name: col.Name, table: s.as.Value})
+	}
+
+	return &relation{columns: columns, rows: child.rows}, nil
+}
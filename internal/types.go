@@ -0,0 +1,439 @@
+package ashudb
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// columnTypes maps the names of types in column definitions to them.
+var columnTypes = map[string]ColumnType{
+	"int":       IntType,
+	"smallint":  SmallIntType,
+	"bigint":    BigIntType,
+	"text":      TextType,
+	"varchar":   TextType,
+	"char":      TextType,
+	"boolean":   BoolType,
+	"bytea":     BlobType,
+	"blob":      BlobType,
+	"json":      JsonType,
+	"uuid":      UuidType,
+	"real":      RealType,
+	"double":    DoubleType,
+	"decimal":   DecimalType,
+	"numeric":   DecimalType,
+	"date":      DateType,
+	"time":      TimeType,
+	"timestamp": TimestampType,
+	"interval":  IntervalType,
+}
+
+// columnTypeNames are the names types are shown with, in errors.
+var columnTypeNames = map[ColumnType]string{
+	TextType:      "text",
+	IntType:       "int",
+	BoolType:      "boolean",
+	RealType:      "real",
+	DoubleType:    "double",
+	DecimalType:   "decimal",
+	SmallIntType:  "smallint",
+	BigIntType:    "bigint",
+	DateType:      "date",
+	TimeType:      "time",
+	TimestampType: "timestamp",
+	IntervalType:  "interval",
+	BlobType:      "bytea",
+	JsonType:      "json",
+	UuidType:      "uuid",
+}
+
+func (c ColumnType) String() string {
+	if name, ok := columnTypeNames[c]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+func parseColumnType(name string) (ColumnType, error) {
+	typ, ok := columnTypes[name]
+	if !ok {
+		return 0, ErrInvalidDatatype
+	}
+
+	return typ, nil
+}
+
+// coercion is how freely a value of one type is converted to another.
+// The coercion matrix is:
+//
+//   - implicitly, when values of different types are compared or
+//     combined: numbers to the wider type, dates to timestamps and text
+//     to dates, times, timestamps, intervals, JSON and UUIDs
+//   - on assignment, when a value is inserted into a column: also
+//     numbers to narrower types, timestamps to dates, any value to text
+//     and text to any type
+//   - explicitly, with CAST(x AS type) or x::type: also booleans and
+//     integers to each other and timestamps to times
+//
+// Values of other types can't be converted at all. Text only converts
+// to numbers when it's a string literal compared with one, as
+// coerceLiteral does: text columns holding numbers would otherwise
+// sort as numbers in one query and as text in the next.
+type coercion uint
+
+const (
+	implicitCoercion coercion = iota
+	assignmentCoercion
+	explicitCoercion
+	noCoercion
+)
+
+// coercionBetween returns the least coercion converting values of type
+// from to type to.
+func coercionBetween(from, to ColumnType) coercion {
+	switch {
+	case from == to:
+		return implicitCoercion
+	case isNumeric(from) && isNumeric(to):
+		if numericRank(to) < numericRank(from) {
+			return assignmentCoercion
+		}
+
+		return implicitCoercion
+	case from == DateType && to == TimestampType:
+		return implicitCoercion
+	case from == TextType && (isDatetime(to) || to == JsonType || to == UuidType):
+		return implicitCoercion
+	case from == TimestampType && to == DateType, from == TextType, to == TextType:
+		return assignmentCoercion
+	case from == TimestampType && to == TimeType:
+		return explicitCoercion
+	case from == BoolType && isInteger(to), isInteger(from) && to == BoolType:
+		return explicitCoercion
+	}
+
+	return noCoercion
+}
+
+// convertible reports whether values of type from can be converted to
+// type to with a coercion at most as free as c.
+func convertible(from, to ColumnType, c coercion) bool {
+	return coercionBetween(from, to) <= c
+}
+
+// commonType returns the type values of types a and b are compared or
+// combined in, the one the other implicitly converts to. Numbers are
+// promoted as commonNumericType does, dates are compared with
+// timestamps as midnight of their day and text is read as the date,
+// time or UUID it's compared with.
+func commonType(a, b ColumnType) (typ ColumnType, ok bool) {
+	switch {
+	case a == b:
+		return a, true
+	case isNumeric(a) && isNumeric(b):
+		return commonNumericType(a, b)
+	case convertible(a, b, implicitCoercion):
+		return b, true
+	case convertible(b, a, implicitCoercion):
+		return a, true
+	}
+
+	return 0, false
+}
+
+// convertCell converts a cell of one type to another, failing if the
+// value has no equivalent in the new type. Timestamps lose their time
+// of day when converted to dates and their date when converted to
+// times. Unknown values stay unknown.
+func convertCell(cell MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if cell == nil || from == to {
+		return cell, nil
+	}
+
+	switch {
+	case isNumeric(from) && isNumeric(to):
+		return convertNumeric(cell, from, to)
+	case to == TextType:
+		return MemoryCell(formatCell(cell, from)), nil
+	case from == TextType:
+		return parseCell(cell.AsText(), to)
+	case from == DateType && to == TimestampType:
+		return microsToCell(timestampMicros(cell, from)), nil
+	case from == TimestampType && to == DateType:
+		days := floorDiv(cell.AsBigInt(), microsPerDay)
+		if days != int64(int32(days)) {
+			return nil, ErrDatetimeOverflow
+		}
+
+		return intToCell(int32(days)), nil
+	case from == TimestampType && to == TimeType:
+		micros := cell.AsBigInt()
+		return microsToCell(micros - floorDiv(micros, microsPerDay)*microsPerDay), nil
+	case from == BoolType && isInteger(to):
+		if cell.AsBool() {
+			return integerToCell(1, to)
+		}
+
+		return integerToCell(0, to)
+	case isInteger(from) && to == BoolType:
+		return boolToCell(integerValue(cell, from) != 0), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// evaluateCastCell evaluates `CAST(x AS type)` or `x::type`, which may
+// convert x with an explicit coercion. Text too long for the type is
+// cut short.
+func evaluateCastCell(exp functionExpression, columns []ResultColumn, row []MemoryCell) (MemoryCell, ResultColumn, error) {
+	cell, col, err := evaluateCell(exp.args[0], columns, row)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	typ, err := parseColumnType(exp.cast.name.Value)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	modifiers, err := newTypeModifiers(exp.cast.name.Value, typ, exp.cast.modifiers)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	if !convertible(col.Type, typ, explicitCoercion) {
+		return nil, ResultColumn{}, ErrInvalidCast
+	}
+
+	cell, err = convertCell(cell, col.Type, typ)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	cell, err = modifiers.fit(cell, typ, true)
+	if err != nil {
+		return nil, ResultColumn{}, err
+	}
+
+	return cell, ResultColumn{Type: typ, Name: "?column?", pad: modifiers.pad()}, nil
+}
+
+// formatCell returns the text of a value, as the REPL prints it.
+func formatCell(cell MemoryCell, typ ColumnType) string {
+	switch {
+	case isInteger(typ):
+		return strconv.FormatInt(integerValue(cell, typ), 10)
+	case typ == BoolType:
+		return strconv.FormatBool(cell.AsBool())
+	case typ == RealType:
+		return strconv.FormatFloat(cell.AsFloat(), 'g', -1, 32)
+	case typ == DoubleType:
+		return strconv.FormatFloat(cell.AsFloat(), 'g', -1, 64)
+	case typ == DecimalType:
+		return cell.AsDecimal().String()
+	case typ == DateType:
+		return cell.AsDate().Format("2006-01-02")
+	case typ == TimeType:
+		return cell.AsTime().Format("15:04:05.999999")
+	case typ == TimestampType:
+		return cell.AsTimestamp().Format("2006-01-02 15:04:05.999999")
+	case typ == IntervalType:
+		return cell.AsInterval().String()
+	case typ == BlobType:
+		return `\x` + hex.EncodeToString(cell.AsBytes())
+	case typ == UuidType:
+		return cell.AsUUID().String()
+	}
+
+	return cell.AsText()
+}
+
+// parseCell reads text as a value of type typ. Surrounding spaces are
+// ignored, except by text and JSON.
+func parseCell(s string, typ ColumnType) (MemoryCell, error) {
+	trimmed := strings.TrimSpace(s)
+	switch {
+	case isInteger(typ):
+		i, err := strconv.ParseInt(trimmed, 10, 64)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return nil, ErrNumericOverflow
+			}
+
+			return nil, ErrInvalidCast
+		}
+
+		return integerToCell(i, typ)
+	case typ == DecimalType:
+		d, err := parseDecimal(trimmed)
+		if err != nil {
+			return nil, ErrInvalidCast
+		}
+
+		return decimalToCell(d), nil
+	case typ == RealType, typ == DoubleType:
+		f, err := strconv.ParseFloat(trimmed, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, ErrNumericOverflow
+		}
+
+		if err != nil {
+			return nil, ErrInvalidCast
+		}
+
+		if err := checkFloat(f, typ); err != nil {
+			return nil, err
+		}
+
+		return floatToCell(f, typ), nil
+	case typ == BoolType:
+		switch strings.ToLower(trimmed) {
+		case "true", "t", "yes", "y", "on", "1":
+			return boolToCell(true), nil
+		case "false", "f", "no", "n", "off", "0":
+			return boolToCell(false), nil
+		}
+
+		return nil, ErrInvalidCast
+	case typ == BlobType:
+		// Hexadecimal after \x, like the REPL prints, or else the bytes
+		// of the text
+		if digits, ok := strings.CutPrefix(s, `\x`); ok {
+			b, err := hex.DecodeString(digits)
+			if err != nil {
+				return nil, ErrInvalidCast
+			}
+
+			return MemoryCell(b), nil
+		}
+
+		return MemoryCell(s), nil
+	case typ == JsonType:
+		return jsonToCell(s)
+	case typ == UuidType:
+		u, err := parseUUID(trimmed)
+		if err != nil {
+			return nil, err
+		}
+
+		return MemoryCell(u[:]), nil
+	case typ == TextType:
+		return MemoryCell(s), nil
+	}
+
+	return parseDatetime(s, typ)
+}
+
+// parseDatetime reads text as a value of a date or time type.
+func parseDatetime(s string, typ ColumnType) (MemoryCell, error) {
+	switch typ {
+	case DateType:
+		days, err := parseDate(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return intToCell(days), nil
+	case TimeType:
+		micros, err := parseTime(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return microsToCell(micros), nil
+	case TimestampType:
+		micros, err := parseTimestamp(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return microsToCell(micros), nil
+	case IntervalType:
+		i, err := parseInterval(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return intervalToCell(i), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// fitText fits a string to a column of at most length characters.
+// Spaces past the length are dropped, anything else there is an error.
+// If padded, as for CHAR(n), trailing spaces don't count and are all
+// dropped, to be put back by padText.
+func fitText(s string, length int, padded bool) (string, error) {
+	if padded {
+		s = strings.TrimRight(s, " ")
+	}
+
+	if utf8.RuneCountInString(s) > length {
+		trimmed := strings.TrimRight(s, " ")
+		if utf8.RuneCountInString(trimmed) > length {
+			return "", ErrValueTooLong
+		}
+
+		// Keep as many of the spaces as fit
+		return trimmed + strings.Repeat(" ", length-utf8.RuneCountInString(trimmed)), nil
+	}
+
+	return s, nil
+}
+
+// padText pads a string with spaces to length characters.
+func padText(s string, length int) string {
+	if n := utf8.RuneCountInString(s); n < length {
+		return s + strings.Repeat(" ", length-n)
+	}
+
+	return s
+}
+
+// isStringLiteral reports whether e is a quoted string written without
+// a type, as opposed to text from a column or a function.
+func isStringLiteral(e expression) bool {
+	return e.kind == literalKind && e.literal.Kind == StringKind && e.typ == nil
+}
+
+// coerceLiteral converts cell, the value of e, to the numeric type
+// other if e is a string literal, so that 1 = '1' compares numbers.
+// Text from anywhere else keeps its type.
+func coerceLiteral(e expression, cell MemoryCell, typ, other ColumnType) (MemoryCell, ColumnType, error) {
+	if typ != TextType || !isNumeric(other) || !isStringLiteral(e) {
+		return cell, typ, nil
+	}
+
+	cell, err := convertCell(cell, TextType, other)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return cell, other, nil
+}
+
+// promoteOperands converts two values of different types to the type
+// an operation on them is carried out in.
+func promoteOperands(a, b MemoryCell, aType, bType ColumnType) (MemoryCell, MemoryCell, ColumnType, error) {
+	typ, ok := commonType(aType, bType)
+	if !ok {
+		return nil, nil, 0, ErrInvalidOperands
+	}
+
+	a, err := convertCell(a, aType, typ)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	b, err = convertCell(b, bType, typ)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return a, b, typ, nil
+}
@@ -0,0 +1,54 @@
+package ashudb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// UUID is a universally unique identifier, stored as its 16 bytes.
+type UUID [16]byte
+
+// String formats a UUID canonically, like
+// a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11.
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// parseUUID reads a UUID in canonical form or as 32 hexadecimal digits
+// without hyphens, in either case.
+func parseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) == 36 {
+		for _, i := range []int{8, 13, 18, 23} {
+			if s[i] != '-' {
+				return u, ErrInvalidUUID
+			}
+		}
+
+		s = strings.ReplaceAll(s, "-", "")
+	}
+
+	if len(s) != 32 {
+		return u, ErrInvalidUUID
+	}
+
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return u, ErrInvalidUUID
+	}
+
+	return u, nil
+}
+
+// newRandomUUID generates a random, version 4 UUID.
+func newRandomUUID() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		return u, err
+	}
+
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u, nil
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
 
 	ashudb "github.com/aashudb/ashudb/internal"
 )
 
 func main() {
+	workers := flag.Int("workers", runtime.GOMAXPROCS(0), "goroutines to split large queries across")
+	flag.Parse()
+
 	mb := ashudb.NewMemoryBackend()
+	mb.SetWorkers(*workers)
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("Welcome to AshuDB.")
@@ -21,61 +28,115 @@ func main() {
 
 		ast, err := ashudb.Parse(text)
 		if err != nil {
-			panic(err)
+			fmt.Println("Error:", err)
+			continue
 		}
 
 		for _, stmt := range ast.Statements {
-			switch stmt.Kind {
-			case ashudb.CreateTableKind:
-				err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
-				if err != nil {
-					panic(err)
-				}
-				fmt.Println("huss")
-			case ashudb.InsertKind:
-				err = mb.Insert(stmt.InsertStatement)
-				if err != nil {
-					panic(err)
-				}
-
-				fmt.Println("huss")
-			case ashudb.SelectKind:
-				results, err := mb.Select(stmt.SelectStatement)
-				if err != nil {
-					panic(err)
-				}
-
-				for _, col := range results.Columns {
-					fmt.Printf("| %s ", col.Name)
-				}
-				fmt.Println("|")
-
-				for i := 0; i < 20; i++ {
-					fmt.Printf("=")
-				}
-				fmt.Println()
-
-				for _, result := range results.Rows {
-					fmt.Printf("|")
-
-					for i, cell := range result {
-						typ := results.Columns[i].Type
-						s := ""
-						switch typ {
-						case ashudb.IntType:
-							s = fmt.Sprintf("%d", cell.AsInt())
-						case ashudb.TextType:
-							s = cell.AsText()
-						}
-
-						fmt.Printf(" %s | ", s)
-					}
-
-					fmt.Println()
-				}
-
-				fmt.Println("huss")
+			if err := execute(mb, stmt); err != nil {
+				fmt.Println("Error:", err)
+				break
+			}
+
+			fmt.Println("huss")
+		}
+	}
+}
+
+// execute runs a statement, printing the results of those that have
+// any.
+func execute(mb *ashudb.MemoryBackend, stmt *ashudb.Statement) error {
+	switch stmt.Kind {
+	case ashudb.CreateTableKind:
+		return mb.CreateTable(stmt.CreateTableStatement)
+	case ashudb.CreateIndexKind:
+		return mb.CreateIndex(stmt.CreateIndexStatement)
+	case ashudb.CreateSequenceKind:
+		return mb.CreateSequence(stmt.CreateSequenceStatement)
+	case ashudb.InsertKind:
+		results, err := mb.Insert(stmt.InsertStatement)
+		if err != nil {
+			return err
+		}
+
+		// Only an INSERT with RETURNING has results
+		if results != nil {
+			printResults(results)
+		}
+	case ashudb.SelectKind:
+		results, err := mb.Select(stmt.SelectStatement)
+		if err != nil {
+			return err
+		}
+
+		printResults(results)
+	case ashudb.AnalyzeKind:
+		return mb.Analyze(stmt.AnalyzeStatement)
+	case ashudb.ExplainKind:
+		results, err := mb.Explain(stmt.ExplainStatement)
+		if err != nil {
+			return err
+		}
+
+		printResults(results)
+	}
+
+	return nil
+}
+
+func printResults(results *ashudb.Results) {
+	for _, col := range results.Columns {
+		fmt.Printf("| %s ", col.Name)
+	}
+	fmt.Println("|")
+
+	for i := 0; i < 20; i++ {
+		fmt.Printf("=")
+	}
+	fmt.Println()
+
+	for _, result := range results.Rows {
+		fmt.Printf("|")
+
+		for i, cell := range result {
+			typ := results.Columns[i].Type
+			s := ""
+			switch {
+			case cell == nil:
+				// Unknown values are left blank
+			case typ == ashudb.IntType:
+				s = fmt.Sprintf("%d", cell.AsInt())
+			case typ == ashudb.SmallIntType:
+				s = fmt.Sprintf("%d", cell.AsSmallInt())
+			case typ == ashudb.BigIntType:
+				s = fmt.Sprintf("%d", cell.AsBigInt())
+			case typ == ashudb.TextType, typ == ashudb.JsonType:
+				s = cell.AsText()
+			case typ == ashudb.BoolType:
+				s = fmt.Sprintf("%t", cell.AsBool())
+			case typ == ashudb.RealType:
+				s = strconv.FormatFloat(cell.AsFloat(), 'g', -1, 32)
+			case typ == ashudb.DoubleType:
+				s = strconv.FormatFloat(cell.AsFloat(), 'g', -1, 64)
+			case typ == ashudb.DecimalType:
+				s = cell.AsDecimal().String()
+			case typ == ashudb.DateType:
+				s = cell.AsDate().Format("2006-01-02")
+			case typ == ashudb.TimeType:
+				s = cell.AsTime().Format("15:04:05.999999")
+			case typ == ashudb.TimestampType:
+				s = cell.AsTimestamp().Format("2006-01-02 15:04:05.999999")
+			case typ == ashudb.IntervalType:
+				s = cell.AsInterval().String()
+			case typ == ashudb.UuidType:
+				s = cell.AsUUID().String()
+			case typ == ashudb.BlobType:
+				s = fmt.Sprintf("\\x%x", cell.AsBytes())
 			}
+
+			fmt.Printf(" %s | ", s)
 		}
+
+		fmt.Println()
 	}
 }